@@ -1,69 +1,185 @@
 package analyser
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 
+	"github.com/luishfonseca/dtu_pa/assembler"
 	"github.com/luishfonseca/dtu_pa/data"
 	"github.com/luishfonseca/dtu_pa/parser"
 )
 
 type analyser struct {
-	classFile string
+	source    Source
+	opts      []parser.Option
+	selection Selection
+	out, warn io.Writer
 }
 
-func New(classFile string) *analyser {
+func New(classFile string, opts ...parser.Option) *analyser {
+	return NewSource(FileSource(classFile), opts...)
+}
+
+func NewSource(source Source, opts ...parser.Option) *analyser {
 	return &analyser{
-		classFile: classFile,
+		source: source,
+		opts:   opts,
+		out:    os.Stdout,
+		warn:   os.Stderr,
 	}
 }
 
-func (a *analyser) Inspect() error {
-	dataCh := make(chan data.Data)
+// Select restricts the views of the class to sel.
+func (a *analyser) Select(sel Selection) *analyser {
+	a.selection = sel
+	return a
+}
 
-	reqCh := make(chan data.Data)
-	defer close(reqCh)
+// Output redirects the views to out, and warnings to warn.
+func (a *analyser) Output(out, warn io.Writer) *analyser {
+	a.out, a.warn = out, warn
+	return a
+}
+
+// open parses the class file and calls fn with it, printing the diagnostics of
+// lenient parsing as warnings once done.
+func (a *analyser) open(ctx context.Context, fn func(s *parser.Session, class *data.Class) error) error {
+	input, err := a.source.open()
+	if err != nil {
+		return err
+	}
 
-	p, err := parser.New(a.classFile, dataCh, reqCh)
+	s, err := parser.OpenReader(ctx, input, a.opts...)
 	if err != nil {
-		return fmt.Errorf("error creating parser: %w", err)
+		return err
 	}
 
-	go func() {
-		if err := p.Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "error: parser: %v\n", err)
+	defer func() {
+		for _, diag := range s.Close() {
+			fmt.Fprintf(a.warn, "warning: %v\n", diag)
 		}
 	}()
 
-	d, ok := <-dataCh
-	if !ok {
-		return fmt.Errorf("error: no data received from parser")
+	class, err := s.Class()
+	if err != nil {
+		return err
 	}
 
-	class := d.Class()
-	fmt.Println(class)
+	return fn(s, class)
+}
+
+func (a *analyser) Inspect(ctx context.Context) error {
+	return a.open(ctx, func(s *parser.Session, class *data.Class) error {
+		selected, err := a.selection.apply(class)
+		if err != nil {
+			return err
+		}
+
+		printed := *selected
+		if !a.selection.constants(true) {
+			printed.ConstantPool = nil
+		}
+		fmt.Fprintln(a.out, printed)
+
+		if a.selection.NoCode {
+			return nil
+		}
+
+		for _, method := range selected.Methods {
+			h, ok := method.Attributes[data.ATTR_CODE]
+			switch {
+			case method.Unparsable:
+				fmt.Fprintln(a.out, method.Name, method.Descriptor, "-> unparsable")
+				fmt.Fprintln(a.out)
+				continue
+			case !ok: // abstract or native
+				fmt.Fprintln(a.out, method.Name, method.Descriptor, "-> no code")
+				fmt.Fprintln(a.out)
+				continue
+			}
+
+			d, err := s.Request(h)
+			if err != nil {
+				return err
+			}
+
+			attr := d.AttributeCode()
+			fmt.Fprintln(a.out, method.Name, method.Descriptor, "->", attr)
+
+			if d, err = s.Request(&attr.CodeHandle); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(a.out, d.Bytecode())
+			fmt.Fprintln(a.out)
+		}
 
-	for _, method := range class.Methods {
-		reqCh <- method.Attributes[data.ATTR_CODE]
+		return nil
+	})
+}
 
-		d, ok = <-dataCh
-		if !ok {
-			return fmt.Errorf("error: no data received from parser")
+// Disassemble prints the class in the text format of the assembler package.
+func (a *analyser) Disassemble(ctx context.Context) error {
+	return a.open(ctx, func(s *parser.Session, class *data.Class) error {
+		return assembler.Disassemble(a.out, class, s.Request)
+	})
+}
+
+// Javap prints the class like javap -c, or javap -c -v when verbose.
+func (a *analyser) Javap(ctx context.Context, verbose bool) error {
+	var header []string
+	if verbose {
+		b, err := a.source.Read()
+		if err != nil {
+			return err
 		}
 
-		attr := d.AttributeCode()
-		fmt.Println(method.Name, method.Descriptor, "->", attr)
+		name := a.source.Name
+		if name != "-" {
+			if path, err := filepath.Abs(name); err == nil {
+				name = path
+			}
+		}
 
-		reqCh <- &attr.CodeHandle
+		size := fmt.Sprintf("  size %d bytes", len(b))
+		if !a.source.ModTime.IsZero() {
+			size = fmt.Sprintf("  Last modified %s; size %d bytes", a.source.ModTime.Format("Jan 2, 2006"), len(b))
+		}
 
-		d, ok = <-dataCh
-		if !ok {
-			return fmt.Errorf("error: no data received from parser")
+		header = []string{
+			"Classfile " + name,
+			size,
+			fmt.Sprintf("  SHA-256 checksum %x", sha256.Sum256(b)),
 		}
+	}
+
+	return a.open(ctx, func(s *parser.Session, class *data.Class) error {
+		j := newJavap(a.out, class, s.Request, verbose)
+		j.selection = a.selection
+		return j.print(header)
+	})
+}
 
-		fmt.Println(d.Bytecode())
-		fmt.Println()
+// JSON prints the class in the schema documented by ClassJSON.
+func (a *analyser) JSON(ctx context.Context) error {
+	return a.open(ctx, func(s *parser.Session, class *data.Class) error {
+		return writeJSON(a.out, a.source.Name, class, s.Request, a.selection)
+	})
+}
+
+// Hexdump prints the bytes of the class file annotated with the structures
+// they hold.
+func (a *analyser) Hexdump(ctx context.Context) error {
+	b, err := a.source.Read()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return a.open(ctx, func(s *parser.Session, class *data.Class) error {
+		return writeHexdump(a.out, b, class, s.Request)
+	})
 }
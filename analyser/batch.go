@@ -0,0 +1,63 @@
+package analyser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// Result of analysing one source of a batch.
+type Result struct {
+	Source   Source
+	Output   []byte // what was printed
+	Warnings []byte // what was printed as warnings
+	Err      error
+}
+
+// Batch calls run on every source, at most workers at a time, and report with
+// the results in the order of sources, so the output does not depend on which
+// source is done first. run prints to out and warn, which are buffered until
+// reported.
+func Batch(ctx context.Context, sources []Source, workers int, run func(ctx context.Context, src Source, out, warn io.Writer) error, report func(Result)) {
+	workers = max(1, min(workers, len(sources)))
+
+	results := make([]chan Result, len(sources))
+	for i := range results {
+		results[i] = make(chan Result, 1)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				var out, warn bytes.Buffer
+				err := run(ctx, sources[i], &out, &warn)
+				results[i] <- Result{Source: sources[i], Output: out.Bytes(), Warnings: warn.Bytes(), Err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range sources {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				for ; i < len(sources); i++ {
+					results[i] <- Result{Source: sources[i], Err: ctx.Err()}
+				}
+				return
+			}
+		}
+	}()
+
+	for _, r := range results {
+		report(<-r)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,162 @@
+package analyser
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSources(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	write("classes/b/B.class", "B")
+	write("classes/A.class", "A")
+	write("classes/notes.txt", "ignored")
+	single := write("Single.class", "S")
+
+	jar, err := os.Create(filepath.Join(dir, "lib.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := zip.NewWriter(jar)
+	for _, name := range []string{"pkg/Z.class", "META-INF/MANIFEST.MF", "pkg/Y.class"} {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, strings.TrimSuffix(filepath.Base(name), ".class"))
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	jar.Close()
+
+	sources, err := Sources([]string{single, filepath.Join(dir, "classes"), jar.Name(), "-"}, strings.NewReader("stdin"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, src := range sources {
+		b, err := src.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.TrimPrefix(src.Name, dir+string(filepath.Separator))+"="+string(b))
+	}
+
+	want := []string{"Single.class=S", "classes/A.class=A", "classes/b/B.class=B", "lib.jar!pkg/Y.class=Y", "lib.jar!pkg/Z.class=Z", "-=stdin"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, args := range [][]string{{"-", "-"}, {filepath.Join(dir, "classes/notes.txt")}, {filepath.Join(dir, "missing.class")}} {
+		if _, err := Sources(args, strings.NewReader(""), 0); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+
+	if _, err := Sources([]string{"-"}, strings.NewReader("stdin"), 5); err != nil {
+		t.Errorf("stdin at the limit: %v", err)
+	}
+	if _, err := Sources([]string{"-"}, strings.NewReader("stdin"), 4); err == nil {
+		t.Error("expected stdin over the limit not to be read")
+	}
+
+	big, err := os.Create(filepath.Join(dir, "big.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z = zip.NewWriter(big)
+	w, err := z.Create("pkg/Big.class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(make([]byte, 100))
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	big.Close()
+
+	if sources, err = Sources([]string{big.Name()}, nil, 99); err != nil || len(sources) != 1 {
+		t.Fatalf("big.jar: %v, %v", sources, err)
+	}
+	if _, err := sources[0].Read(); err == nil {
+		t.Error("expected a jar entry over the limit not to be read")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	var sources []Source
+	for i := range 20 {
+		sources = append(sources, bytesSource(fmt.Sprint(i), time.Time{}, []byte{byte(i)}))
+	}
+
+	var running, most atomic.Int32
+	run := func(ctx context.Context, src Source, out, warn io.Writer) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+		}
+
+		b, err := src.Read()
+		if err != nil {
+			return err
+		}
+
+		// Later sources finish first
+		time.Sleep(time.Duration(20-b[0]) * time.Millisecond)
+
+		fmt.Fprintf(out, "out %d", b[0])
+		if b[0]%3 == 0 {
+			return fmt.Errorf("fail %d", b[0])
+		}
+		return nil
+	}
+
+	var got []string
+	Batch(context.Background(), sources, 4, run, func(r Result) {
+		line := string(r.Output)
+		if r.Err != nil {
+			line += " " + r.Err.Error()
+		}
+		got = append(got, line)
+	})
+
+	for i, line := range got {
+		want := fmt.Sprintf("out %d", i)
+		if i%3 == 0 {
+			want += fmt.Sprintf(" fail %d", i)
+		}
+		if line != want {
+			t.Errorf("result %d is %q, want %q", i, line, want)
+		}
+	}
+
+	if len(got) != len(sources) {
+		t.Errorf("got %d results, want %d", len(got), len(sources))
+	}
+
+	if m := most.Load(); m > 4 {
+		t.Errorf("%d sources ran at once, with 4 workers", m)
+	}
+}
@@ -0,0 +1,396 @@
+package analyser
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/parser"
+)
+
+// summary is what a diff compares of a class. Constants are resolved to what
+// they hold, so that classes whose pools are ordered differently compare
+// equal.
+type summary struct {
+	header  [][2]string // key and value, e.g. {"super", "java/lang/Object"}
+	fields  []memberSummary
+	methods []memberSummary
+}
+
+type memberSummary struct {
+	key   string // name and descriptor
+	flags string
+	code  []string // normalised instructions, nil without code
+	// unparsable code, which lenient parsing could not read
+	unparsable bool
+}
+
+func summarise(class *data.Class, resolve data.Resolver) (*summary, error) {
+	j := newJavap(io.Discard, class, resolve, false)
+
+	super := "none"
+	if class.SuperClass != nil {
+		super = j.internalName(class.SuperClass)
+	}
+
+	s := &summary{header: [][2]string{
+		{"version", class.Version},
+		{"flags", strings.Join(class.AccessFlags.Keywords(data.CLASS_FLAGS), " ")},
+		{"this", j.internalName(class.ThisClass)},
+		{"super", super},
+	}}
+
+	for _, c := range class.Interfaces {
+		s.header = append(s.header, [2]string{"implements", j.internalName(c)})
+	}
+
+	for _, h := range data.SortedHandles(class.Attributes) {
+		if h.AttributeTag != data.ATTR_SOURCE_FILE {
+			continue
+		}
+
+		attr, err := resolve(h)
+		if err != nil {
+			return nil, err
+		}
+		s.header = append(s.header, [2]string{"source", attr.AttributeSourceFile().SourceFile.Value})
+	}
+
+	for _, f := range class.Fields {
+		s.fields = append(s.fields, memberSummary{
+			key:   f.Name.Value + " " + f.Descriptor.Value,
+			flags: strings.Join(f.AccessFlags.Keywords(data.FIELD_FLAGS), " "),
+		})
+	}
+
+	for _, m := range class.Methods {
+		ms := memberSummary{
+			key:   m.Name.Value + m.Descriptor.Value,
+			flags: strings.Join(m.AccessFlags.Keywords(data.METHOD_FLAGS), " "),
+		}
+
+		h, ok := m.Attributes[data.ATTR_CODE]
+		ms.unparsable = ok && m.Unparsable
+		if ok && !m.Unparsable {
+			attr, err := resolve(h)
+			if err != nil {
+				return nil, err
+			}
+
+			if ms.code, err = j.normalise(attr.AttributeCode()); err != nil {
+				return nil, fmt.Errorf("method %s: %w", ms.key, err)
+			}
+		}
+
+		s.methods = append(s.methods, ms)
+	}
+
+	return s, nil
+}
+
+// normalise lists the instructions of code without their pcs, with the
+// constants they use resolved, followed by its limits and exception table.
+// Branches and the exception table refer to labels, numbered in the order of
+// the pcs they mark, so that moving code does not change them.
+func (j *javap) normalise(code *data.AttributeCode) ([]string, error) {
+	d, err := j.resolve(&code.CodeHandle)
+	if err != nil {
+		return nil, err
+	}
+	ops := d.Bytecode().Ops
+
+	marked := make(map[int]bool)
+	pc := 0
+	for _, op := range ops {
+		if offset, ok := op.BranchOffset(); ok {
+			marked[pc+offset] = true
+		}
+		pc += 1 + len(op.Arg)
+	}
+	for _, e := range code.ExceptionTable {
+		marked[int(e.StartPC)], marked[int(e.EndPC)], marked[int(e.HandlerPC)] = true, true, true
+	}
+
+	labels := make(map[int]string, len(marked))
+	for i, pc := range slices.Sorted(maps.Keys(marked)) {
+		labels[pc] = fmt.Sprintf("L%d", i)
+	}
+
+	var lines []string
+
+	pc = 0
+	for _, op := range ops {
+		if l, ok := labels[pc]; ok {
+			lines = append(lines, l+":")
+		}
+
+		text, comment := j.instruction(pc, op)
+		_, text, _ = strings.Cut(text, ": ")
+
+		offset, branch := op.BranchOffset()
+		switch {
+		case branch:
+			text = op.Code.String() + " " + labels[pc+offset]
+		case comment != "":
+			text = op.Code.String() + " " + comment
+		}
+		lines = append(lines, strings.Join(strings.Fields(text), " "))
+
+		pc += 1 + len(op.Arg)
+	}
+	if l, ok := labels[pc]; ok {
+		lines = append(lines, l+":")
+	}
+
+	lines = append(lines, fmt.Sprintf(".limit stack %d locals %d", code.MaxStack, code.MaxLocals))
+
+	for _, e := range code.ExceptionTable {
+		catch := "all"
+		if e.CatchType != nil {
+			catch = j.internalName(e.CatchType)
+		}
+		lines = append(lines, fmt.Sprintf(".catch %s from %s to %s using %s", catch, labels[int(e.StartPC)], labels[int(e.EndPC)], labels[int(e.HandlerPC)]))
+	}
+
+	return lines, nil
+}
+
+// writeDiff writes what changed from a to b, and reports whether anything
+// did.
+func writeDiff(w io.Writer, nameA, nameB string, a, b *summary) (bool, error) {
+	out := bufio.NewWriter(w)
+	changed := false
+
+	line := func(format string, args ...any) {
+		if !changed {
+			fmt.Fprintf(out, "--- %s\n+++ %s\n", nameA, nameB)
+			changed = true
+		}
+		fmt.Fprintf(out, format+"\n", args...)
+	}
+
+	for _, key := range mergeKeys(a.header, b.header) {
+		va, okA := lookup(a.header, key)
+		vb, okB := lookup(b.header, key)
+		switch {
+		case !okA:
+			line("+ %s %s", key, vb)
+		case !okB:
+			line("- %s %s", key, va)
+		case va != vb:
+			line("~ %s %s -> %s", key, va, vb)
+		}
+	}
+
+	for _, kind := range []string{"field", "method"} {
+		ma, mb := a.fields, b.fields
+		if kind == "method" {
+			ma, mb = a.methods, b.methods
+		}
+
+		byKey := make(map[string]memberSummary)
+		for _, m := range mb {
+			byKey[m.key] = m
+		}
+
+		inA := make(map[string]bool)
+		for _, m := range ma {
+			inA[m.key] = true
+
+			other, ok := byKey[m.key]
+			if !ok {
+				line("- %s %s", kind, m.key)
+				continue
+			}
+
+			// Code that could not be parsed is compared with nothing
+			unparsable := m.unparsable || other.unparsable
+			var hunks []string
+			if !unparsable {
+				hunks = diffLines(m.code, other.code, 2)
+			}
+			if m.flags == other.flags && !unparsable && (m.code == nil) == (other.code == nil) && len(hunks) == 0 {
+				continue
+			}
+
+			line("~ %s %s", kind, m.key)
+			if m.flags != other.flags {
+				line("  flags %s -> %s", m.flags, other.flags)
+			}
+
+			switch {
+			case m.unparsable:
+				line("  code could not be parsed in %s", nameA)
+				if other.unparsable {
+					line("  code could not be parsed in %s", nameB)
+				}
+			case other.unparsable:
+				line("  code could not be parsed in %s", nameB)
+			case m.code == nil && other.code != nil:
+				line("  code added")
+			case m.code != nil && other.code == nil:
+				line("  code removed")
+			}
+
+			for _, l := range hunks {
+				line("  %s", l)
+			}
+		}
+
+		for _, m := range mb {
+			if !inA[m.key] {
+				line("+ %s %s", kind, m.key)
+			}
+		}
+	}
+
+	return changed, out.Flush()
+}
+
+// mergeKeys lists the keys of a, followed by those only in b.
+func mergeKeys(a, b [][2]string) []string {
+	var keys []string
+	for _, kv := range a {
+		keys = append(keys, kv[0])
+	}
+	for _, kv := range b {
+		if _, ok := lookup(a, kv[0]); !ok {
+			keys = append(keys, kv[0])
+		}
+	}
+	return keys
+}
+
+func lookup(pairs [][2]string, key string) (string, bool) {
+	for _, kv := range pairs {
+		if kv[0] == key {
+			return kv[1], true
+		}
+	}
+	return "", false
+}
+
+// maxDiffCells bounds the table diffLines fills in, beyond which the lines
+// that differ are replaced as a whole.
+const maxDiffCells = 1 << 22
+
+// diffLines is a unified diff of a and b, with context lines around every
+// change, and "@@" separating hunks. It is empty if they are equal.
+func diffLines(a, b []string, context int) []string {
+	// Common prefix and suffix
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var edits []string
+	for _, l := range a[:pre] {
+		edits = append(edits, "  "+l)
+	}
+
+	midA, midB := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	if (len(midA)+1)*(len(midB)+1) > maxDiffCells {
+		for _, l := range midA {
+			edits = append(edits, "- "+l)
+		}
+		for _, l := range midB {
+			edits = append(edits, "+ "+l)
+		}
+	} else {
+		edits = append(edits, lcsEdits(midA, midB)...)
+	}
+
+	for _, l := range a[len(a)-suf:] {
+		edits = append(edits, "  "+l)
+	}
+
+	// Keep the changes and the context around them
+	keep := make([]bool, len(edits))
+	for n, e := range edits {
+		if e[0] != ' ' {
+			for m := max(0, n-context); m <= min(len(edits)-1, n+context); m++ {
+				keep[m] = true
+			}
+		}
+	}
+
+	var out []string
+	for n, e := range edits {
+		if !keep[n] {
+			continue
+		}
+		if len(out) > 0 && !keep[n-1] {
+			out = append(out, "@@")
+		}
+		out = append(out, e)
+	}
+
+	return out
+}
+
+// lcsEdits turns a into b keeping their longest common subsequence.
+func lcsEdits(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for k := len(b) - 1; k >= 0; k-- {
+			if a[i] == b[k] {
+				lcs[i][k] = lcs[i+1][k+1] + 1
+			} else {
+				lcs[i][k] = max(lcs[i+1][k], lcs[i][k+1])
+			}
+		}
+	}
+
+	var edits []string
+	i, k := 0, 0
+	for i < len(a) || k < len(b) {
+		switch {
+		case i < len(a) && k < len(b) && a[i] == b[k]:
+			edits = append(edits, "  "+a[i])
+			i, k = i+1, k+1
+		case i < len(a) && (k == len(b) || lcs[i+1][k] >= lcs[i][k+1]):
+			edits = append(edits, "- "+a[i])
+			i++
+		default:
+			edits = append(edits, "+ "+b[k])
+			k++
+		}
+	}
+
+	return edits
+}
+
+// Diff prints how the class of other differs from this one, and reports
+// whether it does.
+func (a *analyser) Diff(ctx context.Context, other *analyser) (changed bool, err error) {
+	err = a.open(ctx, func(s *parser.Session, class *data.Class) error {
+		before, err := summarise(class, s.Request)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.source.Name, err)
+		}
+
+		return other.open(ctx, func(s *parser.Session, class *data.Class) error {
+			after, err := summarise(class, s.Request)
+			if err != nil {
+				return fmt.Errorf("%s: %w", other.source.Name, err)
+			}
+
+			changed, err = writeDiff(a.out, a.source.Name, other.source.Name, before, after)
+			return err
+		})
+	})
+
+	return changed, err
+}
@@ -0,0 +1,147 @@
+package analyser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+)
+
+func TestDiff(t *testing.T) {
+	// summary of src, as lenient parsing gives it if the code of the
+	// methods named in unparsable could not be read
+	summary := func(src string, unparsable ...string) *summary {
+		class, resolve, err := assembler.Assemble(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, m := range class.Methods {
+			class.Methods[i].Unparsable = slices.Contains(unparsable, m.Name.Value)
+		}
+
+		s, err := summarise(class, resolve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	extra := `
+.method public static twice(I)I
+  .limit stack 2
+  .limit locals 1
+  iload_0
+  iconst_2
+  imul
+  ireturn
+.end method
+`
+	base := summary(source + extra)
+
+	// The same class, with its constants in another order
+	header, rest, _ := strings.Cut(source, "\n.field private static answer I\n")
+	reordered := summary(header + "\n" + extra + rest + ".field private static answer I\n")
+
+	var out strings.Builder
+	if changed, err := writeDiff(&out, "a", "b", base, reordered); err != nil || changed {
+		t.Errorf("reordered pool: changed=%v err=%v:\n%s", changed, err, out.String())
+	}
+
+	changed := strings.NewReplacer(
+		".field private static answer I", ".field public static answer I\n.field static extra J",
+		"iconst_2", "iconst_3",
+		"two words", "three words",
+	).Replace(source + extra)
+
+	out.Reset()
+	if changed, err := writeDiff(&out, "a", "b", base, summary(changed)); err != nil || !changed {
+		t.Fatalf("changed=%v err=%v", changed, err)
+	}
+
+	want := []string{
+		"--- a",
+		"+++ b",
+		"~ field answer I",
+		"  flags private static -> public static",
+		"+ field extra J",
+		"~ method divide(II)I",
+		"  - ldc String two words",
+		"  + ldc String three words",
+		"~ method twice(I)I",
+		"    iload_0",
+		"  - iconst_2",
+		"  + iconst_3",
+	}
+	if got := strings.Split(strings.TrimSpace(out.String()), "\n"); !slices.Equal(got[:3], want[:3]) {
+		t.Errorf("unexpected start of diff:\n%s", out.String())
+	}
+	for _, line := range want {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("diff lacks %q:\n%s", line, out.String())
+		}
+	}
+
+	for _, tc := range []struct {
+		a, b []string // methods whose code could not be parsed
+		want string
+	}{
+		{nil, []string{"twice"}, "~ method twice(I)I\n  code could not be parsed in b\n"},
+		{[]string{"twice"}, nil, "~ method twice(I)I\n  code could not be parsed in a\n"},
+		{[]string{"twice"}, []string{"twice"}, "~ method twice(I)I\n  code could not be parsed in a\n  code could not be parsed in b\n"},
+	} {
+		out.Reset()
+		if changed, err := writeDiff(&out, "a", "b", summary(source+extra, tc.a...), summary(source+extra, tc.b...)); err != nil || !changed {
+			t.Fatalf("unparsable in %v and %v: changed=%v err=%v", tc.a, tc.b, changed, err)
+		}
+		if !strings.HasSuffix(out.String(), tc.want) || strings.Contains(out.String(), "code removed") {
+			t.Errorf("unparsable in %v and %v: diff lacks %q:\n%s", tc.a, tc.b, tc.want, out.String())
+		}
+	}
+
+	loop := `
+.method public static count(I)I
+  .limit stack 1
+  .limit locals 1
+L0:
+  iload_0
+  ifeq L9
+  iinc 0 -1
+  goto L0
+L9:
+  iload_0
+  ireturn
+.end method
+`
+	// One instruction more at the start of each method moves every branch
+	// target and handler, but no label
+	inserted := strings.NewReplacer("L0:\n", "L0:\n  iinc 0 0\n").Replace(source + loop)
+
+	out.Reset()
+	if changed, err := writeDiff(&out, "a", "b", summary(source+loop), summary(inserted)); err != nil || !changed {
+		t.Fatalf("changed=%v err=%v", changed, err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if strings.HasPrefix(line, "  - ") || (strings.HasPrefix(line, "  + ") && line != "  + iinc 0, 0") {
+			t.Errorf("diff of an insertion has %q:\n%s", line, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "    L0:\n  + iinc 0, 0\n") {
+		t.Errorf("diff lacks the insertion:\n%s", out.String())
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := strings.Fields("a b c d e f g h i")
+	b := strings.Fields("a x c d e f g h j i")
+
+	want := []string{"  a", "- b", "+ x", "  c", "  d", "@@", "  g", "  h", "+ j", "  i"}
+	if got := diffLines(a, b, 2); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := diffLines(a, a, 2); len(got) != 0 {
+		t.Errorf("equal lines differ: %q", got)
+	}
+
+}
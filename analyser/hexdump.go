@@ -0,0 +1,355 @@
+package analyser
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// label names the structure starting at offset, nested depth levels deep.
+type label struct {
+	offset int
+	depth  int
+	text   string
+}
+
+// hexdump annotates the bytes of a class file with the structures they hold.
+// The parser only keeps the offsets of attributes and code, so the structures
+// in between are found by walking the bytes, and the handles of the parsed
+// class are matched against the attributes found on the way.
+type hexdump struct {
+	*javap
+	bytes  []byte
+	labels []label
+}
+
+// writeHexdump writes b as rows of offset, hex and ASCII, each structure
+// preceded by a line naming it. class and resolve are the result of parsing
+// b; bytes past the point where the structure no longer makes sense are
+// dumped without labels.
+func writeHexdump(w io.Writer, b []byte, class *data.Class, resolve data.Resolver) error {
+	h := &hexdump{javap: newJavap(io.Discard, class, resolve, false), bytes: b}
+
+	if err := h.walk(); err != nil {
+		h.mark(h.last(), 0, "unlabelled: %v", err)
+	}
+
+	out := bufio.NewWriter(w)
+	h.print(out)
+	return out.Flush()
+}
+
+func (h *hexdump) mark(offset, depth int, format string, args ...any) {
+	h.labels = append(h.labels, label{offset: offset, depth: depth, text: fmt.Sprintf(format, args...)})
+}
+
+// last is the offset of the last label.
+func (h *hexdump) last() int {
+	if len(h.labels) == 0 {
+		return 0
+	}
+	return h.labels[len(h.labels)-1].offset
+}
+
+func (h *hexdump) u1(off int) (int, error) {
+	if off < 0 || off+1 > len(h.bytes) {
+		return 0, fmt.Errorf("truncated at %d", off)
+	}
+	return int(h.bytes[off]), nil
+}
+
+func (h *hexdump) u2(off int) (int, error) {
+	if off < 0 || off+2 > len(h.bytes) {
+		return 0, fmt.Errorf("truncated at %d", off)
+	}
+	return int(binary.BigEndian.Uint16(h.bytes[off:])), nil
+}
+
+func (h *hexdump) u4(off int) (int, error) {
+	if off < 0 || off+4 > len(h.bytes) {
+		return 0, fmt.Errorf("truncated at %d", off)
+	}
+	return int(binary.BigEndian.Uint32(h.bytes[off:])), nil
+}
+
+func (h *hexdump) walk() error {
+	h.mark(0, 0, "magic")
+	h.mark(4, 0, "minor_version, major_version %s", h.class.Version)
+
+	off, err := h.constantPool(8)
+	if err != nil {
+		return err
+	}
+
+	h.mark(off, 0, "access_flags %s", flags(h.class.AccessFlags, data.CLASS_FLAGS))
+	h.mark(off+2, 0, "this_class %s", h.describe(h.class.ThisClass))
+	if h.class.SuperClass != nil {
+		h.mark(off+4, 0, "super_class %s", h.describe(h.class.SuperClass))
+	} else {
+		h.mark(off+4, 0, "super_class none")
+	}
+	off += 6
+
+	n, err := h.u2(off)
+	if err != nil {
+		return err
+	}
+	h.mark(off, 0, "interfaces_count %d", n)
+	for i, c := range h.class.Interfaces {
+		h.mark(off+2+2*i, 1, "interface %s", h.describe(c))
+	}
+	off += 2 + 2*n
+
+	if off, err = h.members("field", h.class.Fields, off); err != nil {
+		return err
+	}
+
+	if off, err = h.members("method", h.class.Methods, off); err != nil {
+		return err
+	}
+
+	if off, err = h.attributes("class", h.class.Attributes, nil, off, 0); err != nil {
+		return err
+	}
+
+	if off < len(h.bytes) {
+		h.mark(off, 0, "trailing bytes")
+	}
+	h.mark(len(h.bytes), 0, "end of file")
+
+	return nil
+}
+
+func (h *hexdump) constantPool(off int) (int, error) {
+	n, err := h.u2(off)
+	if err != nil {
+		return 0, err
+	}
+	h.mark(off, 0, "constant_pool_count %d", n)
+	off += 2
+
+	for i := 1; i < n; i++ {
+		tag, err := h.u1(off)
+		if err != nil {
+			return 0, err
+		}
+
+		var size int
+		switch tag {
+		case 1: // Utf8
+			length, err := h.u2(off + 1)
+			if err != nil {
+				return 0, err
+			}
+			size = 3 + length
+		case 7, 8, 16, 19, 20: // Class, String, MethodType, Module, Package
+			size = 3
+		case 15: // MethodHandle
+			size = 4
+		case 3, 4, 9, 10, 11, 12, 17, 18:
+			size = 5
+		case 5, 6: // Long, Double
+			size = 9
+		default:
+			return 0, fmt.Errorf("unknown constant tag %d at %d", tag, off)
+		}
+
+		var c data.Data
+		if i <= len(h.class.ConstantPool) {
+			c = h.class.ConstantPool[i-1]
+		}
+
+		switch {
+		case c == nil:
+			h.mark(off, 1, "constant pool #%d tag %d", i, tag)
+		case c.Tag() == data.CP_UTF8:
+			h.mark(off, 1, "constant pool #%d Utf8 %s", i, strconv.Quote(c.ConstantUtf8().Value))
+		default:
+			h.mark(off, 1, "constant pool #%d %s %s", i, strings.TrimPrefix(c.Tag().String(), "Constant"), h.describe(c))
+		}
+
+		off += size
+		if tag == 5 || tag == 6 {
+			i++
+		}
+	}
+
+	return off, nil
+}
+
+func (h *hexdump) members(kind string, members []data.MemberInfo, off int) (int, error) {
+	n, err := h.u2(off)
+	if err != nil {
+		return 0, err
+	}
+	h.mark(off, 0, "%ss_count %d", kind, n)
+	off += 2
+
+	for i := range n {
+		owner := fmt.Sprintf("%s[%d]", kind, i)
+
+		var attrs map[data.Tag]*data.AttributeHandle
+		if i < len(members) {
+			m := members[i]
+			attrs = m.Attributes
+			h.mark(off, 1, "%s %s %s", owner, m.Name.Value, m.Descriptor.Value)
+		} else {
+			h.mark(off, 1, "%s", owner)
+		}
+
+		if off, err = h.attributes(owner, attrs, nil, off+6, 1); err != nil {
+			return 0, err
+		}
+	}
+
+	return off, nil
+}
+
+// attributes labels the attributes count at off and the attributes following
+// it. Attributes are matched by their Begin to attrs, or to list in code.
+func (h *hexdump) attributes(owner string, attrs map[data.Tag]*data.AttributeHandle, list []data.AttributeHandle, off, depth int) (int, error) {
+	n, err := h.u2(off)
+	if err != nil {
+		return 0, err
+	}
+	h.mark(off, depth, "%s.attributes_count %d", owner, n)
+	off += 2
+
+	handles := make(map[int]*data.AttributeHandle)
+	for _, a := range attrs {
+		handles[int(a.Begin)] = a
+	}
+	for i := range list {
+		handles[int(list[i].Begin)] = &list[i]
+	}
+
+	for i := range n {
+		index, err := h.u2(off)
+		if err != nil {
+			return 0, err
+		}
+
+		length, err := h.u4(off + 2)
+		if err != nil {
+			return 0, err
+		}
+
+		name := "#" + strconv.Itoa(index)
+		if index > 0 && index <= len(h.class.ConstantPool) {
+			if c := h.class.ConstantPool[index-1]; c != nil && c.Tag() == data.CP_UTF8 {
+				name = c.ConstantUtf8().Value
+			}
+		}
+
+		h.mark(off, depth+1, "%s.attributes[%d] %s, length %d", owner, i, name, length)
+		off += 6
+
+		if handle, ok := handles[off]; ok && handle.AttributeTag == data.ATTR_CODE {
+			if err := h.code(fmt.Sprintf("%s.attributes[%d]", owner, i), handle, depth+2); err != nil {
+				h.mark(off, depth+2, "unparsable: %v", err)
+			}
+		}
+
+		off += length
+	}
+
+	return off, nil
+}
+
+func (h *hexdump) code(owner string, handle *data.AttributeHandle, depth int) error {
+	attr, err := h.resolve(handle)
+	if err != nil {
+		return err
+	}
+	code := attr.AttributeCode()
+
+	off := int(handle.Begin)
+	h.mark(off, depth, "max_stack %d, max_locals %d, code_length %d", code.MaxStack, code.MaxLocals, code.CodeHandle.Length)
+
+	bc, err := h.resolve(&code.CodeHandle)
+	if err != nil {
+		return err
+	}
+
+	pc := 0
+	for _, op := range bc.Bytecode().Ops {
+		h.mark(int(code.CodeHandle.Begin)+pc, depth+1, "pc %d: %s", pc, h.instructionLabel(pc, op))
+		pc += 1 + len(op.Arg)
+	}
+
+	off = int(code.CodeHandle.Begin) + int(code.CodeHandle.Length)
+	h.mark(off, depth, "exception_table_length %d", len(code.ExceptionTable))
+	off += 2
+
+	for i, e := range code.ExceptionTable {
+		catch := "any"
+		if e.CatchType != nil {
+			catch = h.internalName(e.CatchType)
+		}
+		h.mark(off, depth+1, "exception_table[%d] %d..%d -> %d %s", i, e.StartPC, e.EndPC, e.HandlerPC, catch)
+		off += 8
+	}
+
+	_, err = h.attributes(owner, nil, code.Attributes, off, depth)
+	return err
+}
+
+// instructionLabel is op with its operands, branches relative to the
+// instruction as they are encoded.
+func (h *hexdump) instructionLabel(pc int, op data.Op) string {
+	in := instructionJSON(pc, op)
+
+	switch {
+	case in.Constant != nil:
+		return fmt.Sprintf("%s #%d", in.Opcode, *in.Constant)
+	case in.Target != nil:
+		return fmt.Sprintf("%s %+d", in.Opcode, *in.Target-pc)
+	}
+
+	words := []string{in.Opcode}
+	for _, v := range in.Operands {
+		words = append(words, strconv.Itoa(v))
+	}
+	return strings.Join(words, " ")
+}
+
+func (h *hexdump) print(out io.Writer) {
+	slices.SortStableFunc(h.labels, func(a, b label) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	for i, l := range h.labels {
+		fmt.Fprintf(out, "%s%s\n", strings.Repeat("  ", l.depth), l.text)
+
+		end := len(h.bytes)
+		if i+1 < len(h.labels) {
+			end = min(h.labels[i+1].offset, end)
+		}
+
+		for row := l.offset; row < end; row += 16 {
+			h.row(out, row, min(row+16, end), l.depth)
+		}
+	}
+}
+
+// row prints the bytes from begin to end, at most 16.
+func (h *hexdump) row(out io.Writer, begin, end, depth int) {
+	var hex, ascii strings.Builder
+	for _, b := range h.bytes[begin:end] {
+		fmt.Fprintf(&hex, "%02x ", b)
+		if b >= 0x20 && b < 0x7f {
+			ascii.WriteByte(b)
+		} else {
+			ascii.WriteByte('.')
+		}
+	}
+
+	fmt.Fprintf(out, "%s  %08x  %-48s |%s|\n", strings.Repeat("  ", depth), begin, hex.String(), ascii.String())
+}
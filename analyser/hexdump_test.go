@@ -0,0 +1,84 @@
+package analyser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+	"github.com/luishfonseca/dtu_pa/parser"
+	"github.com/luishfonseca/dtu_pa/writer"
+)
+
+func TestHexdump(t *testing.T) {
+	class, resolve, err := assembler.Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := writer.Bytes(class, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := parser.OpenReader(context.Background(), bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if class, err = s.Class(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{len(b), len(b) - 5} {
+		var out strings.Builder
+		if err := writeHexdump(&out, b[:n], class, s.Request); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			"magic",
+			"  00000000  ca fe ba be                                      |....|",
+			"  constant pool #12 String two words",
+			"  method[0] divide (II)I",
+			"    method[0].attributes[0] Code, length ",
+			"        pc 2: idiv",
+			"        pc 9: invokestatic #18",
+			"        exception_table[0] 0..4 -> 4 java/lang/ArithmeticException",
+			"      method[0].attributes[0].attributes[0] LineNumberTable, length 6",
+		}
+		if n == len(b) {
+			want = append(want, "class.attributes_count 1", "end of file")
+		} else {
+			want = append(want, "unlabelled: truncated at ")
+		}
+
+		for _, line := range want {
+			if !strings.Contains(out.String(), line) {
+				t.Errorf("%d bytes: output lacks %q:\n%s", n, line, out.String())
+			}
+		}
+
+		// Every byte is dumped once, in order
+		var dumped []byte
+		for _, line := range strings.Split(out.String(), "\n") {
+			hex, _, ok := strings.Cut(line, " |")
+			if !ok {
+				continue
+			}
+			for _, field := range strings.Fields(hex)[1:] {
+				var v byte
+				if _, err := fmt.Sscanf(field, "%02x", &v); err != nil {
+					t.Fatalf("row %q: %v", line, err)
+				}
+				dumped = append(dumped, v)
+			}
+		}
+		if !bytes.Equal(dumped, b[:n]) {
+			t.Errorf("%d bytes: dumped %d different bytes", n, len(dumped))
+		}
+	}
+}
@@ -0,0 +1,680 @@
+package analyser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// javap prints a class in the layout of the JDK's javap -c, or javap -c -v
+// when verbose.
+type javap struct {
+	out     *bufio.Writer
+	class   *data.Class
+	resolve data.Resolver
+	verbose bool
+	indices map[data.Data]int
+
+	selection Selection
+}
+
+func newJavap(w io.Writer, class *data.Class, resolve data.Resolver, verbose bool) *javap {
+	j := &javap{
+		out:     bufio.NewWriter(w),
+		class:   class,
+		resolve: resolve,
+		verbose: verbose,
+		indices: make(map[data.Data]int),
+	}
+
+	for i, c := range class.ConstantPool {
+		if _, ok := j.indices[c]; c != nil && !ok {
+			j.indices[c] = i + 1
+		}
+	}
+
+	return j
+}
+
+func (j *javap) printf(format string, args ...any) {
+	fmt.Fprintf(j.out, format, args...)
+}
+
+// commented pads text so that comments line up, as javap does.
+func (j *javap) commented(indent, text, comment string) {
+	if comment == "" {
+		j.printf("%s%s\n", indent, text)
+		return
+	}
+	j.printf("%s%-40s// %s\n", indent, text, comment)
+}
+
+// ref is the index of c as javap writes it, e.g. "#3".
+func (j *javap) ref(c data.Data) string {
+	if i, ok := j.indices[c]; ok {
+		return "#" + strconv.Itoa(i)
+	}
+	return "#?"
+}
+
+func (j *javap) slot(r *data.Data) data.Data {
+	if r == nil {
+		return nil
+	}
+	return *r
+}
+
+// describe a constant as javap does in its comments, e.g.
+// java/lang/Object."<init>":()V for a method reference.
+func (j *javap) describe(c data.Data) string {
+	if c == nil {
+		return "?"
+	}
+
+	switch c.Tag() {
+	case data.CP_UTF8:
+		return c.ConstantUtf8().Value
+	case data.CP_INTEGER:
+		return strconv.Itoa(int(c.ConstantInteger().Value))
+	case data.CP_FLOAT:
+		return strconv.FormatFloat(float64(c.ConstantFloat().Value), 'g', -1, 32) + "f"
+	case data.CP_LONG:
+		return strconv.FormatInt(c.ConstantLong().Value, 10) + "l"
+	case data.CP_DOUBLE:
+		return strconv.FormatFloat(c.ConstantDouble().Value, 'g', -1, 64) + "d"
+	case data.CP_CLASS:
+		name := j.describe(j.slot(c.ConstantClass().Name))
+		if strings.HasPrefix(name, "[") {
+			return strconv.Quote(name)
+		}
+		return name
+	case data.CP_STRING:
+		return escape(j.describe(j.slot(c.ConstantString().Value)))
+	case data.CP_NAME_AND_TYPE:
+		nat := c.ConstantNameAndType()
+		name := j.describe(j.slot(nat.Name))
+		if strings.HasPrefix(name, "<") {
+			name = strconv.Quote(name)
+		}
+		return name + ":" + j.describe(j.slot(nat.Descriptor))
+	case data.CP_FIELDREF:
+		return j.member(c.ConstantFieldref().Clazz, c.ConstantFieldref().NameAndType, false)
+	case data.CP_METHODREF:
+		return j.member(c.ConstantMethodref().Clazz, c.ConstantMethodref().NameAndType, false)
+	case data.CP_INTERFACE_METHODREF:
+		return j.member(c.ConstantInterfaceMethodref().Clazz, c.ConstantInterfaceMethodref().NameAndType, false)
+	default:
+		return c.Tag().String()
+	}
+}
+
+// member describes a member reference, leaving out the class when it is the
+// one being printed and short is set, as javap does for instructions.
+func (j *javap) member(class, nameAndType *data.Data, short bool) string {
+	nat := j.describe(j.slot(nameAndType))
+	if short && j.slot(class) == data.Data(j.class.ThisClass) {
+		return nat
+	}
+	return j.describe(j.slot(class)) + "." + nat
+}
+
+// escape a string constant as javap does.
+func escape(s string) string {
+	q := strconv.Quote(s)
+	return strings.ReplaceAll(q[1:len(q)-1], `\"`, `"`)
+}
+
+func (j *javap) print(header []string) error {
+	for _, line := range header {
+		j.printf("%s\n", line)
+	}
+
+	var attrs []data.Data
+	for _, h := range data.SortedHandles(j.class.Attributes) {
+		attr, err := j.resolve(h)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, attr)
+
+		if attr.Tag() == data.ATTR_SOURCE_FILE {
+			indent := ""
+			if j.verbose {
+				indent = "  "
+			}
+			j.printf("%sCompiled from %q\n", indent, attr.AttributeSourceFile().SourceFile.Value)
+		}
+	}
+
+	selected, err := j.selection.apply(j.class)
+	if err != nil {
+		return err
+	}
+
+	j.printf("%s", j.declaration())
+	switch {
+	case !j.verbose && !j.selection.constants(false):
+		j.printf(" {\n")
+	case !j.verbose:
+		j.printf("\n")
+		j.printConstantPool()
+		j.printf("{\n")
+	default:
+		j.printf("\n")
+		if err := j.printClassInfo(); err != nil {
+			return err
+		}
+		j.printf("{\n")
+	}
+
+	first := true
+	for _, members := range [][]data.MemberInfo{selected.Fields, selected.Methods} {
+		for _, m := range members {
+			if !first {
+				j.printf("\n")
+			}
+			first = false
+
+			if err := j.printMember(m); err != nil {
+				return fmt.Errorf("%s %s: %w", m.MemberType, m.Name.Value, err)
+			}
+		}
+	}
+
+	j.printf("}\n")
+
+	if j.verbose {
+		for _, attr := range attrs {
+			if err := j.printAttribute("", attr); err != nil {
+				return err
+			}
+		}
+	}
+
+	return j.out.Flush()
+}
+
+// className in Java source form, e.g. jpamb.cases.Simple.
+func (j *javap) className(c *data.ConstantClass) string {
+	if c == nil {
+		return "?"
+	}
+	return strings.ReplaceAll(j.describe(j.slot(c.Name)), "/", ".")
+}
+
+// modifiers among the keywords of flags that javap shows in declarations.
+func modifiers(flags data.AccessFlags, of data.FlagsOf) []string {
+	var mods []string
+	for _, k := range flags.Keywords(of) {
+		switch k {
+		case "public", "private", "protected", "static", "final", "synchronized", "volatile", "transient", "native", "abstract":
+			mods = append(mods, k)
+		}
+	}
+	return mods
+}
+
+func (j *javap) declaration() string {
+	mods := modifiers(j.class.AccessFlags, data.CLASS_FLAGS)
+
+	kind := "class"
+	if j.class.AccessFlags&0x0200 != 0 {
+		kind = "interface"
+		mods = slices.DeleteFunc(mods, func(m string) bool { return m == "abstract" })
+	}
+
+	decl := strings.Join(append(mods, kind, j.className(j.class.ThisClass)), " ")
+	if super := j.className(j.class.SuperClass); j.class.SuperClass != nil && super != "java.lang.Object" {
+		decl += " extends " + super
+	}
+
+	var ifaces []string
+	for _, c := range j.class.Interfaces {
+		ifaces = append(ifaces, j.className(c))
+	}
+	if len(ifaces) > 0 && kind == "interface" {
+		decl += " extends " + strings.Join(ifaces, ", ")
+	} else if len(ifaces) > 0 {
+		decl += " implements " + strings.Join(ifaces, ", ")
+	}
+
+	return decl
+}
+
+// flags as javap lists them, e.g. "(0x0021) ACC_PUBLIC, ACC_SUPER".
+func flags(af data.AccessFlags, of data.FlagsOf) string {
+	names := af.Keywords(of)
+	for i, k := range names {
+		if !strings.HasPrefix(k, "0x") {
+			names[i] = "ACC_" + strings.ToUpper(k)
+		}
+	}
+	return fmt.Sprintf("(0x%04x) %s", uint16(af), strings.Join(names, ", "))
+}
+
+func (j *javap) printClassInfo() error {
+	var major, minor int
+	if _, err := fmt.Sscanf(j.class.Version, "%d.%d", &major, &minor); err != nil {
+		return fmt.Errorf("invalid version %q", j.class.Version)
+	}
+
+	j.printf("  minor version: %d\n", minor)
+	j.printf("  major version: %d\n", major)
+	j.printf("  flags: %s\n", flags(j.class.AccessFlags, data.CLASS_FLAGS))
+	j.commented("  ", "this_class: "+j.ref(j.class.ThisClass), j.describe(j.class.ThisClass))
+	if j.class.SuperClass != nil {
+		j.commented("  ", "super_class: "+j.ref(j.class.SuperClass), j.describe(j.class.SuperClass))
+	} else {
+		j.printf("  super_class: #0\n")
+	}
+	j.printf("  interfaces: %d, fields: %d, methods: %d, attributes: %d\n", len(j.class.Interfaces), len(j.class.Fields), len(j.class.Methods), len(j.class.Attributes))
+
+	if j.selection.constants(true) {
+		j.printConstantPool()
+	}
+
+	return nil
+}
+
+func (j *javap) printConstantPool() {
+	j.printf("Constant pool:\n")
+	width := len(strconv.Itoa(len(j.class.ConstantPool))) + 3
+	for i, c := range j.class.ConstantPool {
+		if c == nil {
+			continue
+		}
+
+		var refs []string
+		for _, r := range data.References(c) {
+			refs = append(refs, j.ref(j.slot(r)))
+		}
+
+		kind := strings.TrimPrefix(c.Tag().String(), "Constant")
+		switch {
+		case c.Tag() == data.CP_NAME_AND_TYPE:
+			j.printf("%*s = %-18s %-14s // %s\n", width, "#"+strconv.Itoa(i+1), kind, strings.Join(refs, ":"), j.describe(c))
+		case len(refs) > 0:
+			j.printf("%*s = %-18s %-14s // %s\n", width, "#"+strconv.Itoa(i+1), kind, strings.Join(refs, "."), j.describe(c))
+		default:
+			j.printf("%*s = %-18s %s\n", width, "#"+strconv.Itoa(i+1), kind, j.describe(c))
+		}
+	}
+}
+
+func (j *javap) printMember(m data.MemberInfo) error {
+	if m.MemberType == data.FIELD {
+		mods := modifiers(m.AccessFlags, data.FIELD_FLAGS)
+		j.printf("  %s;\n", strings.Join(append(mods, data.JavaType(m.Descriptor.Value), m.Name.Value), " "))
+
+		if j.verbose {
+			j.printf("    descriptor: %s\n", m.Descriptor.Value)
+			j.printf("    flags: %s\n", flags(m.AccessFlags, data.FIELD_FLAGS))
+		}
+	} else {
+		params, ret, err := data.SplitMethodDescriptor(m.Descriptor.Value)
+		if err != nil {
+			return err
+		}
+
+		types := make([]string, len(params))
+		for i, p := range params {
+			types[i] = data.JavaType(p)
+		}
+
+		mods := modifiers(m.AccessFlags, data.METHOD_FLAGS)
+		switch m.Name.Value {
+		case "<clinit>":
+			j.printf("  static {};\n")
+		case "<init>":
+			j.printf("  %s(%s);\n", strings.Join(append(mods, j.className(j.class.ThisClass)), " "), strings.Join(types, ", "))
+		default:
+			j.printf("  %s(%s);\n", strings.Join(append(mods, data.JavaType(ret), m.Name.Value), " "), strings.Join(types, ", "))
+		}
+
+		if j.verbose {
+			j.printf("    descriptor: %s\n", m.Descriptor.Value)
+			j.printf("    flags: %s\n", flags(m.AccessFlags, data.METHOD_FLAGS))
+		}
+	}
+
+	for _, h := range data.SortedHandles(m.Attributes) {
+		if h.AttributeTag == data.ATTR_CODE && j.selection.NoCode {
+			continue
+		}
+
+		if h.AttributeTag == data.ATTR_CODE && m.Unparsable {
+			j.printf("    Code: (unparsable)\n")
+			continue
+		}
+
+		if h.AttributeTag != data.ATTR_CODE && !j.verbose {
+			continue
+		}
+
+		attr, err := j.resolve(h)
+		if err != nil {
+			return err
+		}
+
+		if attr.Tag() == data.ATTR_CODE {
+			err = j.printCode(m, attr.AttributeCode())
+		} else {
+			err = j.printAttribute("    ", attr)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (j *javap) printCode(m data.MemberInfo, code *data.AttributeCode) error {
+	j.printf("    Code:\n")
+
+	indent := "    "
+	if j.verbose {
+		params, _, err := data.SplitMethodDescriptor(m.Descriptor.Value)
+		if err != nil {
+			return err
+		}
+
+		args := 0
+		if m.AccessFlags&0x0008 == 0 {
+			args++ // this
+		}
+		for _, p := range params {
+			args += data.Slots(p)
+		}
+
+		j.printf("      stack=%d, locals=%d, args_size=%d\n", code.MaxStack, code.MaxLocals, args)
+		indent = "      "
+	}
+
+	d, err := j.resolve(&code.CodeHandle)
+	if err != nil {
+		return err
+	}
+
+	pc := 0
+	for _, op := range d.Bytecode().Ops {
+		text, comment := j.instruction(pc, op)
+		j.commented(indent, text, comment)
+		pc += 1 + len(op.Arg)
+	}
+
+	if len(code.ExceptionTable) > 0 {
+		j.printf("      Exception table:\n")
+		j.printf("         from    to  target type\n")
+		for _, e := range code.ExceptionTable {
+			catch := "any"
+			if e.CatchType != nil {
+				catch = "Class " + j.describe(e.CatchType)
+			}
+			j.printf("        %6d %5d %5d   %s\n", e.StartPC, e.EndPC, e.HandlerPC, catch)
+		}
+	}
+
+	if !j.verbose {
+		return nil
+	}
+
+	for _, h := range code.Attributes {
+		attr, err := j.resolve(&h)
+		if err != nil {
+			return err
+		}
+
+		if err := j.printAttribute("      ", attr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var arrayTypes = []string{4: "boolean", "char", "float", "double", "byte", "short", "int", "long"}
+
+// instruction at pc as javap prints it, and the comment resolving its
+// constant operand if it has one.
+func (j *javap) instruction(pc int, op data.Op) (string, string) {
+	prefix := fmt.Sprintf("%4d: ", pc)
+	if len(op.Arg) == 0 {
+		return prefix + op.Code.String(), ""
+	}
+
+	mnemonic := fmt.Sprintf("%-13s ", op.Code)
+
+	if i, ok := op.ConstantIndex(); ok {
+		var c data.Data
+		if i > 0 && int(i) <= len(j.class.ConstantPool) {
+			c = j.class.ConstantPool[i-1]
+		}
+
+		var comment string
+		switch {
+		case c == nil:
+			comment = "invalid constant"
+		case op.Code == data.OP_LDC:
+			kind := strings.ToLower(strings.TrimPrefix(c.Tag().String(), "Constant"))
+			switch c.Tag() {
+			case data.CP_INTEGER:
+				kind = "int"
+			case data.CP_STRING:
+				kind = "String"
+			}
+			comment = kind + " " + j.describe(c)
+		case c.Tag() == data.CP_FIELDREF:
+			comment = "Field " + j.member(c.ConstantFieldref().Clazz, c.ConstantFieldref().NameAndType, true)
+		case c.Tag() == data.CP_METHODREF:
+			comment = "Method " + j.member(c.ConstantMethodref().Clazz, c.ConstantMethodref().NameAndType, true)
+		case c.Tag() == data.CP_INTERFACE_METHODREF:
+			comment = "InterfaceMethod " + j.member(c.ConstantInterfaceMethodref().Clazz, c.ConstantInterfaceMethodref().NameAndType, true)
+		case c.Tag() == data.CP_CLASS:
+			comment = "class " + j.describe(c)
+		default:
+			comment = j.describe(c)
+		}
+
+		return prefix + mnemonic + fmt.Sprintf("#%d", i), comment
+	}
+
+	var operand string
+	switch op.Code {
+	case data.OP_BIPUSH:
+		operand = strconv.Itoa(int(int8(op.Arg[0])))
+	case data.OP_SIPUSH:
+		operand = strconv.Itoa(int(int16(uint16(op.Arg[0])<<8 | uint16(op.Arg[1]))))
+	case data.OP_IINC:
+		operand = fmt.Sprintf("%d, %d", op.Arg[0], int8(op.Arg[1]))
+	case data.OP_NEWARRAY:
+		operand = strconv.Itoa(int(op.Arg[0]))
+		if int(op.Arg[0]) < len(arrayTypes) && arrayTypes[op.Arg[0]] != "" {
+			operand = arrayTypes[op.Arg[0]]
+		}
+	default:
+		if offset, ok := op.BranchOffset(); ok {
+			operand = strconv.Itoa(pc + offset)
+		} else {
+			operand = strconv.Itoa(int(op.Arg[0]))
+		}
+	}
+
+	return prefix + mnemonic + operand, ""
+}
+
+// verificationType as javap lists it in stack map frames.
+func (j *javap) verificationType(vt data.VerificationType) string {
+	switch vt.Tag {
+	case data.VT_TOP:
+		return "top"
+	case data.VT_INTEGER:
+		return "int"
+	case data.VT_FLOAT:
+		return "float"
+	case data.VT_DOUBLE:
+		return "double"
+	case data.VT_LONG:
+		return "long"
+	case data.VT_NULL:
+		return "null"
+	case data.VT_UNINITIALIZED_THIS:
+		return "uninitialized_this"
+	case data.VT_OBJECT:
+		return "class " + j.describe(vt.Class)
+	case data.VT_UNINITIALIZED:
+		return fmt.Sprintf("uninitialized %d", vt.Offset)
+	default:
+		return fmt.Sprintf("unknown %d", vt.Tag)
+	}
+}
+
+func (j *javap) verificationTypes(types []data.VerificationType) string {
+	names := make([]string, len(types))
+	for i, vt := range types {
+		names[i] = j.verificationType(vt)
+	}
+	return "[ " + strings.Join(names, ", ") + " ]"
+}
+
+// printAttribute in verbose mode, with the attribute name at indent.
+func (j *javap) printAttribute(indent string, attr data.Data) error {
+	switch attr.Tag() {
+	case data.ATTR_SOURCE_FILE:
+		j.printf("%sSourceFile: %q\n", indent, attr.AttributeSourceFile().SourceFile.Value)
+	case data.ATTR_LINE_NUMBER_TABLE:
+		j.printf("%sLineNumberTable:\n", indent)
+		for _, ln := range attr.AttributeLineNumberTable().LineNumbers {
+			j.printf("%s  line %d: %d\n", indent, ln.LineNumber, ln.StartPC)
+		}
+	case data.ATTR_LOCAL_VARIABLE_TABLE:
+		j.printf("%sLocalVariableTable:\n", indent)
+		j.printf("%s  Start  Length  Slot  Name   Signature\n", indent)
+		for _, lv := range attr.AttributeLocalVariableTable().LocalVariables {
+			j.printf("%s  %5d %7d %5d %5s   %s\n", indent, lv.StartPC, lv.Length, lv.Index, lv.Name.Value, lv.Descriptor.Value)
+		}
+	case data.ATTR_STACK_MAP_TABLE:
+		frames := attr.AttributeStackMapTable().Frames
+		j.printf("%sStackMapTable: number_of_entries = %d\n", indent, len(frames))
+		for _, f := range frames {
+			switch t := f.FrameType; {
+			case t <= 63:
+				j.printf("%s  frame_type = %d /* same */\n", indent, t)
+			case t <= 127:
+				j.printf("%s  frame_type = %d /* same_locals_1_stack_item */\n", indent, t)
+				j.printf("%s    stack = %s\n", indent, j.verificationTypes(f.Stack))
+			case t == 247:
+				j.printf("%s  frame_type = %d /* same_locals_1_stack_item_frame_extended */\n", indent, t)
+				j.printf("%s    offset_delta = %d\n", indent, f.OffsetDelta)
+				j.printf("%s    stack = %s\n", indent, j.verificationTypes(f.Stack))
+			case t <= 250:
+				j.printf("%s  frame_type = %d /* chop */\n", indent, t)
+				j.printf("%s    offset_delta = %d\n", indent, f.OffsetDelta)
+			case t == 251:
+				j.printf("%s  frame_type = %d /* same_frame_extended */\n", indent, t)
+				j.printf("%s    offset_delta = %d\n", indent, f.OffsetDelta)
+			case t <= 254:
+				j.printf("%s  frame_type = %d /* append */\n", indent, t)
+				j.printf("%s    offset_delta = %d\n", indent, f.OffsetDelta)
+				j.printf("%s    locals = %s\n", indent, j.verificationTypes(f.Locals))
+			default:
+				j.printf("%s  frame_type = %d /* full_frame */\n", indent, t)
+				j.printf("%s    offset_delta = %d\n", indent, f.OffsetDelta)
+				j.printf("%s    locals = %s\n", indent, j.verificationTypes(f.Locals))
+				j.printf("%s    stack = %s\n", indent, j.verificationTypes(f.Stack))
+			}
+		}
+	case data.ATTR_INNER_CLASSES:
+		j.printf("%sInnerClasses:\n", indent)
+		for _, c := range attr.AttributeInnerClasses().Classes {
+			// The flags of inner classes name like those of fields, as far as javap shows them
+			mods := strings.Join(modifiers(c.AccessFlags, data.FIELD_FLAGS), " ")
+			if mods != "" {
+				mods += " "
+			}
+
+			text, comment := mods+j.ref(c.InnerClass), "class "+j.describe(c.InnerClass)
+			if c.InnerName != nil {
+				text, comment = mods+j.ref(c.InnerName)+"= "+j.ref(c.InnerClass), c.InnerName.Value+"="+comment
+			}
+			if c.OuterClass != nil {
+				text, comment = text+" of "+j.ref(c.OuterClass), comment+" of class "+j.describe(c.OuterClass)
+			}
+			j.commented(indent+"  ", text+";", comment)
+		}
+	case data.ATTR_RUNTIME_VISIBLE_ANNOTATIONS:
+		j.printf("%sRuntimeVisibleAnnotations:\n", indent)
+		for i, a := range attr.AttributeRuntimeVisibleAnnotations().Annotations {
+			j.printf("%s  %d: %s\n", indent, i, j.annotationRefs(a))
+			j.printf("%s    %s\n", indent, j.annotation(a))
+		}
+	default:
+		j.printf("%s%s: (not shown)\n", indent, attr.Tag())
+	}
+
+	return nil
+}
+
+// annotationRefs in javap's index form, e.g. #18(#19=s#20).
+func (j *javap) annotationRefs(a data.Annotation) string {
+	var elements []string
+	for _, e := range a.Elements {
+		elements = append(elements, j.ref(e.Name)+"="+j.elementRefs(e.Value))
+	}
+	return j.ref(a.Type) + "(" + strings.Join(elements, ",") + ")"
+}
+
+func (j *javap) elementRefs(e data.ElementValue) string {
+	switch e.Tag {
+	case 'e':
+		return "e" + j.ref(e.EnumType) + "." + j.ref(e.EnumName)
+	case 'c':
+		return "c" + j.ref(e.Class)
+	case '@':
+		return "@" + j.annotationRefs(*e.Annotation)
+	case '[':
+		var values []string
+		for _, v := range e.Values {
+			values = append(values, j.elementRefs(v))
+		}
+		return "[" + strings.Join(values, ",") + "]"
+	default:
+		return string(e.Tag) + j.ref(e.Const)
+	}
+}
+
+// annotation in Java source form, e.g. jpamb.utils.Case(value="(0) -> ok").
+func (j *javap) annotation(a data.Annotation) string {
+	var elements []string
+	for _, e := range a.Elements {
+		elements = append(elements, e.Name.Value+"="+j.element(e.Value))
+	}
+	return data.JavaType(a.Type.Value) + "(" + strings.Join(elements, ", ") + ")"
+}
+
+func (j *javap) element(e data.ElementValue) string {
+	switch e.Tag {
+	case 'e':
+		return data.JavaType(e.EnumType.Value) + "." + e.EnumName.Value
+	case 'c':
+		return "class " + strconv.Quote(e.Class.Value)
+	case '@':
+		return "@" + j.annotation(*e.Annotation)
+	case '[':
+		var values []string
+		for _, v := range e.Values {
+			values = append(values, j.element(v))
+		}
+		return "[" + strings.Join(values, ",") + "]"
+	case 's':
+		return strconv.Quote(j.describe(e.Const))
+	default:
+		return j.describe(e.Const)
+	}
+}
@@ -0,0 +1,76 @@
+package analyser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+)
+
+const source = `.class public super jpamb/cases/Example
+.source Example.java
+.field private static answer I
+
+.method public static divide(II)I
+  .limit stack 2
+  .limit locals 2
+  .line 7
+L0:
+  iload_0
+  iload_1
+  idiv
+  ireturn
+L4:
+  getstatic jpamb/cases/Example/answer I
+  ldc "two words"
+  invokestatic java/lang/Integer/parseInt(Ljava/lang/String;)I
+  iadd
+  ireturn
+  .catch java/lang/ArithmeticException from L0 to L4 using L4
+  .var 0 is a I from L0 to L4
+.end method
+`
+
+func TestJavap(t *testing.T) {
+	class, resolve, err := assembler.Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, verbose := range []bool{false, true} {
+		var out strings.Builder
+		if err := newJavap(&out, class, resolve, verbose).print(nil); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			"public class jpamb.cases.Example {",
+			"  private static int answer;",
+			"  public static int divide(int, int);",
+			"       0: iload_0",
+			"       4: getstatic     #10                 // Field answer:I",
+			"       7: ldc           #12                 // String two words",
+			"       9: invokestatic  #18                 // Method java/lang/Integer.parseInt:(Ljava/lang/String;)I",
+			"             0     4     4   Class java/lang/ArithmeticException",
+		}
+		if verbose {
+			want = []string{
+				"  Compiled from \"Example.java\"",
+				"  flags: (0x0021) ACC_PUBLIC, ACC_SUPER",
+				"  #18 = Methodref          #14.#17        // java/lang/Integer.parseInt:(Ljava/lang/String;)I",
+				"    flags: (0x0009) ACC_PUBLIC, ACC_STATIC",
+				"      stack=2, locals=2, args_size=2",
+				"         9: invokestatic  #18                 // Method java/lang/Integer.parseInt:(Ljava/lang/String;)I",
+				"        line 7: 0",
+				"            0       4     0     a   I",
+				"SourceFile: \"Example.java\"",
+			}
+		}
+
+		for _, line := range want {
+			if !strings.Contains(out.String(), line+"\n") {
+				t.Errorf("verbose=%v: output lacks %q:\n%s", verbose, line, out.String())
+			}
+		}
+	}
+}
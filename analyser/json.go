@@ -0,0 +1,551 @@
+package analyser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// The JSON output of inspect --format json is a ClassJSON. Its schema is
+// versioned by the Schema field, which changes only when a field is renamed,
+// removed or changes meaning; new fields may be added without notice.
+//
+// Object keys appear in the order of the fields below and lists keep the
+// order of the class file, so the output of a class is always the same.
+// Constants are referred to by their constant pool index. Optional values are
+// null, and lists that are empty are [] rather than left out. When inspect is
+// asked for some methods, fields or constants, the parts not asked for are
+// empty. Several classes are printed as a stream of objects, one after the
+// other.
+const JSONSchema = 1
+
+type ClassJSON struct {
+	Schema       int             `json:"schema"`
+	Source       string          `json:"source,omitempty"` // file the class was read from, e.g. "lib.jar!pkg/A.class"
+	Major        int             `json:"major_version"`
+	Minor        int             `json:"minor_version"`
+	AccessFlags  FlagsJSON       `json:"access_flags"`
+	ThisClass    string          `json:"this_class"`
+	SuperClass   *string         `json:"super_class"`
+	Interfaces   []string        `json:"interfaces"`
+	ConstantPool []ConstantJSON  `json:"constant_pool"`
+	Fields       []MemberJSON    `json:"fields"`
+	Methods      []MemberJSON    `json:"methods"`
+	Attributes   []AttributeJSON `json:"attributes"`
+}
+
+// FlagsJSON are access flags, both as their value and the keywords naming
+// them, e.g. ["public", "static"]. Bits with no name are left out of Names.
+type FlagsJSON struct {
+	Value uint16   `json:"value"`
+	Names []string `json:"names"`
+}
+
+// ConstantJSON is a constant pool entry. The second slot of longs and doubles
+// is left out, so Index is not always the position in the list.
+//
+// Value holds the contents of Utf8, Integer and String constants, the latter
+// resolved. Floats, longs and doubles are strings, so they survive parsers
+// reading numbers as doubles and can be NaN or infinite ("NaN", "+Inf").
+// Class, Name and Descriptor are resolved for the constants that refer to
+// them, and Refs lists the indices they were resolved through.
+type ConstantJSON struct {
+	Index      int    `json:"index"`
+	Tag        string `json:"tag"` // Utf8, Integer, Float, Long, Double, Class, String, NameAndType, Fieldref, Methodref, InterfaceMethodref
+	Value      any    `json:"value,omitempty"`
+	Class      string `json:"class,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Descriptor string `json:"descriptor,omitempty"`
+	Refs       []int  `json:"refs,omitempty"`
+}
+
+type MemberJSON struct {
+	Name        string          `json:"name"`
+	Descriptor  string          `json:"descriptor"`
+	AccessFlags FlagsJSON       `json:"access_flags"`
+	Code        *CodeJSON       `json:"code"`       // null for fields, methods without code and with --no-code
+	Unparsable  bool            `json:"unparsable"` // lenient parsing could not read the code
+	Attributes  []AttributeJSON `json:"attributes"` // other than Code
+}
+
+type CodeJSON struct {
+	MaxStack       int               `json:"max_stack"`
+	MaxLocals      int               `json:"max_locals"`
+	Instructions   []InstructionJSON `json:"instructions"`
+	ExceptionTable []ExceptionJSON   `json:"exception_table"`
+	Attributes     []AttributeJSON   `json:"attributes"`
+}
+
+// InstructionJSON is an instruction at PC. Operands are its immediate values
+// decoded, e.g. [1, -1] for iinc 1 -1; Constant is the constant pool index it
+// refers to and Target the pc a branch goes to.
+type InstructionJSON struct {
+	PC       int    `json:"pc"`
+	Opcode   string `json:"opcode"`
+	Operands []int  `json:"operands"`
+	Constant *int   `json:"constant"`
+	Target   *int   `json:"target"`
+}
+
+type ExceptionJSON struct {
+	StartPC   int     `json:"start_pc"`
+	EndPC     int     `json:"end_pc"`
+	HandlerPC int     `json:"handler_pc"`
+	CatchType *string `json:"catch_type"` // null catches everything
+}
+
+// AttributeJSON is a decoded attribute. Name says which of the other fields
+// is set: SourceFile, LineNumberTable, LocalVariableTable, InnerClasses,
+// StackMapTable or RuntimeVisibleAnnotations.
+type AttributeJSON struct {
+	Name           string              `json:"name"`
+	SourceFile     string              `json:"source_file,omitempty"`
+	LineNumbers    []LineNumberJSON    `json:"line_numbers,omitempty"`
+	LocalVariables []LocalVariableJSON `json:"local_variables,omitempty"`
+	InnerClasses   []InnerClassJSON    `json:"inner_classes,omitempty"`
+	Frames         []FrameJSON         `json:"frames,omitempty"`
+	Annotations    []AnnotationJSON    `json:"annotations,omitempty"`
+}
+
+type LineNumberJSON struct {
+	StartPC int `json:"start_pc"`
+	Line    int `json:"line"`
+}
+
+type LocalVariableJSON struct {
+	StartPC    int    `json:"start_pc"`
+	Length     int    `json:"length"`
+	Index      int    `json:"index"`
+	Name       string `json:"name"`
+	Descriptor string `json:"descriptor"`
+}
+
+type InnerClassJSON struct {
+	InnerClass  string    `json:"inner_class"`
+	OuterClass  *string   `json:"outer_class"` // null if not a member
+	InnerName   *string   `json:"inner_name"`  // null if anonymous
+	AccessFlags FlagsJSON `json:"access_flags"`
+}
+
+// FrameJSON is a stack map frame, with the verification types of its locals
+// and stack as in javap, e.g. "int" or "class java/lang/String".
+type FrameJSON struct {
+	FrameType   int      `json:"frame_type"`
+	OffsetDelta int      `json:"offset_delta"`
+	Locals      []string `json:"locals"`
+	Stack       []string `json:"stack"`
+}
+
+type AnnotationJSON struct {
+	Type     string             `json:"type"`
+	Elements []ElementValueJSON `json:"elements"`
+}
+
+// ElementValueJSON is an annotation element, or a value of an array element
+// when Name is empty. Tag is the element value tag of the class file format;
+// Value is set for constants (with longs, floats and doubles as strings),
+// enum constants ("Lpkg/Kind;.NAME") and classes, Annotation for nested
+// annotations and Values for arrays.
+type ElementValueJSON struct {
+	Name       string             `json:"name,omitempty"`
+	Tag        string             `json:"tag"`
+	Value      any                `json:"value,omitempty"`
+	Annotation *AnnotationJSON    `json:"annotation,omitempty"`
+	Values     []ElementValueJSON `json:"values,omitempty"`
+}
+
+// classJSON converts class, using resolve for the contents of its handles.
+func classJSON(class *data.Class, resolve data.Resolver, sel Selection) (*ClassJSON, error) {
+	j := newJavap(io.Discard, class, resolve, false)
+	j.selection = sel
+
+	selected, err := sel.apply(class)
+	if err != nil {
+		return nil, err
+	}
+
+	var major, minor int
+	if _, err := fmt.Sscanf(class.Version, "%d.%d", &major, &minor); err != nil {
+		return nil, fmt.Errorf("invalid version %q", class.Version)
+	}
+
+	out := &ClassJSON{
+		Schema:       JSONSchema,
+		Major:        major,
+		Minor:        minor,
+		AccessFlags:  flagsJSON(class.AccessFlags, data.CLASS_FLAGS),
+		ThisClass:    j.internalName(class.ThisClass),
+		Interfaces:   []string{},
+		ConstantPool: []ConstantJSON{},
+		Fields:       []MemberJSON{},
+		Methods:      []MemberJSON{},
+	}
+
+	if class.SuperClass != nil {
+		super := j.internalName(class.SuperClass)
+		out.SuperClass = &super
+	}
+
+	for _, c := range class.Interfaces {
+		out.Interfaces = append(out.Interfaces, j.internalName(c))
+	}
+
+	for i, c := range class.ConstantPool {
+		if c != nil && sel.constants(true) {
+			out.ConstantPool = append(out.ConstantPool, j.constantJSON(i+1, c))
+		}
+	}
+
+	if out.Attributes, err = j.attributesJSON(data.SortedHandles(class.Attributes)); err != nil {
+		return nil, err
+	}
+
+	for _, f := range selected.Fields {
+		m, err := j.memberJSON(f)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name.Value, err)
+		}
+		out.Fields = append(out.Fields, m)
+	}
+
+	for _, f := range selected.Methods {
+		m, err := j.memberJSON(f)
+		if err != nil {
+			return nil, fmt.Errorf("method %s: %w", f.Name.Value, err)
+		}
+		out.Methods = append(out.Methods, m)
+	}
+
+	return out, nil
+}
+
+// writeJSON encodes the selected parts of the class read from source to w,
+// indented.
+func writeJSON(w io.Writer, source string, class *data.Class, resolve data.Resolver, sel Selection) error {
+	out, err := classJSON(class, resolve, sel)
+	if err != nil {
+		return err
+	}
+	out.Source = source
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func flagsJSON(af data.AccessFlags, of data.FlagsOf) FlagsJSON {
+	names := []string{}
+	for _, k := range af.Keywords(of) {
+		if _, err := strconv.ParseUint(k, 0, 16); err != nil {
+			names = append(names, k)
+		}
+	}
+	return FlagsJSON{Value: uint16(af), Names: names}
+}
+
+// index of the constant r points to, 0 if none.
+func (j *javap) index(r *data.Data) int {
+	if i, ok := j.indices[j.slot(r)]; ok {
+		return i
+	}
+	return 0
+}
+
+func (j *javap) constantJSON(index int, c data.Data) ConstantJSON {
+	out := ConstantJSON{Index: index, Tag: c.Tag().String()[len("Constant"):]}
+
+	for _, r := range data.References(c) {
+		out.Refs = append(out.Refs, j.index(r))
+	}
+
+	name := func(nat *data.Data) {
+		if n := j.slot(nat); n != nil && n.Tag() == data.CP_NAME_AND_TYPE {
+			out.Name = j.describe(j.slot(n.ConstantNameAndType().Name))
+			out.Descriptor = j.describe(j.slot(n.ConstantNameAndType().Descriptor))
+		}
+	}
+
+	switch c.Tag() {
+	case data.CP_UTF8:
+		out.Value = c.ConstantUtf8().Value
+	case data.CP_INTEGER:
+		out.Value = c.ConstantInteger().Value
+	case data.CP_FLOAT, data.CP_LONG, data.CP_DOUBLE:
+		out.Value = wideNumber(c)
+	case data.CP_STRING:
+		out.Value = j.describe(j.slot(c.ConstantString().Value))
+	case data.CP_CLASS:
+		out.Name = j.describe(j.slot(c.ConstantClass().Name))
+	case data.CP_NAME_AND_TYPE:
+		out.Name = j.describe(j.slot(c.ConstantNameAndType().Name))
+		out.Descriptor = j.describe(j.slot(c.ConstantNameAndType().Descriptor))
+	case data.CP_FIELDREF:
+		out.Class = j.refClass(c.ConstantFieldref().Clazz)
+		name(c.ConstantFieldref().NameAndType)
+	case data.CP_METHODREF:
+		out.Class = j.refClass(c.ConstantMethodref().Clazz)
+		name(c.ConstantMethodref().NameAndType)
+	case data.CP_INTERFACE_METHODREF:
+		out.Class = j.refClass(c.ConstantInterfaceMethodref().Clazz)
+		name(c.ConstantInterfaceMethodref().NameAndType)
+	}
+
+	return out
+}
+
+// wideNumber formats a float, long or double constant, which the schema
+// keeps in strings.
+func wideNumber(c data.Data) string {
+	switch c.Tag() {
+	case data.CP_FLOAT:
+		return strconv.FormatFloat(float64(c.ConstantFloat().Value), 'g', -1, 32)
+	case data.CP_LONG:
+		return strconv.FormatInt(c.ConstantLong().Value, 10)
+	case data.CP_DOUBLE:
+		return strconv.FormatFloat(c.ConstantDouble().Value, 'g', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func (j *javap) memberJSON(m data.MemberInfo) (MemberJSON, error) {
+	of := data.FIELD_FLAGS
+	if m.MemberType == data.METHOD {
+		of = data.METHOD_FLAGS
+	}
+
+	out := MemberJSON{
+		Name:        m.Name.Value,
+		Descriptor:  m.Descriptor.Value,
+		AccessFlags: flagsJSON(m.AccessFlags, of),
+		Unparsable:  m.Unparsable,
+		Attributes:  []AttributeJSON{},
+	}
+
+	var handles []*data.AttributeHandle
+	for _, h := range data.SortedHandles(m.Attributes) {
+		if h.AttributeTag != data.ATTR_CODE {
+			handles = append(handles, h)
+			continue
+		}
+
+		if m.Unparsable || j.selection.NoCode {
+			continue
+		}
+
+		attr, err := j.resolve(h)
+		if err != nil {
+			return out, err
+		}
+
+		if out.Code, err = j.codeJSON(attr.AttributeCode()); err != nil {
+			return out, err
+		}
+	}
+
+	var err error
+	out.Attributes, err = j.attributesJSON(handles)
+	return out, err
+}
+
+func (j *javap) codeJSON(code *data.AttributeCode) (*CodeJSON, error) {
+	out := &CodeJSON{
+		MaxStack:       int(code.MaxStack),
+		MaxLocals:      int(code.MaxLocals),
+		Instructions:   []InstructionJSON{},
+		ExceptionTable: []ExceptionJSON{},
+	}
+
+	d, err := j.resolve(&code.CodeHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := 0
+	for _, op := range d.Bytecode().Ops {
+		out.Instructions = append(out.Instructions, instructionJSON(pc, op))
+		pc += 1 + len(op.Arg)
+	}
+
+	for _, e := range code.ExceptionTable {
+		entry := ExceptionJSON{StartPC: int(e.StartPC), EndPC: int(e.EndPC), HandlerPC: int(e.HandlerPC)}
+		if e.CatchType != nil {
+			catch := j.internalName(e.CatchType)
+			entry.CatchType = &catch
+		}
+		out.ExceptionTable = append(out.ExceptionTable, entry)
+	}
+
+	handles := make([]*data.AttributeHandle, len(code.Attributes))
+	for i := range code.Attributes {
+		handles[i] = &code.Attributes[i]
+	}
+
+	if out.Attributes, err = j.attributesJSON(handles); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func instructionJSON(pc int, op data.Op) InstructionJSON {
+	out := InstructionJSON{PC: pc, Opcode: op.Code.String(), Operands: []int{}}
+
+	if i, ok := op.ConstantIndex(); ok {
+		c := int(i)
+		out.Constant = &c
+		return out
+	}
+
+	switch op.Code {
+	case data.OP_BIPUSH:
+		out.Operands = append(out.Operands, int(int8(op.Arg[0])))
+	case data.OP_IINC:
+		out.Operands = append(out.Operands, int(op.Arg[0]), int(int8(op.Arg[1])))
+	default:
+		switch len(op.Arg) {
+		case 1:
+			out.Operands = append(out.Operands, int(op.Arg[0]))
+		case 2:
+			if offset, ok := op.BranchOffset(); ok {
+				target := pc + offset
+				out.Target = &target
+			} else {
+				out.Operands = append(out.Operands, int(int16(uint16(op.Arg[0])<<8|uint16(op.Arg[1]))))
+			}
+		}
+	}
+
+	return out
+}
+
+func (j *javap) attributesJSON(handles []*data.AttributeHandle) ([]AttributeJSON, error) {
+	out := []AttributeJSON{}
+
+	for _, h := range handles {
+		attr, err := j.resolve(h)
+		if err != nil {
+			return nil, err
+		}
+
+		a := AttributeJSON{Name: h.Name.Value}
+		switch attr.Tag() {
+		case data.ATTR_SOURCE_FILE:
+			a.SourceFile = attr.AttributeSourceFile().SourceFile.Value
+		case data.ATTR_LINE_NUMBER_TABLE:
+			a.LineNumbers = []LineNumberJSON{}
+			for _, ln := range attr.AttributeLineNumberTable().LineNumbers {
+				a.LineNumbers = append(a.LineNumbers, LineNumberJSON{StartPC: int(ln.StartPC), Line: int(ln.LineNumber)})
+			}
+		case data.ATTR_LOCAL_VARIABLE_TABLE:
+			a.LocalVariables = []LocalVariableJSON{}
+			for _, lv := range attr.AttributeLocalVariableTable().LocalVariables {
+				a.LocalVariables = append(a.LocalVariables, LocalVariableJSON{
+					StartPC:    int(lv.StartPC),
+					Length:     int(lv.Length),
+					Index:      int(lv.Index),
+					Name:       lv.Name.Value,
+					Descriptor: lv.Descriptor.Value,
+				})
+			}
+		case data.ATTR_INNER_CLASSES:
+			a.InnerClasses = []InnerClassJSON{}
+			for _, c := range attr.AttributeInnerClasses().Classes {
+				inner := InnerClassJSON{
+					InnerClass:  j.internalName(c.InnerClass),
+					AccessFlags: flagsJSON(c.AccessFlags, data.FIELD_FLAGS),
+				}
+				if c.OuterClass != nil {
+					outer := j.internalName(c.OuterClass)
+					inner.OuterClass = &outer
+				}
+				if c.InnerName != nil {
+					inner.InnerName = &c.InnerName.Value
+				}
+				a.InnerClasses = append(a.InnerClasses, inner)
+			}
+		case data.ATTR_STACK_MAP_TABLE:
+			a.Frames = []FrameJSON{}
+			for _, f := range attr.AttributeStackMapTable().Frames {
+				frame := FrameJSON{FrameType: int(f.FrameType), OffsetDelta: int(f.OffsetDelta), Locals: []string{}, Stack: []string{}}
+				for _, vt := range f.Locals {
+					frame.Locals = append(frame.Locals, j.verificationType(vt))
+				}
+				for _, vt := range f.Stack {
+					frame.Stack = append(frame.Stack, j.verificationType(vt))
+				}
+				a.Frames = append(a.Frames, frame)
+			}
+		case data.ATTR_RUNTIME_VISIBLE_ANNOTATIONS:
+			a.Annotations = []AnnotationJSON{}
+			for _, an := range attr.AttributeRuntimeVisibleAnnotations().Annotations {
+				a.Annotations = append(a.Annotations, j.annotationJSON(an))
+			}
+		}
+
+		out = append(out, a)
+	}
+
+	return out, nil
+}
+
+func (j *javap) annotationJSON(a data.Annotation) AnnotationJSON {
+	out := AnnotationJSON{Type: a.Type.Value, Elements: []ElementValueJSON{}}
+	for _, e := range a.Elements {
+		v := j.elementJSON(e.Value)
+		v.Name = e.Name.Value
+		out.Elements = append(out.Elements, v)
+	}
+	return out
+}
+
+func (j *javap) elementJSON(e data.ElementValue) ElementValueJSON {
+	out := ElementValueJSON{Tag: string(e.Tag)}
+
+	switch e.Tag {
+	case 'e':
+		out.Value = e.EnumType.Value + "." + e.EnumName.Value
+	case 'c':
+		out.Value = e.Class.Value
+	case '@':
+		a := j.annotationJSON(*e.Annotation)
+		out.Annotation = &a
+	case '[':
+		out.Values = []ElementValueJSON{}
+		for _, v := range e.Values {
+			out.Values = append(out.Values, j.elementJSON(v))
+		}
+	default:
+		switch e.Const.Tag() {
+		case data.CP_INTEGER:
+			out.Value = e.Const.ConstantInteger().Value
+		case data.CP_UTF8:
+			out.Value = e.Const.ConstantUtf8().Value
+		default:
+			out.Value = wideNumber(e.Const)
+		}
+	}
+
+	return out
+}
+
+// internalName of a class as written in the class file, e.g. "[I" or
+// "java/lang/Object".
+func (j *javap) internalName(c *data.ConstantClass) string {
+	if c == nil {
+		return "?"
+	}
+	return j.describe(j.slot(c.Name))
+}
+
+// refClass is the internal name of the class constant r points to.
+func (j *javap) refClass(r *data.Data) string {
+	if c := j.slot(r); c != nil && c.Tag() == data.CP_CLASS {
+		return j.internalName(c.ConstantClass())
+	}
+	return "?"
+}
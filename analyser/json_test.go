@@ -0,0 +1,86 @@
+package analyser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+)
+
+func TestJSON(t *testing.T) {
+	class, resolve, err := assembler.Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var first, second bytes.Buffer
+	if err := writeJSON(&first, "Example.class", class, resolve, Selection{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSON(&second, "Example.class", class, resolve, Selection{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.String() != second.String() {
+		t.Fatal("output is not deterministic")
+	}
+
+	var out ClassJSON
+	dec := json.NewDecoder(&first)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Schema != JSONSchema || out.ThisClass != "jpamb/cases/Example" || out.SuperClass == nil || *out.SuperClass != "java/lang/Object" {
+		t.Errorf("unexpected header %+v", out)
+	}
+
+	if len(out.Methods) != 1 || out.Methods[0].Code == nil {
+		t.Fatalf("unexpected methods %+v", out.Methods)
+	}
+	code := out.Methods[0].Code
+
+	invoke := code.Instructions[6]
+	if invoke.PC != 9 || invoke.Opcode != "invokestatic" || invoke.Constant == nil || *invoke.Constant != 18 {
+		t.Errorf("unexpected instruction %+v", invoke)
+	}
+
+	var ref *ConstantJSON
+	for i := range out.ConstantPool {
+		if out.ConstantPool[i].Index == 18 {
+			ref = &out.ConstantPool[i]
+		}
+	}
+	if ref == nil || ref.Tag != "Methodref" || ref.Class != "java/lang/Integer" || ref.Name != "parseInt" || ref.Descriptor != "(Ljava/lang/String;)I" {
+		t.Errorf("unexpected constant #18 %+v", ref)
+	}
+
+	if len(code.ExceptionTable) != 1 || code.ExceptionTable[0].CatchType == nil || *code.ExceptionTable[0].CatchType != "java/lang/ArithmeticException" {
+		t.Errorf("unexpected exception table %+v", code.ExceptionTable)
+	}
+
+	if len(out.Fields) != 1 || out.Fields[0].Code != nil || strings.Join(out.Fields[0].AccessFlags.Names, " ") != "private static" {
+		t.Errorf("unexpected fields %+v", out.Fields)
+	}
+
+	class, resolve, err = assembler.Assemble(strings.NewReader(source + `
+.method public <init>()V
+  .limit stack 0
+  .limit locals 1
+  return
+.end method
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ctor bytes.Buffer
+	if err := writeJSON(&ctor, "Example.class", class, resolve, Selection{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ctor.String(), `"<init>"`) {
+		t.Errorf("constructor name is escaped:\n%s", ctor.String())
+	}
+}
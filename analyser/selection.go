@@ -0,0 +1,70 @@
+package analyser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// ErrNoMatch is returned when a method selector matches none of the methods
+// of the class.
+var ErrNoMatch = errors.New("no method matches")
+
+// Selection restricts what is printed of a class. Asking for methods, fields
+// or constants prints only the parts asked for; the zero value prints
+// everything.
+type Selection struct {
+	Methods   []data.Selector // methods to print, all if none
+	Fields    bool
+	Constants bool
+	NoCode    bool // leave out the code of methods
+}
+
+func (s Selection) restricted() bool {
+	return len(s.Methods) > 0 || s.Fields || s.Constants
+}
+
+// constants reports whether to print the constant pool, given whether the
+// view prints it when unrestricted.
+func (s Selection) constants(byDefault bool) bool {
+	return s.Constants || (byDefault && !s.restricted())
+}
+
+// apply returns a copy of class with only the selected fields and methods. It
+// fails with ErrNoMatch if a method selector matches none.
+func (s Selection) apply(class *data.Class) (*data.Class, error) {
+	if !s.restricted() {
+		return class, nil
+	}
+
+	selected := *class
+	selected.Fields, selected.Methods = nil, nil
+
+	if s.Fields {
+		selected.Fields = class.Fields
+	}
+
+	// Methods keep their order in the class, whichever selector picks them
+	picked := make([]bool, len(class.Methods))
+	for _, sel := range s.Methods {
+		found := false
+		for i, m := range class.Methods {
+			if sel.Matches(class, m) {
+				found, picked[i] = true, true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%w %s", ErrNoMatch, sel)
+		}
+	}
+
+	for i, m := range class.Methods {
+		if picked[i] {
+			selected.Methods = append(selected.Methods, m)
+		}
+	}
+
+	return &selected, nil
+}
@@ -0,0 +1,82 @@
+package analyser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+func TestSelection(t *testing.T) {
+	class, resolve, err := assembler.Assemble(strings.NewReader(source + `
+.method public abstract shape()I
+.end method
+
+.method public static divide(I)I
+  .limit stack 2
+  .limit locals 1
+  iload_0
+  iconst_2
+  idiv
+  ireturn
+.end method
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	selector := func(s string) data.Selector {
+		sel, err := data.ParseSelector(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sel
+	}
+
+	for _, tc := range []struct {
+		selection Selection
+		want      []string
+		unwanted  []string
+	}{{
+		selection: Selection{Methods: []data.Selector{selector("divide")}},
+		want:      []string{"public static int divide(int, int);", "public static int divide(int);", "iconst_2"},
+		unwanted:  []string{"int answer;", "shape", "Constant pool:"},
+	}, {
+		selection: Selection{Methods: []data.Selector{selector("jpamb.cases.Example.divide:(I)I"), selector("shape")}, NoCode: true},
+		want:      []string{"public static int divide(int);", "public abstract int shape();"},
+		unwanted:  []string{"divide(int, int)", "Code:"},
+	}, {
+		selection: Selection{Fields: true, Constants: true},
+		want:      []string{"private static int answer;", "Constant pool:", "#18 = Methodref"},
+		unwanted:  []string{"int divide(", "Code:"},
+	}, {
+		selection: Selection{},
+		want:      []string{"int answer;", "public abstract int shape();", "divide(int, int)", "divide(int)"},
+		unwanted:  []string{"Constant pool:"},
+	}} {
+		var out strings.Builder
+		j := newJavap(&out, class, resolve, false)
+		j.selection = tc.selection
+		if err := j.print(nil); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range tc.want {
+			if !strings.Contains(out.String(), s) {
+				t.Errorf("%+v: output lacks %q:\n%s", tc.selection, s, out.String())
+			}
+		}
+
+		for _, s := range tc.unwanted {
+			if strings.Contains(out.String(), s) {
+				t.Errorf("%+v: output has %q:\n%s", tc.selection, s, out.String())
+			}
+		}
+	}
+
+	if _, err := (Selection{Methods: []data.Selector{selector("other.Class.divide")}}).apply(class); !errors.Is(err, ErrNoMatch) {
+		t.Error("expected no method to match a selector for another class")
+	}
+}
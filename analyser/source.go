@@ -0,0 +1,180 @@
+package analyser
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Source is a class file to analyse: a file, an entry of a jar or stdin.
+type Source struct {
+	Name    string    // path, path.jar!entry, or - for stdin
+	ModTime time.Time // zero if unknown
+	open    func() (io.ReadSeeker, error)
+}
+
+func FileSource(path string) Source {
+	src := Source{Name: path, open: func() (io.ReadSeeker, error) { return os.Open(path) }}
+	if info, err := os.Stat(path); err == nil {
+		src.ModTime = info.ModTime()
+	}
+	return src
+}
+
+func bytesSource(name string, modTime time.Time, b []byte) Source {
+	return Source{Name: name, ModTime: modTime, open: func() (io.ReadSeeker, error) { return bytes.NewReader(b), nil }}
+}
+
+// Read the whole source.
+func (s Source) Read() ([]byte, error) {
+	r, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	return io.ReadAll(r)
+}
+
+// Sources expands arguments into the class files they name. Directories are
+// searched recursively for .class files, jars are listed for their .class
+// entries, and - reads a class from stdin. Classes are in the order of the
+// arguments, and within a directory or jar in lexical order. Stdin and the
+// entries of jars may hold at most maxBytes, unbounded if 0.
+func Sources(args []string, stdin io.Reader, maxBytes int64) ([]Source, error) {
+	var (
+		sources []Source
+		piped   bool
+	)
+
+	for _, arg := range args {
+		if arg == "-" {
+			if piped {
+				return nil, fmt.Errorf("stdin can only be read once")
+			}
+			piped = true
+
+			b, err := readAll(stdin, maxBytes)
+			if err != nil {
+				return nil, fmt.Errorf("reading stdin: %w", err)
+			}
+			sources = append(sources, bytesSource("-", time.Time{}, b))
+			continue
+		}
+
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case info.IsDir():
+			found, err := dirSources(arg)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, found...)
+		case filepath.Ext(arg) == ".jar":
+			found, err := jarSources(arg, maxBytes)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, found...)
+		case filepath.Ext(arg) == ".class":
+			sources = append(sources, FileSource(arg))
+		default:
+			return nil, fmt.Errorf("file %s must have a .class or .jar extension", arg)
+		}
+	}
+
+	return sources, nil
+}
+
+func dirSources(dir string) ([]Source, error) {
+	var sources []Source
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() && filepath.Ext(path) == ".class" {
+			sources = append(sources, FileSource(path))
+		}
+
+		return nil
+	})
+
+	return sources, err
+}
+
+// jarSources reads the jar into memory, and its entries from there when
+// opened.
+func jarSources(jar string, maxBytes int64) ([]Source, error) {
+	b, err := os.ReadFile(jar)
+	if err != nil {
+		return nil, err
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", jar, err)
+	}
+
+	var sources []Source
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(f.Name, ".class") {
+			continue
+		}
+
+		sources = append(sources, Source{
+			Name:    jar + "!" + f.Name,
+			ModTime: f.Modified,
+			open: func() (io.ReadSeeker, error) {
+				if maxBytes != 0 && f.UncompressedSize64 > uint64(maxBytes) {
+					return nil, fmt.Errorf("entry of %d bytes exceeds limit of %d", f.UncompressedSize64, maxBytes)
+				}
+
+				r, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer r.Close()
+
+				entry, err := readAll(r, maxBytes)
+				if err != nil {
+					return nil, err
+				}
+				return bytes.NewReader(entry), nil
+			},
+		})
+	}
+
+	slices.SortFunc(sources, func(a, b Source) int { return strings.Compare(a.Name, b.Name) })
+
+	return sources, nil
+}
+
+// readAll reads r to the end, failing once it has read more than max bytes,
+// unless max is 0.
+func readAll(r io.Reader, max int64) ([]byte, error) {
+	if max == 0 {
+		return io.ReadAll(r)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err == nil && int64(len(b)) > max {
+		return nil, fmt.Errorf("more than %d bytes, exceeding the limit", max)
+	}
+	return b, err
+}
@@ -0,0 +1,149 @@
+package analyser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/parser"
+	"github.com/luishfonseca/dtu_pa/writer"
+)
+
+// DebugAttributes are the attributes strip removes by default: they only
+// help debuggers and stack traces, and may hold local paths and names.
+var DebugAttributes = []data.Tag{data.ATTR_SOURCE_FILE, data.ATTR_LINE_NUMBER_TABLE, data.ATTR_LOCAL_VARIABLE_TABLE}
+
+// AttributeTag is the tag of the attribute called name in class files, e.g.
+// data.ATTR_SOURCE_FILE for SourceFile.
+func AttributeTag(name string) (data.Tag, error) {
+	for _, tag := range []data.Tag{
+		data.ATTR_CODE,
+		data.ATTR_SOURCE_FILE,
+		data.ATTR_RUNTIME_VISIBLE_ANNOTATIONS,
+		data.ATTR_INNER_CLASSES,
+		data.ATTR_LINE_NUMBER_TABLE,
+		data.ATTR_LOCAL_VARIABLE_TABLE,
+		data.ATTR_STACK_MAP_TABLE,
+	} {
+		if strings.TrimPrefix(tag.String(), "Attribute") == name {
+			return tag, nil
+		}
+	}
+	return data.UNKNOWN, fmt.Errorf("unknown attribute %s", name)
+}
+
+// Strip returns the class file without the attributes in remove, wherever
+// they are, and without the constants nothing refers to any more. The result
+// is parsed again in full to check it is well formed.
+func (a *analyser) Strip(ctx context.Context, remove []data.Tag) (stripped []byte, err error) {
+	if slices.Contains(remove, data.ATTR_CODE) {
+		return nil, fmt.Errorf("the Code attribute cannot be stripped")
+	}
+
+	removed := func(h data.AttributeHandle) bool { return slices.Contains(remove, h.AttributeTag) }
+
+	without := func(attrs map[data.Tag]*data.AttributeHandle) map[data.Tag]*data.AttributeHandle {
+		attrs = maps.Clone(attrs)
+		maps.DeleteFunc(attrs, func(_ data.Tag, h *data.AttributeHandle) bool { return removed(*h) })
+		return attrs
+	}
+
+	members := func(ms []data.MemberInfo) []data.MemberInfo {
+		ms = slices.Clone(ms)
+		for i := range ms {
+			ms[i].Attributes = without(ms[i].Attributes)
+		}
+		return ms
+	}
+
+	err = a.open(ctx, func(s *parser.Session, class *data.Class) error {
+		for _, m := range class.Methods {
+			if m.Unparsable {
+				return fmt.Errorf("method %s%s could not be parsed", m.Name.Value, m.Descriptor.Value)
+			}
+		}
+
+		out := *class
+		out.Attributes = without(class.Attributes)
+		out.Fields = members(class.Fields)
+		out.Methods = members(class.Methods)
+
+		// Attributes of code are stripped as they are resolved
+		resolve := func(h data.Data) (data.Data, error) {
+			d, err := s.Request(h)
+			if err != nil || d.Tag() != data.ATTR_CODE {
+				return d, err
+			}
+
+			code := *d.AttributeCode()
+			code.Attributes = slices.DeleteFunc(slices.Clone(code.Attributes), removed)
+			return &code, nil
+		}
+
+		if stripped, err = writer.Bytes(&out, resolve, writer.Compact()); err != nil {
+			return err
+		}
+
+		return check(ctx, stripped)
+	})
+
+	return stripped, err
+}
+
+// check parses b strictly, resolving every attribute and all code.
+func check(ctx context.Context, b []byte) error {
+	s, err := parser.OpenReader(ctx, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	class, err := s.Class()
+	if err != nil {
+		return fmt.Errorf("stripped class does not parse: %w", err)
+	}
+
+	var resolveAll func(handles []*data.AttributeHandle) error
+	resolveAll = func(handles []*data.AttributeHandle) error {
+		for _, h := range handles {
+			d, err := s.Request(h)
+			if err != nil {
+				return fmt.Errorf("stripped class does not parse: %w", err)
+			}
+
+			if d.Tag() != data.ATTR_CODE {
+				continue
+			}
+			code := d.AttributeCode()
+
+			if _, err := s.Request(&code.CodeHandle); err != nil {
+				return fmt.Errorf("stripped class does not parse: %w", err)
+			}
+
+			var nested []*data.AttributeHandle
+			for i := range code.Attributes {
+				nested = append(nested, &code.Attributes[i])
+			}
+			if err := resolveAll(nested); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := resolveAll(data.SortedHandles(class.Attributes)); err != nil {
+		return err
+	}
+
+	for _, m := range slices.Concat(class.Fields, class.Methods) {
+		if err := resolveAll(data.SortedHandles(m.Attributes)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
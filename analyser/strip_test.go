@@ -0,0 +1,79 @@
+package analyser
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/parser"
+	"github.com/luishfonseca/dtu_pa/writer"
+)
+
+func TestStrip(t *testing.T) {
+	class, resolve, err := assembler.Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := writer.Bytes(class, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	stripped, err := NewSource(bytesSource("Example.class", time.Time{}, original)).Strip(ctx, DebugAttributes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stripped) >= len(original) {
+		t.Errorf("stripping did not shrink the class: %d -> %d bytes", len(original), len(stripped))
+	}
+
+	s, err := parser.OpenReader(ctx, bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if class, err = s.Class(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(class.Attributes) != 0 {
+		t.Errorf("class attributes left: %v", class.Attributes)
+	}
+
+	for _, c := range class.ConstantPool {
+		if c != nil && c.Tag() == data.CP_UTF8 {
+			switch v := c.ConstantUtf8().Value; v {
+			case "SourceFile", "Example.java", "LineNumberTable", "LocalVariableTable", "a":
+				t.Errorf("unreferenced constant %q left", v)
+			}
+		}
+	}
+
+	var out strings.Builder
+	if err := newJavap(&out, class, s.Request, true).print(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"ldc           #", "Exception table:"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("stripped class lacks %q:\n%s", line, out.String())
+		}
+	}
+	for _, line := range []string{"LineNumberTable", "LocalVariableTable", "SourceFile"} {
+		if strings.Contains(out.String(), line) {
+			t.Errorf("stripped class still has %q:\n%s", line, out.String())
+		}
+	}
+
+	if _, err := NewSource(bytesSource("Example.class", time.Time{}, original)).Strip(ctx, []data.Tag{data.ATTR_CODE}); err == nil {
+		t.Error("expected stripping Code to fail")
+	}
+}
@@ -0,0 +1,670 @@
+// Package assembler builds classes from a Jasmin-like text format, and
+// disassembles classes back into it:
+//
+//	.version 49.0
+//	.class public super Example
+//	.super java/lang/Object
+//	.implements java/lang/Runnable
+//	.source Example.java
+//
+//	.field private static count I
+//
+//	.method public static divide(II)I
+//	  .limit stack 2
+//	  .limit locals 2
+//	  .line 3
+//	L0:
+//	  iload_0
+//	  iload_1
+//	  idiv
+//	  ireturn
+//	L4:
+//	  astore_1
+//	  iconst_0
+//	  ireturn
+//	  .catch java/lang/ArithmeticException from L0 to L4 using L4
+//	  .var 0 is a I from L0 to L4
+//	.end method
+//
+// Comments start with ';'. Labels name the position of the instruction that
+// follows them and are used by branches, .catch and .var; only the end of a
+// .catch or .var range may be the end of the code. Methods of
+// interfaces are invoked as in invokestatic interface pkg/I/m()V. Methods with code
+// must give their .limit stack and .limit locals. The version defaults to
+// 49.0, the last that does not require a StackMapTable, which the format does
+// not represent; neither are inner classes or annotations.
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/state"
+)
+
+type assembler struct {
+	scanner *bufio.Scanner
+	line    int
+	tokens  []string
+
+	class  *data.Class
+	pool   *data.PoolBuilder
+	next   int64 // Begin of the next handle
+	attrs  map[int64]data.Data
+	codes  map[int64]*data.Bytecode
+	method *method
+
+	err error
+}
+
+// method being assembled, whose labels are resolved at .end method.
+type method struct {
+	info      data.MemberInfo
+	ops       []data.Op
+	pc        int
+	labels    map[string]int
+	branches  map[int]string // op to target label
+	lines     []data.LineNumber
+	catches   [][4]string // from, to, using and class
+	vars      [][5]string // index, name, descriptor, from and to
+	maxStack  int
+	maxLocals int
+}
+
+func (a *assembler) Fail(err error) {
+	a.err = fmt.Errorf("line %d: %w", a.line, err)
+}
+
+// Assemble builds the class described by src, returning it along with a
+// resolver for the contents of its handles.
+func Assemble(src io.Reader) (*data.Class, data.Resolver, error) {
+	a := &assembler{
+		scanner: bufio.NewScanner(src),
+		class: &data.Class{
+			Version:    "49.0",
+			Attributes: make(map[data.Tag]*data.AttributeHandle),
+		},
+		attrs: make(map[int64]data.Data),
+		codes: make(map[int64]*data.Bytecode),
+	}
+	a.pool = data.NewPoolBuilder(a.class)
+
+	state.Run(a, header)
+	if a.err != nil {
+		return nil, nil, a.err
+	}
+	if err := a.pool.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return a.class, a.resolve, nil
+}
+
+func (a *assembler) resolve(h data.Data) (data.Data, error) {
+	switch h.Tag() {
+	case data.ATTRIBUTE_HANDLE:
+		if d, ok := a.attrs[h.AttributeHandle().Begin]; ok {
+			return d, nil
+		}
+	case data.BYTECODE_HANDLE:
+		if d, ok := a.codes[h.BytecodeHandle().Begin]; ok {
+			return d, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%v was not assembled", h)
+}
+
+// scan moves to the next line with tokens, reporting false at the end of the
+// input.
+func (a *assembler) scan() (bool, error) {
+	for a.scanner.Scan() {
+		a.line++
+
+		tokens, err := tokenize(a.scanner.Text())
+		if err != nil {
+			return false, err
+		}
+
+		if len(tokens) > 0 {
+			a.tokens = tokens
+			return true, nil
+		}
+	}
+
+	return false, a.scanner.Err()
+}
+
+// args checks the current line has between min and max tokens after the
+// first, with max < 0 for no limit.
+func (a *assembler) args(min, max int) ([]string, error) {
+	args := a.tokens[1:]
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return nil, fmt.Errorf("wrong number of operands for %s", a.tokens[0])
+	}
+	return args, nil
+}
+
+// attribute adds the handle of d, named after its attribute.
+func (a *assembler) attribute(d data.Data, name string) *data.AttributeHandle {
+	a.next++
+	a.attrs[a.next] = d
+	return &data.AttributeHandle{AttributeTag: d.Tag(), Name: a.pool.Utf8(name), Begin: a.next}
+}
+
+func header(a *assembler) state.Fn[*assembler] {
+	ok, err := a.scan()
+	if err != nil {
+		return state.Fail[*assembler](err)
+	}
+
+	if !ok {
+		return finish
+	}
+
+	switch a.tokens[0] {
+	case ".version":
+		args, err := a.args(1, 1)
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+
+		var major, minor uint16
+		if _, err := fmt.Sscanf(args[0], "%d.%d", &major, &minor); err != nil {
+			return state.Fail[*assembler](fmt.Errorf("invalid version %q", args[0]))
+		}
+		a.class.Version = args[0]
+	case ".class":
+		args, err := a.args(1, -1)
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+
+		if a.class.ThisClass != nil {
+			return state.Fail[*assembler](fmt.Errorf("duplicate .class"))
+		}
+
+		if a.class.AccessFlags, err = data.ParseAccessFlags(args[:len(args)-1], data.CLASS_FLAGS); err != nil {
+			return state.Fail[*assembler](err)
+		}
+		a.class.ThisClass = a.pool.Class(args[len(args)-1])
+	case ".super":
+		args, err := a.args(1, 1)
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+		a.class.SuperClass = a.pool.Class(args[0])
+	case ".implements":
+		args, err := a.args(1, 1)
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+		a.class.Interfaces = append(a.class.Interfaces, a.pool.Class(args[0]))
+	case ".source":
+		args, err := a.args(1, 1)
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+		a.class.Attributes[data.ATTR_SOURCE_FILE] = a.attribute(&data.AttributeSourceFile{SourceFile: a.pool.Utf8(args[0])}, "SourceFile")
+	case ".field":
+		args, err := a.args(2, -1)
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+
+		flags, err := data.ParseAccessFlags(args[:len(args)-2], data.FIELD_FLAGS)
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+
+		a.class.Fields = append(a.class.Fields, data.MemberInfo{
+			MemberType:  data.FIELD,
+			AccessFlags: flags,
+			Name:        a.pool.Utf8(args[len(args)-2]),
+			Descriptor:  a.pool.Utf8(args[len(args)-1]),
+			Attributes:  make(map[data.Tag]*data.AttributeHandle),
+		})
+	case ".method":
+		return startMethod
+	default:
+		return state.Fail[*assembler](fmt.Errorf("unexpected %s outside a method", a.tokens[0]))
+	}
+
+	return header
+}
+
+func startMethod(a *assembler) state.Fn[*assembler] {
+	args, err := a.args(1, -1)
+	if err != nil {
+		return state.Fail[*assembler](err)
+	}
+
+	flags, err := data.ParseAccessFlags(args[:len(args)-1], data.METHOD_FLAGS)
+	if err != nil {
+		return state.Fail[*assembler](err)
+	}
+
+	signature := args[len(args)-1]
+	i := strings.IndexByte(signature, '(')
+	if i <= 0 {
+		return state.Fail[*assembler](fmt.Errorf("method %q has no descriptor", signature))
+	}
+
+	a.method = &method{
+		info: data.MemberInfo{
+			MemberType:  data.METHOD,
+			AccessFlags: flags,
+			Name:        a.pool.Utf8(signature[:i]),
+			Descriptor:  a.pool.Utf8(signature[i:]),
+			Attributes:  make(map[data.Tag]*data.AttributeHandle),
+		},
+		labels:    make(map[string]int),
+		branches:  make(map[int]string),
+		maxStack:  -1,
+		maxLocals: -1,
+	}
+
+	return methodBody
+}
+
+func methodBody(a *assembler) state.Fn[*assembler] {
+	ok, err := a.scan()
+	if err != nil {
+		return state.Fail[*assembler](err)
+	}
+
+	if !ok {
+		return state.Fail[*assembler](fmt.Errorf("missing .end method"))
+	}
+
+	m := a.method
+	tok := a.tokens[0]
+
+	if label, ok := strings.CutSuffix(tok, ":"); ok && label != "" {
+		if _, dup := m.labels[label]; dup {
+			return state.Fail[*assembler](fmt.Errorf("duplicate label %s", label))
+		}
+		m.labels[label] = m.pc
+
+		if a.tokens = a.tokens[1:]; len(a.tokens) == 0 {
+			return methodBody
+		}
+		tok = a.tokens[0]
+	}
+
+	switch tok {
+	case ".end":
+		if _, err := a.args(1, 1); err != nil || a.tokens[1] != "method" {
+			return state.Fail[*assembler](fmt.Errorf("expected .end method"))
+		}
+		return endMethod
+	case ".limit":
+		args, err := a.args(2, 2)
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+
+		v, err := strconv.ParseUint(args[1], 0, 16)
+		if err != nil {
+			return state.Fail[*assembler](fmt.Errorf("invalid limit %q", args[1]))
+		}
+
+		switch args[0] {
+		case "stack":
+			m.maxStack = int(v)
+		case "locals":
+			m.maxLocals = int(v)
+		default:
+			return state.Fail[*assembler](fmt.Errorf("unknown limit %q", args[0]))
+		}
+	case ".line":
+		args, err := a.args(1, 1)
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+
+		v, err := strconv.ParseUint(args[0], 0, 16)
+		if err != nil {
+			return state.Fail[*assembler](fmt.Errorf("invalid line number %q", args[0]))
+		}
+		m.lines = append(m.lines, data.LineNumber{StartPC: uint16(m.pc), LineNumber: uint16(v)})
+	case ".catch":
+		args, err := a.args(7, 7)
+		if err != nil || args[1] != "from" || args[3] != "to" || args[5] != "using" {
+			return state.Fail[*assembler](fmt.Errorf("expected .catch <class> from <label> to <label> using <label>"))
+		}
+		m.catches = append(m.catches, [4]string{args[2], args[4], args[6], args[0]})
+	case ".var":
+		args, err := a.args(8, 8)
+		if err != nil || args[1] != "is" || args[4] != "from" || args[6] != "to" {
+			return state.Fail[*assembler](fmt.Errorf("expected .var <index> is <name> <descriptor> from <label> to <label>"))
+		}
+		m.vars = append(m.vars, [5]string{args[0], args[2], args[3], args[5], args[7]})
+	default:
+		if err := a.instruction(); err != nil {
+			return state.Fail[*assembler](err)
+		}
+	}
+
+	return methodBody
+}
+
+// instruction assembles the current line, leaving branch targets to be
+// resolved at the end of the method.
+func (a *assembler) instruction() error {
+	m := a.method
+
+	code, ok := opcodes[a.tokens[0]]
+	if !ok {
+		return fmt.Errorf("unknown instruction %s", a.tokens[0])
+	}
+
+	n, _ := code.NArgs()
+	min, max := 1, 1
+	switch OperandOf(code) {
+	case NONE:
+		min, max = 0, 0
+	case INCREMENT, FIELD:
+		min, max = 2, 2
+	case CONSTANT, METHOD:
+		max = 2
+	}
+
+	args, err := a.args(min, max)
+	if err != nil {
+		return err
+	}
+
+	op := data.Op{Code: code}
+	switch OperandOf(code) {
+	case BYTE, SHORT, LOCAL:
+		bits := map[Operand]int{BYTE: 8, SHORT: 16}[OperandOf(code)]
+		var v int64
+		if bits == 0 {
+			var u uint64
+			u, err = strconv.ParseUint(args[0], 0, 8)
+			v = int64(u)
+		} else {
+			v, err = strconv.ParseInt(args[0], 0, bits)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid operand %q", args[0])
+		}
+
+		if bits == 16 {
+			op.Arg = []byte{byte(v >> 8), byte(v)}
+		} else {
+			op.Arg = []byte{byte(v)}
+		}
+	case INCREMENT:
+		i, err := strconv.ParseUint(args[0], 0, 8)
+		if err != nil {
+			return fmt.Errorf("invalid local %q", args[0])
+		}
+
+		v, err := strconv.ParseInt(args[1], 0, 8)
+		if err != nil {
+			return fmt.Errorf("invalid increment %q", args[1])
+		}
+		op.Arg = []byte{byte(i), byte(v)}
+	case ARRAY_TYPE:
+		for t, name := range arrayTypes {
+			if name != "" && name == args[0] {
+				op.Arg = []byte{byte(t)}
+			}
+		}
+		if op.Arg == nil {
+			return fmt.Errorf("unknown array type %q", args[0])
+		}
+	case BRANCH:
+		m.branches[len(m.ops)] = args[0]
+		op.Arg = []byte{0, 0}
+	case CONSTANT:
+		c, err := a.constant(args)
+		if err != nil {
+			return err
+		}
+
+		i, err := a.pool.Index(c)
+		if err != nil {
+			return err
+		}
+
+		if i > 0xFF {
+			return fmt.Errorf("constant index %d does not fit ldc", i)
+		}
+		op.Arg = []byte{byte(i)}
+	case FIELD, METHOD, CLASS:
+		var c data.Data
+		switch OperandOf(code) {
+		case FIELD:
+			class, name, _, err := splitMember(args[0])
+			if err != nil {
+				return err
+			}
+			c = a.pool.Fieldref(class, name, args[1])
+		case METHOD:
+			iface := len(args) == 2
+			if iface {
+				if args[0] != "interface" {
+					return fmt.Errorf("expected interface before %s, got %s", args[1], args[0])
+				}
+				args = args[1:]
+			}
+
+			class, name, descriptor, err := splitMember(args[0])
+			if err != nil {
+				return err
+			}
+			if descriptor == "" {
+				return fmt.Errorf("method reference %q has no descriptor", args[0])
+			}
+
+			if iface {
+				c = a.pool.InterfaceMethodref(class, name, descriptor)
+			} else {
+				c = a.pool.Methodref(class, name, descriptor)
+			}
+		case CLASS:
+			c = a.pool.Class(args[0])
+		}
+
+		i, err := a.pool.Index(c)
+		if err != nil {
+			return err
+		}
+		op.Arg = []byte{byte(i >> 8), byte(i)}
+	}
+
+	if len(op.Arg) != n {
+		return fmt.Errorf("%s takes %d bytes of operands, not %d", code, n, len(op.Arg))
+	}
+
+	m.ops = append(m.ops, op)
+	m.pc += 1 + n
+
+	return nil
+}
+
+// constant interns the operand of ldc: an integer, a float with an f suffix,
+// a quoted string or class followed by a class name.
+func (a *assembler) constant(args []string) (data.Data, error) {
+	if len(args) == 2 {
+		if args[0] != "class" {
+			return nil, fmt.Errorf("invalid constant %s", strings.Join(args, " "))
+		}
+		return a.pool.Class(args[1]), nil
+	}
+
+	arg := args[0]
+	if strings.HasPrefix(arg, `"`) {
+		s, err := strconv.Unquote(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", arg)
+		}
+		return a.pool.String(s), nil
+	}
+
+	if v, err := strconv.ParseInt(arg, 0, 32); err == nil {
+		return a.pool.Integer(int32(v)), nil
+	}
+
+	if f, ok := strings.CutSuffix(arg, "f"); ok {
+		if v, err := strconv.ParseFloat(f, 32); err == nil {
+			return a.pool.Float(float32(v)), nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid constant %s", arg)
+}
+
+// label resolves a label of m.
+func (m *method) label(name string) (uint16, error) {
+	pc, ok := m.labels[name]
+	if !ok {
+		return 0, fmt.Errorf("undefined label %s", name)
+	}
+	return uint16(pc), nil
+}
+
+// instruction is the pc of the label, which must be on an instruction.
+func (m *method) instruction(name string) (uint16, error) {
+	pc, err := m.label(name)
+	if err != nil {
+		return 0, err
+	}
+	if int(pc) >= m.pc {
+		return 0, fmt.Errorf("label %s is not on an instruction", name)
+	}
+	return pc, nil
+}
+
+func endMethod(a *assembler) state.Fn[*assembler] {
+	m := a.method
+	a.method = nil
+	a.class.Methods = append(a.class.Methods, m.info)
+	info := &a.class.Methods[len(a.class.Methods)-1]
+
+	if len(m.ops) == 0 {
+		if len(m.lines) > 0 || len(m.catches) > 0 || len(m.vars) > 0 {
+			return state.Fail[*assembler](fmt.Errorf("method %s has directives but no code", m.info.Name.Value))
+		}
+		return header
+	}
+
+	if m.maxStack < 0 || m.maxLocals < 0 {
+		return state.Fail[*assembler](fmt.Errorf("method %s must give .limit stack and .limit locals", m.info.Name.Value))
+	}
+
+	if m.pc > math.MaxUint16 {
+		return state.Fail[*assembler](fmt.Errorf("method %s has %d bytes of code", m.info.Name.Value, m.pc))
+	}
+
+	pc := 0
+	for i := range m.ops {
+		if label, ok := m.branches[i]; ok {
+			target, err := m.instruction(label)
+			if err != nil {
+				return state.Fail[*assembler](err)
+			}
+
+			offset := int(target) - pc
+			if offset < math.MinInt16 || offset > math.MaxInt16 {
+				return state.Fail[*assembler](fmt.Errorf("branch to %s is too far", label))
+			}
+			m.ops[i].Arg = []byte{byte(offset >> 8), byte(offset)}
+		}
+		pc += 1 + len(m.ops[i].Arg)
+	}
+
+	a.next++
+	begin := a.next
+	a.codes[begin] = &data.Bytecode{Ops: m.ops}
+	code := &data.AttributeCode{
+		MaxStack:   uint16(m.maxStack),
+		MaxLocals:  uint16(m.maxLocals),
+		CodeHandle: data.BytecodeHandle{Begin: begin, Length: uint32(m.pc)},
+	}
+
+	for _, c := range m.catches {
+		start, err := m.instruction(c[0])
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+		end, err := m.label(c[1])
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+		handler, err := m.instruction(c[2])
+		if err != nil {
+			return state.Fail[*assembler](err)
+		}
+		if start >= end {
+			return state.Fail[*assembler](fmt.Errorf("handler of %s covers no code", c[3]))
+		}
+
+		entry := data.ExceptionTableEntry{StartPC: start, EndPC: end, HandlerPC: handler}
+		if c[3] != "all" {
+			entry.CatchType = a.pool.Class(c[3])
+		}
+		code.ExceptionTable = append(code.ExceptionTable, entry)
+	}
+
+	if len(m.lines) > 0 {
+		code.Attributes = append(code.Attributes, *a.attribute(&data.AttributeLineNumberTable{LineNumbers: m.lines}, "LineNumberTable"))
+	}
+
+	if len(m.vars) > 0 {
+		table := &data.AttributeLocalVariableTable{}
+		for _, v := range m.vars {
+			index, err := strconv.ParseUint(v[0], 0, 16)
+			if err != nil {
+				return state.Fail[*assembler](fmt.Errorf("invalid local %q", v[0]))
+			}
+
+			from, err := m.label(v[3])
+			if err != nil {
+				return state.Fail[*assembler](err)
+			}
+
+			to, err := m.label(v[4])
+			if err != nil {
+				return state.Fail[*assembler](err)
+			}
+
+			if to < from {
+				return state.Fail[*assembler](fmt.Errorf("local %s ends before it starts", v[1]))
+			}
+
+			table.LocalVariables = append(table.LocalVariables, data.LocalVariable{
+				StartPC:    from,
+				Length:     to - from,
+				Name:       a.pool.Utf8(v[1]),
+				Descriptor: a.pool.Utf8(v[2]),
+				Index:      uint16(index),
+			})
+		}
+		code.Attributes = append(code.Attributes, *a.attribute(table, "LocalVariableTable"))
+	}
+
+	info.Attributes[data.ATTR_CODE] = a.attribute(code, "Code")
+
+	return header
+}
+
+func finish(a *assembler) state.Fn[*assembler] {
+	if a.class.ThisClass == nil {
+		return state.Fail[*assembler](fmt.Errorf("missing .class"))
+	}
+
+	if a.class.SuperClass == nil && a.class.ThisClass.Name != nil && (*a.class.ThisClass.Name).ConstantUtf8().Value != "java/lang/Object" {
+		a.class.SuperClass = a.pool.Class("java/lang/Object")
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package assembler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/parser"
+	"github.com/luishfonseca/dtu_pa/writer"
+)
+
+const source = `; Every operand kind the format supports
+.version 49.0
+.class public super jpamb/cases/Example
+.super java/lang/Object
+.implements java/lang/Runnable
+.implements java/io/Serializable
+.source Example.java
+
+.field private static final 0x0100 answer I
+.field public names [Ljava/lang/String;
+
+.method public <init>()V
+  .limit stack 1
+  .limit locals 1
+  aload_0
+  invokespecial java/lang/Object/<init>()V
+  return
+.end method
+
+.method public static divide(II)I
+  .limit stack 3
+  .limit locals 3
+  .line 7
+L0: iload_0
+  iload_1
+  idiv
+  ireturn
+L4:
+  astore_2
+  .line 9
+  bipush -3
+  sipush 1000
+  iadd
+  ireturn
+  .catch java/lang/ArithmeticException from L0 to L4 using L4
+  .catch all from L0 to L4 using L4
+  .var 0 is a I from L0 to L4
+.end method
+
+.method public static constants()V
+  .limit stack 2
+  .limit locals 0
+  ldc "hello; world\n"
+  ldc 100000
+  ldc -1.5f
+  ldc class java/lang/String
+  getstatic jpamb/cases/Example/answer I
+  putstatic jpamb/cases/Example/answer I
+  invokestatic interface java/util/List/of()Ljava/util/List;
+  new java/lang/AssertionError
+  athrow
+.end method
+
+.method public static loop(I)I
+  .limit stack 2
+  .limit locals 2
+  iconst_3
+  newarray int
+  astore_1
+L5:
+  iload_0
+  ifeq L17
+  iinc 0 -1
+  goto L5
+  nop
+L17:
+  iload_0
+  ireturn
+.end method
+
+.method public abstract 0x0200 missing()V
+.end method
+`
+
+// disassemble builds src into a class file, parses it back and disassembles
+// it, returning the class file and the text.
+func disassemble(t *testing.T, src string) ([]byte, string) {
+	class, resolve, err := Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := writer.Bytes(class, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := parser.OpenReader(context.Background(), bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	parsed, err := s.Class()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := Disassemble(&out, parsed, s.Request); err != nil {
+		t.Fatal(err)
+	}
+
+	return b, out.String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	b1, text1 := disassemble(t, source)
+	b2, text2 := disassemble(t, text1)
+
+	if text1 != text2 {
+		t.Fatalf("disassembly changed:\n%s\n---\n%s", text1, text2)
+	}
+
+	if !bytes.Equal(b1, b2) {
+		t.Fatal("reassembling the disassembly changed the class file")
+	}
+
+	for _, want := range []string{
+		`ldc "hello; world\n"`, "ldc -1.5f", "ldc class java/lang/String", "bipush -3", "iinc 0 -1",
+		"goto L4", ".catch all from L0 to L4 using L4", ".var 0 is a I from L0 to L4",
+		".field private static final 0x0100 answer I", "ifeq L15",
+		"invokestatic interface java/util/List/of()Ljava/util/List;",
+		".implements java/lang/Runnable\n.implements java/io/Serializable\n",
+	} {
+		if !strings.Contains(text1, want) {
+			t.Errorf("disassembly lacks %q:\n%s", want, text1)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var full strings.Builder
+	full.WriteString(".class A\n")
+	for i := range 1 << 16 {
+		fmt.Fprintf(&full, ".field f%d I\n", i)
+	}
+
+	for _, tc := range []struct {
+		src, err string
+	}{
+		{"", "missing .class"},
+		{".class A\n.method m()V\n", "missing .end method"},
+		{".class A\n.method m()V\n  nop\n.end method\n", "must give .limit"},
+		{".class A\n.method m()V\n.limit stack 1\n.limit locals 1\n  goto X\n.end method\n", "undefined label X"},
+		{".class A\n.method m()V\n  frobnicate\n.end method\n", "line 3: unknown instruction frobnicate"},
+		{".class A\n.method m()V\n  iload\n.end method\n", "wrong number of operands"},
+		{".class A\n.method m()V\n  bipush 200\n.end method\n", "invalid operand"},
+		{".class A\n.method m()V\n  ldc 1.5\n.end method\n", "invalid constant"},
+		{".class A\n.method m()V\nL0:\nL0:\n.end method\n", "duplicate label"},
+		{".class wobbly A\n", "unknown access flag"},
+		{".class A\n.method m()V\n  invokestatic static A/m()V\n.end method\n", "expected interface"},
+		{full.String(), "constant pool is full"},
+		{".class A\n.method m()V\n.limit stack 1\n.limit locals 0\n  iconst_0\n  ifeq L5\n  return\nL5:\n.end method\n", "label L5 is not on an instruction"},
+		{".class A\n.method m()V\n.limit stack 1\n.limit locals 0\nL0:\n  nop\nL1:\n  return\n  .catch all from L1 to L0 using L0\n.end method\n", "covers no code"},
+		{".class A\n.method m()V\n.limit stack 1\n.limit locals 0\nL0:\n  return\nL1:\n  .catch all from L0 to L1 using L1\n.end method\n", "label L1 is not on an instruction"},
+	} {
+		_, _, err := Assemble(strings.NewReader(tc.src))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%q: got error %v, want %q", tc.src, err, tc.err)
+		}
+	}
+}
+
+func TestFramesLowerVersion(t *testing.T) {
+	for _, tc := range []struct {
+		frames bool
+		want   string
+	}{
+		{false, ".version 52.0\n"},
+		{true, ".version 49.0\n"},
+	} {
+		class, resolve, err := Assemble(strings.NewReader(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		class.Version = "52.0"
+
+		framed := resolve
+		if tc.frames {
+			framed = func(h data.Data) (data.Data, error) {
+				attr, err := resolve(h)
+				if err != nil || attr.Tag() != data.ATTR_CODE {
+					return attr, err
+				}
+
+				code := *attr.AttributeCode()
+				code.Attributes = append(slices.Clone(code.Attributes), data.AttributeHandle{AttributeTag: data.ATTR_STACK_MAP_TABLE})
+				return &code, nil
+			}
+		}
+
+		var out strings.Builder
+		if err := Disassemble(&out, class, framed); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(out.String(), tc.want) {
+			t.Fatalf("frames %t: disassembly lacks %q:\n%s", tc.frames, tc.want, out.String())
+		}
+
+		b, text := disassemble(t, out.String())
+		if !strings.Contains(text, tc.want) {
+			t.Errorf("frames %t: reassembly lacks %q:\n%s", tc.frames, tc.want, text)
+		}
+
+		s, err := parser.OpenReader(context.Background(), bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Class(); err != nil {
+			t.Errorf("frames %t: reassembled class does not parse: %v", tc.frames, err)
+		}
+		s.Close()
+	}
+}
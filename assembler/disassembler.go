@@ -0,0 +1,381 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+type disassembler struct {
+	out     *bufio.Writer
+	class   *data.Class
+	resolve data.Resolver
+}
+
+// Disassemble writes class in the text format Assemble reads, using resolve
+// to obtain the contents of its handles. Attributes the format does not
+// represent are listed in comments. A class whose methods carry a
+// StackMapTable is given version 49.0, so that it verifies without them.
+func Disassemble(w io.Writer, class *data.Class, resolve data.Resolver) error {
+	d := &disassembler{out: bufio.NewWriter(w), class: class, resolve: resolve}
+
+	if err := d.disassemble(); err != nil {
+		return err
+	}
+
+	return d.out.Flush()
+}
+
+func (d *disassembler) printf(format string, args ...any) {
+	fmt.Fprintf(d.out, format, args...)
+}
+
+func (d *disassembler) disassemble() error {
+	this, err := className(d.class.ThisClass)
+	if err != nil {
+		return fmt.Errorf("this class: %w", err)
+	}
+
+	version := d.class.Version
+	major, _, _ := strings.Cut(version, ".")
+	if n, err := strconv.Atoi(major); err == nil && n >= 50 {
+		frames, err := d.frames()
+		if err != nil {
+			return err
+		}
+		if frames {
+			d.printf("; version %s lowered, as its StackMapTable is not represented\n", version)
+			version = "49.0"
+		}
+	}
+
+	d.printf(".version %s\n", version)
+	d.printf(".class %s\n", strings.Join(append(d.class.AccessFlags.Keywords(data.CLASS_FLAGS), this), " "))
+
+	if d.class.SuperClass != nil {
+		super, err := className(d.class.SuperClass)
+		if err != nil {
+			return fmt.Errorf("super class: %w", err)
+		}
+		d.printf(".super %s\n", super)
+	}
+
+	for i, c := range d.class.Interfaces {
+		iface, err := className(c)
+		if err != nil {
+			return fmt.Errorf("interface %d: %w", i, err)
+		}
+		d.printf(".implements %s\n", iface)
+	}
+
+	for _, h := range data.SortedHandles(d.class.Attributes) {
+		if h.AttributeTag != data.ATTR_SOURCE_FILE {
+			d.printf("; %s not represented\n", h.AttributeTag)
+			continue
+		}
+
+		attr, err := d.resolve(h)
+		if err != nil {
+			return err
+		}
+		d.printf(".source %s\n", attr.AttributeSourceFile().SourceFile.Value)
+	}
+
+	if len(d.class.Fields) > 0 {
+		d.printf("\n")
+	}
+
+	for _, f := range d.class.Fields {
+		d.printf(".field %s\n", strings.Join(append(f.AccessFlags.Keywords(data.FIELD_FLAGS), f.Name.Value, f.Descriptor.Value), " "))
+		for _, h := range data.SortedHandles(f.Attributes) {
+			d.printf("; %s not represented\n", h.AttributeTag)
+		}
+	}
+
+	for _, m := range d.class.Methods {
+		d.printf("\n.method %s\n", strings.Join(append(m.AccessFlags.Keywords(data.METHOD_FLAGS), m.Name.Value+m.Descriptor.Value), " "))
+
+		if err := d.method(m); err != nil {
+			return fmt.Errorf("method %s%s: %w", m.Name.Value, m.Descriptor.Value, err)
+		}
+
+		d.printf(".end method\n")
+	}
+
+	return nil
+}
+
+// frames reports whether any method has a StackMapTable, which classes of
+// version 50.0 onwards are verified against.
+func (d *disassembler) frames() (bool, error) {
+	for _, m := range d.class.Methods {
+		h, ok := m.Attributes[data.ATTR_CODE]
+		if !ok {
+			continue
+		}
+
+		attr, err := d.resolve(h)
+		if err != nil {
+			return false, fmt.Errorf("method %s%s: %w", m.Name.Value, m.Descriptor.Value, err)
+		}
+
+		for _, a := range attr.AttributeCode().Attributes {
+			if a.AttributeTag == data.ATTR_STACK_MAP_TABLE {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (d *disassembler) method(m data.MemberInfo) error {
+	for _, h := range data.SortedHandles(m.Attributes) {
+		if h.AttributeTag != data.ATTR_CODE {
+			d.printf("  ; %s not represented\n", h.AttributeTag)
+		}
+	}
+
+	h, ok := m.Attributes[data.ATTR_CODE]
+	if !ok {
+		return nil
+	}
+
+	attr, err := d.resolve(h)
+	if err != nil {
+		return err
+	}
+	code := attr.AttributeCode()
+
+	bc, err := d.resolve(&code.CodeHandle)
+	if err != nil {
+		return err
+	}
+	ops := bc.Bytecode().Ops
+
+	// Position of every op, and one past the last
+	pcs := make([]int, len(ops)+1)
+	for i, op := range ops {
+		if n, err := op.Code.NArgs(); err != nil || n != len(op.Arg) {
+			return fmt.Errorf("malformed %s at %d", op.Code, pcs[i])
+		}
+		pcs[i+1] = pcs[i] + 1 + len(op.Arg)
+	}
+
+	var (
+		lines  []data.LineNumber
+		vars   []data.LocalVariable
+		labels = make(map[int]bool)
+	)
+
+	for _, a := range code.Attributes {
+		switch a.AttributeTag {
+		case data.ATTR_LINE_NUMBER_TABLE, data.ATTR_LOCAL_VARIABLE_TABLE:
+			attr, err := d.resolve(&a)
+			if err != nil {
+				return err
+			}
+
+			if a.AttributeTag == data.ATTR_LINE_NUMBER_TABLE {
+				lines = append(lines, attr.AttributeLineNumberTable().LineNumbers...)
+			} else {
+				vars = append(vars, attr.AttributeLocalVariableTable().LocalVariables...)
+			}
+		default:
+			d.printf("  ; %s not represented\n", a.AttributeTag)
+		}
+	}
+
+	for _, e := range code.ExceptionTable {
+		labels[int(e.StartPC)], labels[int(e.EndPC)], labels[int(e.HandlerPC)] = true, true, true
+	}
+
+	for _, v := range vars {
+		labels[int(v.StartPC)], labels[int(v.StartPC)+int(v.Length)] = true, true
+	}
+
+	for i, op := range ops {
+		if offset, ok := op.BranchOffset(); ok {
+			labels[pcs[i]+offset] = true
+		}
+	}
+
+	for pc := range labels {
+		if _, found := slices.BinarySearch(pcs, pc); !found {
+			return fmt.Errorf("label at %d is not on an instruction", pc)
+		}
+	}
+
+	for _, l := range lines {
+		if _, found := slices.BinarySearch(pcs, int(l.StartPC)); !found || int(l.StartPC) == pcs[len(ops)] {
+			return fmt.Errorf("line %d at %d is not on an instruction", l.LineNumber, l.StartPC)
+		}
+	}
+
+	d.printf("  .limit stack %d\n", code.MaxStack)
+	d.printf("  .limit locals %d\n", code.MaxLocals)
+
+	for i, op := range ops {
+		pc := pcs[i]
+		if labels[pc] {
+			d.printf("L%d:\n", pc)
+		}
+
+		for _, l := range lines {
+			if int(l.StartPC) == pc {
+				d.printf("  .line %d\n", l.LineNumber)
+			}
+		}
+
+		instr, err := Instruction(d.class, pc, op)
+		if err != nil {
+			return err
+		}
+		d.printf("  %s\n", instr)
+	}
+
+	if end := pcs[len(ops)]; labels[end] {
+		d.printf("L%d:\n", end)
+	}
+
+	for _, e := range code.ExceptionTable {
+		catch := "all"
+		if e.CatchType != nil {
+			if catch, err = className(e.CatchType); err != nil {
+				return err
+			}
+		}
+		d.printf("  .catch %s from L%d to L%d using L%d\n", catch, e.StartPC, e.EndPC, e.HandlerPC)
+	}
+
+	for _, v := range vars {
+		d.printf("  .var %d is %s %s from L%d to L%d\n", v.Index, v.Name.Value, v.Descriptor.Value, v.StartPC, int(v.StartPC)+int(v.Length))
+	}
+
+	return nil
+}
+
+// Instruction is op at pc, an instruction of a method of class, in the text
+// format, with its operand resolved, e.g. "invokestatic pkg/C/m(I)I". Branch
+// targets are labels of their pc, as in L12.
+func Instruction(class *data.Class, pc int, op data.Op) (string, error) {
+	operand, err := operand(class, pc, op)
+	if err != nil {
+		return "", fmt.Errorf("%s at %d: %w", op.Code, pc, err)
+	}
+
+	if operand == "" {
+		return op.Code.String(), nil
+	}
+	return op.Code.String() + " " + operand, nil
+}
+
+// operand of op at pc in the text format.
+func operand(class *data.Class, pc int, op data.Op) (string, error) {
+	switch OperandOf(op.Code) {
+	case BYTE:
+		return strconv.Itoa(int(int8(op.Arg[0]))), nil
+	case SHORT:
+		return strconv.Itoa(int(int16(uint16(op.Arg[0])<<8 | uint16(op.Arg[1])))), nil
+	case LOCAL:
+		return strconv.Itoa(int(op.Arg[0])), nil
+	case INCREMENT:
+		return fmt.Sprintf("%d %d", op.Arg[0], int8(op.Arg[1])), nil
+	case ARRAY_TYPE:
+		if int(op.Arg[0]) >= len(arrayTypes) || arrayTypes[op.Arg[0]] == "" {
+			return "", fmt.Errorf("unknown array type %d", op.Arg[0])
+		}
+		return arrayTypes[op.Arg[0]], nil
+	case BRANCH:
+		offset, _ := op.BranchOffset()
+		return fmt.Sprintf("L%d", pc+offset), nil
+	case NONE:
+		return "", nil
+	}
+
+	i, _ := op.ConstantIndex()
+	if i == 0 || int(i) > len(class.ConstantPool) || class.ConstantPool[i-1] == nil {
+		return "", fmt.Errorf("constant index %d out of range", i)
+	}
+	c := class.ConstantPool[i-1]
+
+	switch OperandOf(op.Code) {
+	case CONSTANT:
+		switch c.Tag() {
+		case data.CP_INTEGER:
+			return strconv.Itoa(int(c.ConstantInteger().Value)), nil
+		case data.CP_FLOAT:
+			return strconv.FormatFloat(float64(c.ConstantFloat().Value), 'g', -1, 32) + "f", nil
+		case data.CP_STRING:
+			s, err := utf8(c.ConstantString().Value)
+			return strconv.Quote(s), err
+		case data.CP_CLASS:
+			name, err := className(c.ConstantClass())
+			return "class " + name, err
+		}
+	case FIELD:
+		if c.Tag() == data.CP_FIELDREF {
+			return member(c.ConstantFieldref().Clazz, c.ConstantFieldref().NameAndType, " ")
+		}
+	case METHOD:
+		switch c.Tag() {
+		case data.CP_METHODREF:
+			return member(c.ConstantMethodref().Clazz, c.ConstantMethodref().NameAndType, "")
+		case data.CP_INTERFACE_METHODREF:
+			m, err := member(c.ConstantInterfaceMethodref().Clazz, c.ConstantInterfaceMethodref().NameAndType, "")
+			return "interface " + m, err
+		}
+	case CLASS:
+		if c.Tag() == data.CP_CLASS {
+			return className(c.ConstantClass())
+		}
+	}
+
+	return "", fmt.Errorf("unexpected %s operand", c.Tag())
+}
+
+func utf8(r *data.Data) (string, error) {
+	if r == nil || *r == nil || (*r).Tag() != data.CP_UTF8 {
+		return "", fmt.Errorf("broken reference to a utf8 constant")
+	}
+	return (*r).ConstantUtf8().Value, nil
+}
+
+func className(c *data.ConstantClass) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("missing class")
+	}
+	return utf8(c.Name)
+}
+
+// member formats a member reference as class/name, followed by sep and its
+// descriptor.
+func member(class, nameAndType *data.Data, sep string) (string, error) {
+	if class == nil || *class == nil || (*class).Tag() != data.CP_CLASS ||
+		nameAndType == nil || *nameAndType == nil || (*nameAndType).Tag() != data.CP_NAME_AND_TYPE {
+		return "", fmt.Errorf("broken member reference")
+	}
+
+	c, err := className((*class).ConstantClass())
+	if err != nil {
+		return "", err
+	}
+
+	nat := (*nameAndType).ConstantNameAndType()
+	name, err := utf8(nat.Name)
+	if err != nil {
+		return "", err
+	}
+
+	descriptor, err := utf8(nat.Descriptor)
+	if err != nil {
+		return "", err
+	}
+
+	return c + "/" + name + sep + descriptor, nil
+}
@@ -0,0 +1,115 @@
+package assembler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// Operand is the kind of operand an instruction takes in the text format.
+type Operand int
+
+const (
+	NONE       Operand = iota
+	BYTE               // bipush 10
+	SHORT              // sipush 1000
+	LOCAL              // iload 4
+	CONSTANT           // ldc "hello", ldc 42, ldc 1.5f, ldc class java/lang/String
+	INCREMENT          // iinc 1 -1
+	ARRAY_TYPE         // newarray int
+	BRANCH             // goto L12
+	FIELD              // getstatic java/lang/System/out Ljava/io/PrintStream;
+	METHOD             // invokestatic java/lang/Math/abs(I)I, invokestatic interface pkg/I/m()V
+	CLASS              // new java/lang/Object
+)
+
+// OperandOf op in the text format.
+func OperandOf(op data.OpCode) Operand {
+	switch op {
+	case data.OP_BIPUSH:
+		return BYTE
+	case data.OP_SIPUSH:
+		return SHORT
+	case data.OP_ILOAD, data.OP_ALOAD, data.OP_ISTORE, data.OP_ASTORE:
+		return LOCAL
+	case data.OP_LDC:
+		return CONSTANT
+	case data.OP_IINC:
+		return INCREMENT
+	case data.OP_NEWARRAY:
+		return ARRAY_TYPE
+	case data.OP_IFEQ, data.OP_IFNE, data.OP_IFGE, data.OP_IFGT, data.OP_IF_ICMPEQ, data.OP_IF_ICMPNE,
+		data.OP_IF_ICMPLT, data.OP_IF_ICMPGE, data.OP_IF_ICMPGT, data.OP_IF_ICMPLE, data.OP_IF_ACMPEQ,
+		data.OP_IF_ACMPNE, data.OP_IFNULL, data.OP_IFNONNULL, data.OP_GOTO:
+		return BRANCH
+	case data.OP_GETSTATIC, data.OP_PUTSTATIC, data.OP_GETFIELD, data.OP_PUTFIELD:
+		return FIELD
+	case data.OP_INVOKEVIRTUAL, data.OP_INVOKESPECIAL, data.OP_INVOKESTATIC:
+		return METHOD
+	case data.OP_NEW, data.OP_ANEWARRAY:
+		return CLASS
+	default:
+		return NONE
+	}
+}
+
+// opcodes by mnemonic, for every instruction the data model knows.
+var opcodes = func() map[string]data.OpCode {
+	ops := make(map[string]data.OpCode)
+	for b := range 256 {
+		op := data.OpCode(b)
+		if _, err := op.NArgs(); err == nil {
+			ops[op.String()] = op
+		}
+	}
+	return ops
+}()
+
+var arrayTypes = []string{4: "boolean", "char", "float", "double", "byte", "short", "int", "long"}
+
+// tokenize splits a line into whitespace separated tokens, keeping quoted
+// strings whole and dropping comments, which start with a token beginning
+// with ';' so that descriptors such as "Ljava/lang/Object;" are kept.
+func tokenize(line string) ([]string, error) {
+	var tokens []string
+	for {
+		line = strings.TrimLeft(line, " \t\r")
+		if line == "" || line[0] == ';' {
+			return tokens, nil
+		}
+
+		if line[0] == '"' {
+			q, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("unterminated string %s", line)
+			}
+			tokens = append(tokens, q)
+			line = line[len(q):]
+			continue
+		}
+
+		end := strings.IndexAny(line, " \t\r")
+		if end < 0 {
+			end = len(line)
+		}
+		tokens = append(tokens, line[:end])
+		line = line[end:]
+	}
+}
+
+// splitMember splits a member reference such as "java/lang/Object/<init>()V"
+// into its class, name and descriptor. Fields have their descriptor apart.
+func splitMember(ref string) (class, name, descriptor string, err error) {
+	if i := strings.IndexByte(ref, '('); i >= 0 {
+		ref, descriptor = ref[:i], ref[i:]
+	}
+
+	i := strings.LastIndexByte(ref, '/')
+	if i <= 0 || i == len(ref)-1 {
+		return "", "", "", fmt.Errorf("member reference %q is not class/name", ref)
+	}
+
+	return ref[:i], ref[i+1:], descriptor, nil
+}
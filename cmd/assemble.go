@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+	"github.com/luishfonseca/dtu_pa/writer"
+
+	"github.com/spf13/cobra"
+)
+
+var assembleOutput string
+
+// assembleCmd represents the assemble command
+var assembleCmd = &cobra.Command{
+	Use:   "assemble [flags] file",
+	Short: "Build a class file from its textual form, as printed by inspect --disassemble",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := assemble(args[0], assembleOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func assemble(source, output string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	class, resolve, err := assembler.Assemble(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	if output == "" {
+		output = strings.TrimSuffix(source, filepath.Ext(source)) + ".class"
+	}
+
+	b, err := writer.Bytes(class, resolve)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(output, b, 0o644)
+}
+
+func init() {
+	rootCmd.AddCommand(assembleCmd)
+
+	assembleCmd.Flags().StringVarP(&assembleOutput, "output", "o", "", "class file to write (default: the source file with a .class extension)")
+}
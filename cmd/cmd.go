@@ -1,6 +1,16 @@
 package cmd
 
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
 // Execute adds all child commands to the root command and sets flags appropriately.
+// The context passed to the commands is cancelled on interrupt.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return rootCmd.ExecuteContext(ctx)
 }
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/coverage"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	coverageClasspath string
+	coverageSteps     int
+	coverageDepth     int
+	coverageHeap      int
+	coverageInputs    string
+	coverageLCOV      string
+	coverageRoot      string
+)
+
+// coverageCmd represents the coverage command
+var coverageCmd = &cobra.Command{
+	Use:   "coverage [flags] pkg.Class.method:(desc) '(inputs)'...",
+	Short: "Run a method on several inputs and print which instructions and branches they cover",
+	Long: `Run a static method on each of the inputs, as interpret does, given as
+arguments or one per line in the file of --inputs, e.g.
+
+  dtu_pa coverage 'jpamb.cases.Simple.checkBeforeDivideByN:(I)I' '(0)' '(1)'
+
+Prints the outcome of each run, then the disassembly of the method and of
+the methods it invoked, each instruction preceded by the times it ran, or
+##### if it never did, and each if* and goto followed by the times it was
+taken and not taken.
+
+With --lcov, also writes the coverage of every class run to that file in
+the lcov format, mapped to source lines by their LineNumberTable, with source
+files named after the package of their class under --source-root.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cover(cmd, args[0], args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func cover(cmd *cobra.Command, selector string, inputs []string) error {
+	if coverageInputs != "" {
+		b, err := os.ReadFile(coverageInputs)
+		if err != nil {
+			return err
+		}
+		for line := range strings.Lines(string(b)) {
+			if line = strings.TrimSpace(line); line != "" {
+				inputs = append(inputs, line)
+			}
+		}
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("no inputs to run the method on")
+	}
+
+	cp, m, err := load(cmd, selector, coverageClasspath)
+	if err != nil {
+		return err
+	}
+
+	cov := coverage.New()
+	out := cmd.OutOrStdout()
+
+	// runs that fail are reported, and the coverage of the others kept
+	var failed []error
+	for _, input := range inputs {
+		in, err := start(cmd, cp, m, input, coverageDepth, coverageHeap, interpreter.WithObserver(cov))
+		if err != nil {
+			fmt.Fprintf(out, "%s: error: %v\n", input, err)
+			failed = append(failed, fmt.Errorf("%s: %w", input, err))
+			continue
+		}
+
+		t, err := in.Execute(coverageSteps)
+		if err != nil {
+			fmt.Fprintf(out, "%s: error: %v\n", input, err)
+			failed = append(failed, fmt.Errorf("%s: %w", input, err))
+			continue
+		}
+		fmt.Fprintf(out, "%s: %s\n", input, t.Outcome)
+	}
+
+	// the method covered first, then those it invoked, and the classes of
+	// any of them in the lcov file
+	methods := []*interpreter.Method{m}
+	var classes []*interpreter.Class
+	for _, c := range cp.Classes() {
+		ran := c.Name == m.ClassName
+		for _, invoked := range c.Methods() {
+			if len(cov.Counts(invoked).Hits) > 0 {
+				ran = true
+				if invoked != m {
+					methods = append(methods, invoked)
+				}
+			}
+		}
+		if ran {
+			classes = append(classes, c)
+		}
+	}
+
+	for _, method := range methods {
+		fmt.Fprintln(out)
+		if err := cov.Annotate(out, method); err != nil {
+			return err
+		}
+	}
+
+	if coverageLCOV != "" {
+		f, err := os.Create(coverageLCOV)
+		if err != nil {
+			return err
+		}
+		if err := cov.WriteLCOV(f, classes, coverageRoot); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(failed...)
+}
+
+func init() {
+	rootCmd.AddCommand(coverageCmd)
+
+	coverageCmd.Flags().StringVar(&coverageClasspath, "classpath", ".", "directories and jars to look up classes in, separated as in java -cp")
+	coverageCmd.Flags().IntVar(&coverageSteps, "steps", 100000, "instructions to run before giving up with *")
+	coverageCmd.Flags().IntVar(&coverageDepth, "max-depth", interpreter.DefaultMaxDepth, "frames on the call stack before a StackOverflowError")
+	coverageCmd.Flags().IntVar(&coverageHeap, "max-heap", interpreter.DefaultMaxHeap, "elements the arrays allocated may hold in all before an OutOfMemoryError")
+	coverageCmd.Flags().StringVar(&coverageInputs, "inputs", "", "file of inputs, one per line, to run besides those given as arguments")
+	coverageCmd.Flags().StringVar(&coverageLCOV, "lcov", "", "file to write the coverage to in the lcov format, if any")
+	coverageCmd.Flags().StringVar(&coverageRoot, "source-root", "", "directory the source files named in the lcov file are under")
+}
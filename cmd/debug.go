@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/luishfonseca/dtu_pa/debugger"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	debugClasspath string
+	debugSteps     int
+	debugDepth     int
+	debugHeap      int
+)
+
+// debugCmd represents the debug command
+var debugCmd = &cobra.Command{
+	Use:   "debug [flags] pkg.Class.method:(desc) '(inputs)'",
+	Short: "Run a method on inputs one instruction at a time, at a prompt",
+	Long: `Run a static method on inputs, as interpret does, under the control of
+commands read from standard input, e.g.
+
+  dtu_pa debug 'jpamb.cases.Simple.divideByN:(I)I' '(0)'
+
+Each instruction is shown with its operands resolved and its source line.
+The commands step, next and continue run it, break stops it at a pc or on
+arriving at a source line, and stack, locals, heap and frames print the
+state of the machine. Type help at the prompt for all of them.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := debug(cmd, args[0], args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func debug(cmd *cobra.Command, selector, inputs string) error {
+	cp, m, err := load(cmd, selector, debugClasspath)
+	if err != nil {
+		return err
+	}
+
+	in, err := start(cmd, cp, m, inputs, debugDepth, debugHeap)
+	if err != nil {
+		return err
+	}
+
+	return debugger.New(in, cmd.OutOrStdout(), debugSteps).Run(cmd.InOrStdin())
+}
+
+func init() {
+	rootCmd.AddCommand(debugCmd)
+
+	debugCmd.Flags().StringVar(&debugClasspath, "classpath", ".", "directories and jars to look up classes in, separated as in java -cp")
+	debugCmd.Flags().IntVar(&debugSteps, "steps", 100000, "instructions to run before giving up with *")
+	debugCmd.Flags().IntVar(&debugDepth, "max-depth", interpreter.DefaultMaxDepth, "frames on the call stack before a StackOverflowError")
+	debugCmd.Flags().IntVar(&debugHeap, "max-heap", interpreter.DefaultMaxHeap, "elements the arrays allocated may hold in all before an OutOfMemoryError")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/luishfonseca/dtu_pa/analyser"
+	"github.com/luishfonseca/dtu_pa/parser"
+
+	"github.com/spf13/cobra"
+)
+
+var diffLenient bool
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff [flags] a.class b.class",
+	Short: "Print the structural differences between two compiled java classes",
+	Long: `Print the structural differences between two compiled java classes:
+members added (+), removed (-) or changed (~), and for changed methods a
+diff of their instructions, with the constants they use resolved so that
+reordering the constant pool makes no difference, and branch targets and
+exception handlers named by labels so that inserting code only shows the
+insertion.
+
+Exits with 0 if the classes are the same, 1 if they differ and 2 on error.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts []parser.Option
+		if diffLenient {
+			opts = append(opts, parser.Lenient())
+		}
+
+		sources, err := analyser.Sources(args, cmd.InOrStdin(), parser.DefaultLimits().MaxBytesRead)
+		if err == nil && len(sources) != 2 {
+			err = fmt.Errorf("expected two classes, found %d", len(sources))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+
+		a, b := analyser.NewSource(sources[0], opts...), analyser.NewSource(sources[1], opts...)
+		changed, err := a.Diff(cmd.Context(), b)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+
+		if changed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().BoolVar(&diffLenient, "lenient", false, "recover from damaged structures, comparing what could be parsed")
+}
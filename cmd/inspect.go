@@ -1,44 +1,188 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/luishfonseca/dtu_pa/analyser"
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/parser"
+	"github.com/luishfonseca/dtu_pa/state"
 
 	"github.com/spf13/cobra"
 
+	"io"
 	"os"
-	"path/filepath"
+	"runtime"
+)
+
+var (
+	inspectLenient bool
+	inspectDisasm  bool
+	inspectCode    bool
+	inspectVerbose bool
+	inspectHexdump bool
+	inspectMethods []string
+	inspectFields  bool
+	inspectConsts  bool
+	inspectNoCode  bool
+	inspectSelect  analyser.Selection
+	inspectFormat  string
+	inspectTrace   bool
+	inspectTimeout time.Duration
+	inspectJobs    int
+	inspectLimits  = parser.DefaultLimits()
+	inspectSources []analyser.Source
 )
 
 // inspectCmd represents the inspect command
 var inspectCmd = &cobra.Command{
-	Use:   "inspect [flags] file",
-	Short: "Print the parsed compiled java class in a human readable format",
-	Args:  cobra.ExactArgs(1),
-	PreRunE: func(_ *cobra.Command, args []string) (err error) {
-		if filepath.Ext(args[0]) != ".class" {
-			return fmt.Errorf("file %s must have a .class extension", args[0])
+	Use:   "inspect [flags] file|dir|jar|- ...",
+	Short: "Print the parsed compiled java classes in a human readable format",
+	Long: `Print the parsed compiled java classes in a human readable format.
+
+Directories are searched recursively for .class files, jars are read for
+their .class entries and - reads a class from stdin. Classes are parsed in
+parallel but printed in order, followed by a summary of those that failed
+when there are several. Of several classes, those without a method picked by
+--method are skipped, and it is only an error if none has one.`,
+	Args: cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
+		switch inspectFormat {
+		case "text":
+		case "json":
+			if inspectDisasm || inspectCode || inspectVerbose || inspectHexdump {
+				return fmt.Errorf("--format json cannot be combined with -S, -c, -v or --hexdump")
+			}
+		default:
+			return fmt.Errorf("unknown format %q, expected text or json", inspectFormat)
 		}
 
-		if _, err = os.Stat(args[0]); os.IsNotExist(err) {
-			return fmt.Errorf("file %s does not exist", args[0])
+		inspectSelect = analyser.Selection{Fields: inspectFields, Constants: inspectConsts, NoCode: inspectNoCode}
+		for _, m := range inspectMethods {
+			sel, err := data.ParseSelector(m)
+			if err != nil {
+				return err
+			}
+			inspectSelect.Methods = append(inspectSelect.Methods, sel)
 		}
 
-		if args[0], err = filepath.Abs(args[0]); err != nil {
-			return fmt.Errorf("could not get absolute path of %s: %w", args[0], err)
+		if (inspectDisasm || inspectHexdump) && (len(inspectMethods) > 0 || inspectFields || inspectConsts || inspectNoCode) {
+			return fmt.Errorf("-S and --hexdump show the whole class and cannot be combined with --method, --fields, --constants or --no-code")
+		}
+
+		inspectSources, err = analyser.Sources(args, cmd.InOrStdin(), inspectLimits.MaxBytesRead)
+		if err != nil {
+			return err
+		}
+
+		if len(inspectSources) == 0 {
+			return fmt.Errorf("no class files found")
 		}
 
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := analyser.New(args[0]).Inspect(); err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		var failures []analyser.Result
+		unmatched := 0
+		analyser.Batch(cmd.Context(), inspectSources, inspectJobs, inspect, func(r analyser.Result) {
+			if len(inspectSources) > 1 && errors.Is(r.Err, analyser.ErrNoMatch) {
+				unmatched++
+				return
+			}
+
+			if len(inspectSources) > 1 && inspectFormat == "text" {
+				fmt.Printf("==> %s <==\n", r.Source.Name)
+			}
+			os.Stdout.Write(r.Output)
+			os.Stderr.Write(r.Warnings)
+
+			if r.Err != nil {
+				failures = append(failures, r)
+			}
+		})
+
+		if len(inspectSources) == 1 && len(failures) == 1 {
+			fmt.Fprintf(os.Stderr, "error: %v\n", failures[0].Err)
+		} else if len(inspectSources) > 1 {
+			fmt.Fprintf(os.Stderr, "%d classes: %d parsed, %d failed", len(inspectSources), len(inspectSources)-len(failures), len(failures))
+			if unmatched > 0 {
+				fmt.Fprintf(os.Stderr, ", %d with no method selected", unmatched)
+			}
+			fmt.Fprintln(os.Stderr)
+			for _, r := range failures {
+				fmt.Fprintf(os.Stderr, "  %s: %v\n", r.Source.Name, r.Err)
+			}
+		}
+
+		parsed := len(inspectSources) - len(failures)
+		if unmatched > 0 && unmatched == parsed {
+			fmt.Fprintf(os.Stderr, "error: no class has a method matching %s\n", strings.Join(inspectMethods, ", "))
+			os.Exit(1)
+		}
+
+		if len(failures) > 0 {
 			os.Exit(1)
 		}
 	},
 }
 
+// inspect prints src with the view selected by the flags.
+func inspect(ctx context.Context, src analyser.Source, out, warn io.Writer) error {
+	if inspectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, inspectTimeout)
+		defer cancel()
+	}
+
+	opts := []parser.Option{parser.WithLimits(inspectLimits)}
+	if inspectLenient {
+		opts = append(opts, parser.Lenient())
+	}
+
+	if inspectTrace {
+		opts = append(opts, parser.WithObserver(state.Tracer{W: warn}))
+	}
+
+	a := analyser.NewSource(src, opts...).Select(inspectSelect).Output(out, warn)
+	switch {
+	case inspectFormat == "json":
+		return a.JSON(ctx)
+	case inspectHexdump:
+		return a.Hexdump(ctx)
+	case inspectDisasm:
+		return a.Disassemble(ctx)
+	case inspectCode, inspectVerbose:
+		return a.Javap(ctx, inspectVerbose)
+	default:
+		return a.Inspect(ctx)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(inspectCmd)
+
+	inspectCmd.Flags().BoolVar(&inspectLenient, "lenient", false, "recover from damaged structures and list every problem found")
+	inspectCmd.Flags().BoolVarP(&inspectDisasm, "disassemble", "S", false, "print the class in the text format read by assemble")
+	inspectCmd.Flags().BoolVarP(&inspectCode, "code", "c", false, "print the class and its code like javap -c")
+	inspectCmd.Flags().BoolVarP(&inspectVerbose, "verbose", "v", false, "print everything in the class like javap -c -v")
+	inspectCmd.Flags().BoolVar(&inspectHexdump, "hexdump", false, "print the bytes of the file annotated with the structures they hold")
+	inspectCmd.MarkFlagsMutuallyExclusive("disassemble", "code")
+	inspectCmd.MarkFlagsMutuallyExclusive("disassemble", "verbose")
+	inspectCmd.MarkFlagsMutuallyExclusive("hexdump", "disassemble", "code", "verbose")
+	inspectCmd.Flags().StringArrayVar(&inspectMethods, "method", nil, "print only the methods selected by name, name:(desc) or pkg.Class.name:(desc); repeatable")
+	inspectCmd.Flags().BoolVar(&inspectFields, "fields", false, "print the fields (only them, unless --method or --constants are given)")
+	inspectCmd.Flags().BoolVar(&inspectConsts, "constants", false, "print the constant pool (only it, unless --method or --fields are given)")
+	inspectCmd.Flags().BoolVar(&inspectNoCode, "no-code", false, "leave out the code of methods")
+	inspectCmd.Flags().StringVar(&inspectFormat, "format", "text", "output format: text or json (see analyser.ClassJSON for the schema)")
+	inspectCmd.Flags().BoolVar(&inspectTrace, "trace-parser", false, "print every parser state transition and the file offset to stderr")
+	inspectCmd.Flags().DurationVar(&inspectTimeout, "timeout", 0, "abort parsing a class after this long (0 disables)")
+	inspectCmd.Flags().IntVarP(&inspectJobs, "jobs", "j", runtime.NumCPU(), "number of classes to parse in parallel")
+	inspectCmd.Flags().Uint16Var(&inspectLimits.MaxConstantPool, "max-constant-pool", inspectLimits.MaxConstantPool, "maximum constant pool count (0 disables)")
+	inspectCmd.Flags().Uint32Var(&inspectLimits.MaxAttributeSize, "max-attribute-size", inspectLimits.MaxAttributeSize, "maximum attribute length in bytes (0 disables)")
+	inspectCmd.Flags().Uint32Var(&inspectLimits.MaxCodeLength, "max-code-length", inspectLimits.MaxCodeLength, "maximum code length in bytes (0 disables)")
+	inspectCmd.Flags().Int64Var(&inspectLimits.MaxBytesRead, "max-bytes", inspectLimits.MaxBytesRead, "maximum bytes read from the class file (0 disables)")
 }
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	interpretClasspath string
+	interpretSteps     int
+	interpretDepth     int
+	interpretHeap      int
+)
+
+// interpretCmd represents the interpret command
+var interpretCmd = &cobra.Command{
+	Use:   "interpret [flags] pkg.Class.method:(desc) '(inputs)'",
+	Short: "Run a method on inputs and print how it terminates",
+	Long: `Run a static method on inputs, given in the syntax of jpamb, e.g.
+
+  dtu_pa interpret 'jpamb.cases.Simple.divideByN:(I)I' '(0)'
+  dtu_pa interpret 'jpamb.cases.Arrays.first:([I)I' '([I:1,2,3])'
+
+Inputs are ints, true or false, chars such as 'a', arrays such as [C:'a','b']
+and null, and must match the descriptor of the method. The class is looked
+up on the classpath, as are the classes of the methods it invokes; methods
+of the JDK are run by models in Go, those of interpreter.DefaultNatives, and
+invoking one without a model is an error.
+
+Prints the return value, or the stack trace of an uncaught exception, and
+the number of steps taken, then the outcome on the last line: ok, assertion
+error, divide by zero, out of bounds, null pointer, or * when the step
+budget runs out or the call stack overflows.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := interpret(cmd, args[0], args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func interpret(cmd *cobra.Command, selector, inputs string) error {
+	cp, m, err := load(cmd, selector, interpretClasspath)
+	if err != nil {
+		return err
+	}
+
+	in, err := start(cmd, cp, m, inputs, interpretDepth, interpretHeap)
+	if err != nil {
+		return err
+	}
+
+	t, err := in.Execute(interpretSteps)
+	if err != nil {
+		if t.Thrown != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Exception in thread \"main\" %s", t.Thrown.StackTrace())
+		}
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	switch {
+	case t.Outcome == interpreter.OK:
+		_, ret, _ := data.SplitMethodDescriptor(m.Info.Descriptor.Value)
+		fmt.Fprintf(out, "returned %s in %d steps, at pc %d\n", in.Format(t.Result, ret), in.Steps, t.PC)
+	case t.Outcome == interpreter.NON_TERMINATION && t.Thrown == nil:
+		fmt.Fprintf(out, "ran out of %d steps, at pc %d\n", in.Steps, t.PC)
+	default:
+		fmt.Fprintf(out, "Exception in thread \"main\" %s", t.Thrown.StackTrace())
+		fmt.Fprintf(out, "threw in %d steps, at pc %d\n", in.Steps, t.PC)
+	}
+	fmt.Fprintln(out, t.Outcome)
+
+	return nil
+}
+
+// load the method of selector, looking its class up on classpath.
+func load(cmd *cobra.Command, selector, classpath string) (*interpreter.Classpath, *interpreter.Method, error) {
+	sel, err := data.ParseSelector(selector)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sel.Class == "" {
+		return nil, nil, fmt.Errorf("method %s must be qualified by its class", sel)
+	}
+
+	cp := interpreter.ParseClasspath(classpath)
+	class, err := cp.Load(cmd.Context(), sel.Class)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := class.Method(sel)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cp, m, nil
+}
+
+// start an interpreter configured by opts on m, with inputs as arguments.
+func start(cmd *cobra.Command, cp *interpreter.Classpath, m *interpreter.Method, inputs string, depth, heap int, opts ...interpreter.Option) (*interpreter.Interpreter, error) {
+	values, err := interpreter.ParseInputs(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]interpreter.Option{interpreter.WithClasspath(cp), interpreter.WithContext(cmd.Context()), interpreter.WithMaxDepth(depth), interpreter.WithMaxHeap(heap)}, opts...)
+	in := interpreter.New(opts...)
+	args, err := in.Args(m, values)
+	if err != nil {
+		return nil, err
+	}
+	if err := in.Start(m, args); err != nil {
+		return nil, err
+	}
+
+	return in, nil
+}
+
+func init() {
+	rootCmd.AddCommand(interpretCmd)
+
+	interpretCmd.Flags().StringVar(&interpretClasspath, "classpath", ".", "directories and jars to look up classes in, separated as in java -cp")
+	interpretCmd.Flags().IntVar(&interpretSteps, "steps", 100000, "instructions to run before giving up with *")
+	interpretCmd.Flags().IntVar(&interpretDepth, "max-depth", interpreter.DefaultMaxDepth, "frames on the call stack before a StackOverflowError")
+	interpretCmd.Flags().IntVar(&interpretHeap, "max-heap", interpreter.DefaultMaxHeap, "elements the arrays allocated may hold in all before an OutOfMemoryError")
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/luishfonseca/dtu_pa/trace"
+
+	"github.com/spf13/cobra"
+)
+
+var replayAll bool
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay [flags] trace",
+	Short: "View a trace recorded by the trace command, one instruction at a time",
+	Long: `View a trace recorded by the trace command, in either format, going
+forward and back through the instructions run as asked by commands read
+from standard input. Type help at the prompt for them. With --all, every
+instruction is printed at once instead.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := replay(cmd, args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func replay(cmd *cobra.Command, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	t, err := trace.Read(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	if replayAll {
+		return trace.Print(cmd.OutOrStdout(), t)
+	}
+	return trace.Replay(t, cmd.InOrStdin(), cmd.OutOrStdout())
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().BoolVar(&replayAll, "all", false, "print every instruction of the trace and exit")
+}
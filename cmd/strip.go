@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/analyser"
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/parser"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	stripAttributes []string
+	stripOutput     string
+	stripDryRun     bool
+	stripLenient    bool
+)
+
+// stripCmd represents the strip command
+var stripCmd = &cobra.Command{
+	Use:   "strip [flags] file|dir|- ...",
+	Short: "Remove debug attributes and unused constants from compiled java classes",
+	Long: `Remove debug attributes and unused constants from compiled java classes.
+
+Classes are rewritten in place, or to the file given by -o when there is only
+one; a class read from stdin (-) is written to stdout. The size saved is
+reported for every class. Of several classes, those that fail are skipped and
+listed at the end.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := strip(cmd, args); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func strip(cmd *cobra.Command, args []string) error {
+	var remove []data.Tag
+	for _, name := range stripAttributes {
+		tag, err := analyser.AttributeTag(name)
+		if err != nil {
+			return err
+		}
+		remove = append(remove, tag)
+	}
+
+	sources, err := analyser.Sources(args, cmd.InOrStdin(), parser.DefaultLimits().MaxBytesRead)
+	if err != nil {
+		return err
+	}
+
+	if stripOutput != "" && len(sources) != 1 {
+		return fmt.Errorf("-o needs exactly one class, found %d", len(sources))
+	}
+
+	var opts []parser.Option
+	if stripLenient {
+		opts = append(opts, parser.Lenient())
+	}
+
+	var before, after int
+	var failed []error
+	for _, src := range sources {
+		b, a, err := stripClass(cmd, src, remove, opts)
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", src.Name, err))
+			continue
+		}
+
+		before, after = before+b, after+a
+		fmt.Fprintf(os.Stderr, "%s: %s\n", src.Name, saved(b, a))
+	}
+
+	if len(sources) == 1 {
+		return errors.Join(failed...)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d classes: %d stripped, %d failed, %s\n", len(sources), len(sources)-len(failed), len(failed), saved(before, after))
+	for _, err := range failed {
+		fmt.Fprintf(os.Stderr, "  %v\n", err)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d classes could not be stripped", len(failed), len(sources))
+	}
+
+	return nil
+}
+
+// stripClass strips src and writes it where the flags say, returning its size
+// before and after.
+func stripClass(cmd *cobra.Command, src analyser.Source, remove []data.Tag, opts []parser.Option) (int, int, error) {
+	if strings.Contains(src.Name, ".jar!") {
+		return 0, 0, fmt.Errorf("classes in jars cannot be stripped in place")
+	}
+
+	original, err := src.Read()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	stripped, err := analyser.NewSource(src, opts...).Strip(cmd.Context(), remove)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch {
+	case stripDryRun:
+	case src.Name == "-":
+		_, err = os.Stdout.Write(stripped)
+	case stripOutput != "":
+		err = os.WriteFile(stripOutput, stripped, 0o644)
+	default:
+		err = os.WriteFile(src.Name, stripped, 0o644)
+	}
+
+	return len(original), len(stripped), err
+}
+
+func saved(before, after int) string {
+	return fmt.Sprintf("%d -> %d bytes, saved %d (%.1f%%)", before, after, before-after, 100*float64(before-after)/float64(max(before, 1)))
+}
+
+func init() {
+	rootCmd.AddCommand(stripCmd)
+
+	var debug []string
+	for _, tag := range analyser.DebugAttributes {
+		debug = append(debug, strings.TrimPrefix(tag.String(), "Attribute"))
+	}
+
+	stripCmd.Flags().StringSliceVar(&stripAttributes, "attributes", debug, "attributes to remove")
+	stripCmd.Flags().StringVarP(&stripOutput, "output", "o", "", "file to write the stripped class to, instead of in place")
+	stripCmd.Flags().BoolVarP(&stripDryRun, "dry-run", "n", false, "only report the size that would be saved")
+	stripCmd.Flags().BoolVar(&stripLenient, "lenient", false, "recover from damaged structures, dropping attributes the parser does not know")
+}
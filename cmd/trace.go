@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/luishfonseca/dtu_pa/interpreter"
+	"github.com/luishfonseca/dtu_pa/trace"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	traceClasspath string
+	traceSteps     int
+	traceDepth     int
+	traceHeap      int
+	traceFormat    string
+	traceOutput    string
+)
+
+// traceCmd represents the trace command
+var traceCmd = &cobra.Command{
+	Use:   "trace [flags] pkg.Class.method:(desc) '(inputs)'",
+	Short: "Run a method on inputs and record every instruction it runs",
+	Long: `Run a static method on inputs, as interpret does, recording for every
+instruction run its pc, source line and instruction, the operand stack and
+locals before and after it, and the writes it makes to arrays and fields.
+
+The trace is written as JSON Lines (see trace.JSONSchema for the format), or
+with --format binary in a compact binary format (see trace.Magic), to stdout
+or the file of --output. Traces of the same method and inputs are the same,
+so they can be diffed. replay views them.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := record(cmd, args[0], args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func record(cmd *cobra.Command, selector, inputs string) error {
+	var write func(io.Writer, *trace.Trace) error
+	switch traceFormat {
+	case "jsonl":
+		write = trace.WriteJSONL
+	case "binary":
+		write = trace.WriteBinary
+	default:
+		return fmt.Errorf("unknown format %q, expected jsonl or binary", traceFormat)
+	}
+
+	cp, m, err := load(cmd, selector, traceClasspath)
+	if err != nil {
+		return err
+	}
+
+	rec := &trace.Recorder{Trace: trace.Trace{Inputs: inputs}}
+	in, err := start(cmd, cp, m, inputs, traceDepth, traceHeap, interpreter.WithObserver(rec))
+	if err != nil {
+		return err
+	}
+
+	// a run ending in an error is traced up to it
+	t, runErr := in.Execute(traceSteps)
+	if runErr == nil {
+		rec.Trace.Outcome = t.Outcome.String()
+	}
+
+	if traceOutput == "" {
+		if err := write(cmd.OutOrStdout(), &rec.Trace); err != nil {
+			return err
+		}
+		return runErr
+	}
+
+	f, err := os.Create(traceOutput)
+	if err != nil {
+		return err
+	}
+	if err := write(f, &rec.Trace); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return runErr
+}
+
+func init() {
+	rootCmd.AddCommand(traceCmd)
+
+	traceCmd.Flags().StringVar(&traceClasspath, "classpath", ".", "directories and jars to look up classes in, separated as in java -cp")
+	traceCmd.Flags().IntVar(&traceSteps, "steps", 100000, "instructions to run before giving up with *")
+	traceCmd.Flags().IntVar(&traceDepth, "max-depth", interpreter.DefaultMaxDepth, "frames on the call stack before a StackOverflowError")
+	traceCmd.Flags().IntVar(&traceHeap, "max-heap", interpreter.DefaultMaxHeap, "elements the arrays allocated may hold in all before an OutOfMemoryError")
+	traceCmd.Flags().StringVar(&traceFormat, "format", "jsonl", "format of the trace: jsonl or binary")
+	traceCmd.Flags().StringVarP(&traceOutput, "output", "o", "", "file to write the trace to, instead of stdout")
+}
@@ -0,0 +1,130 @@
+// Package coverage measures which instructions, and which edges out of the
+// branches (the if* instructions and goto), runs of the interpreter take, and
+// reports it as an annotated disassembly or in the lcov format.
+package coverage
+
+import (
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+// Coverage is an interpreter.Observer counting, over all the runs it
+// observes, the instructions run and the edges taken.
+type Coverage struct {
+	methods map[interpreter.Ref]*Counts
+	pc      int // of the instruction running
+}
+
+// Counts of a method.
+type Counts struct {
+	Hits  map[int]int  // runs of the instruction at each pc
+	Edges map[Edge]int // times each branch went from its pc to another
+}
+
+// Edge from a branch to the instruction run after it.
+type Edge struct {
+	From, To int
+}
+
+func New() *Coverage {
+	return &Coverage{methods: make(map[interpreter.Ref]*Counts)}
+}
+
+// Counts of m, which are empty if it has not run.
+func (c *Coverage) Counts(m *interpreter.Method) *Counts {
+	if counts, ok := c.methods[key(m)]; ok {
+		return counts
+	}
+	return &Counts{Hits: map[int]int{}, Edges: map[Edge]int{}}
+}
+
+func key(m *interpreter.Method) interpreter.Ref {
+	return interpreter.Ref{Class: m.ClassName, Name: m.Info.Name.Value, Descriptor: m.Info.Descriptor.Value}
+}
+
+func (c *Coverage) Before(in *interpreter.Interpreter, f *interpreter.Frame, op data.Op) {
+	counts, ok := c.methods[key(f.Method)]
+	if !ok {
+		counts = &Counts{Hits: make(map[int]int), Edges: make(map[Edge]int)}
+		c.methods[key(f.Method)] = counts
+	}
+	counts.Hits[f.PC]++
+	c.pc = f.PC
+}
+
+func (c *Coverage) Write(interpreter.Write) {}
+
+func (c *Coverage) After(in *interpreter.Interpreter, f *interpreter.Frame, op data.Op, err error) {
+	if _, ok := op.BranchOffset(); err != nil || !ok {
+		return
+	}
+	c.methods[key(f.Method)].Edges[Edge{From: c.pc, To: f.PC}]++
+}
+
+// Branch is an if* instruction, which goes to Target or falls through to
+// Next, or a goto, which only goes to Target.
+type Branch struct {
+	PC, Target, Next int
+	Goto             bool
+}
+
+// Instruction of a method, with the runs of it.
+type Instruction struct {
+	PC     int
+	Op     data.Op
+	Line   int // source line, -1 if unknown
+	Hits   int
+	Branch *Branch // if it is one
+}
+
+// Instructions of m, with their counts.
+func (c *Coverage) Instructions(m *interpreter.Method) []Instruction {
+	counts := c.Counts(m)
+
+	instrs := make([]Instruction, len(m.Ops))
+	for i, op := range m.Ops {
+		pc := m.PC(i)
+		instrs[i] = Instruction{PC: pc, Op: op, Line: m.Line(pc), Hits: counts.Hits[pc]}
+		if offset, ok := op.BranchOffset(); ok {
+			instrs[i].Branch = &Branch{
+				PC:     pc,
+				Target: pc + offset,
+				Next:   m.PC(i + 1),
+				Goto:   op.Code == data.OP_GOTO,
+			}
+		}
+	}
+	return instrs
+}
+
+// Taken is how many times the branch went to its target, and fell through.
+func (b *Branch) Taken(counts *Counts) (target, next int) {
+	return counts.Edges[Edge{From: b.PC, To: b.Target}], counts.Edges[Edge{From: b.PC, To: b.Next}]
+}
+
+// Summary of the coverage of m: instructions and edges out of branches,
+// covered and in all.
+func (c *Coverage) Summary(m *interpreter.Method) (hit, instrs, taken, edges int) {
+	counts := c.Counts(m)
+	for _, instr := range c.Instructions(m) {
+		instrs++
+		if instr.Hits > 0 {
+			hit++
+		}
+
+		if b := instr.Branch; b != nil {
+			target, next := b.Taken(counts)
+			edges++
+			if target > 0 {
+				taken++
+			}
+			if !b.Goto {
+				edges++
+				if next > 0 {
+					taken++
+				}
+			}
+		}
+	}
+	return hit, instrs, taken, edges
+}
@@ -0,0 +1,138 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/internal/testutil"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+const source = `.class public super jpamb/cases/Branches
+.super java/lang/Object
+.source Branches.java
+
+.method public static count(I)I
+  .limit stack 2
+  .limit locals 2
+  .line 3
+  iconst_0
+  istore_1
+L2:
+  .line 4
+  iload_0
+  ifeq L15
+  .line 5
+  iinc 1 1
+  .line 6
+  iinc 0 -1
+  goto L2
+L15:
+  .line 8
+  iload_1
+  invokestatic jpamb/cases/Branches/twice(I)I
+  ireturn
+.end method
+
+.method public static twice(I)I
+  .limit stack 2
+  .limit locals 1
+  .line 11
+  iload_0
+  iconst_2
+  imul
+  ireturn
+.end method
+
+.method public static unused()V
+  .limit stack 0
+  .limit locals 0
+  .line 14
+  return
+.end method
+`
+
+func TestCoverage(t *testing.T) {
+	cp, classes := testutil.Classpath(t, source)
+	c := classes[0]
+
+	m, err := c.Method(data.Selector{Name: "count"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cov := New()
+	for _, n := range []int32{0, 2} {
+		in := interpreter.New(interpreter.WithClasspath(cp), interpreter.WithObserver(cov))
+		if err := in.Start(m, []interpreter.Value{interpreter.Int(n)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := in.Execute(100); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if hit, instrs, taken, edges := cov.Summary(m); hit != instrs || taken != edges || edges != 3 {
+		t.Errorf("count: %d/%d instructions, %d/%d edges", hit, instrs, taken, edges)
+	}
+
+	var out strings.Builder
+	if err := cov.Annotate(&out, m); err != nil {
+		t.Fatal(err)
+	}
+	want := `jpamb/cases/Branches.count:(I)I: 10/10 instructions, 3/3 branch edges
+  ; line 3
+      2     0  iconst_0
+      2     1  istore_1
+  ; line 4
+      4     2  iload_0
+      4     3  ifeq L15  ; taken 2, not taken 2
+  ; line 5
+      2     6  iinc 1 1
+  ; line 6
+      2     9  iinc 0 -1
+      2    12  goto L2  ; taken 2
+  ; line 8
+      2    15  iload_1
+      2    16  invokestatic jpamb/cases/Branches/twice(I)I
+      2    19  ireturn
+`
+	if out.String() != want {
+		t.Errorf("annotated\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := cov.WriteLCOV(&out, cp.Classes(), "src"); err != nil {
+		t.Fatal(err)
+	}
+	want = `TN:
+SF:src/jpamb/cases/Branches.java
+FN:3,jpamb/cases/Branches.count(I)I
+FN:11,jpamb/cases/Branches.twice(I)I
+FN:14,jpamb/cases/Branches.unused()V
+FNDA:2,jpamb/cases/Branches.count(I)I
+FNDA:2,jpamb/cases/Branches.twice(I)I
+FNDA:0,jpamb/cases/Branches.unused()V
+FNF:3
+FNH:2
+BRDA:4,3,0,2
+BRDA:4,3,1,2
+BRDA:6,12,0,2
+BRF:3
+BRH:3
+DA:3,2
+DA:4,4
+DA:5,2
+DA:6,2
+DA:8,2
+DA:11,2
+DA:14,0
+LF:7
+LH:6
+end_of_record
+`
+	if out.String() != want {
+		t.Errorf("lcov\n%s\nwant\n%s", out.String(), want)
+	}
+}
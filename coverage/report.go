@@ -0,0 +1,176 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"slices"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+// Annotate writes the disassembly of m to w, each instruction preceded by the
+// times it ran, or ##### if it never did, and each branch followed by the
+// times it was taken and, unless it is a goto, not taken, e.g.
+//
+//	jpamb/cases/Simple.sign:(I)I: 4/5 instructions, 1/2 branch edges
+//	  ; line 3
+//	      2     0  iload_0
+//	      2     1  ifge L6  ; taken 2, not taken 0
+//	  #####     4  iconst_m1
+func (c *Coverage) Annotate(w io.Writer, m *interpreter.Method) error {
+	out := bufio.NewWriter(w)
+	counts := c.Counts(m)
+
+	hit, instrs, taken, edges := c.Summary(m)
+	fmt.Fprintf(out, "%s.%s:%s: %d/%d instructions, %d/%d branch edges\n",
+		m.ClassName, m.Info.Name.Value, m.Info.Descriptor.Value, hit, instrs, taken, edges)
+
+	line := -1
+	for _, instr := range c.Instructions(m) {
+		if instr.Line != line && instr.Line >= 0 {
+			fmt.Fprintf(out, "  ; line %d\n", instr.Line)
+			line = instr.Line
+		}
+
+		text, err := assembler.Instruction(m.Class, instr.PC, instr.Op)
+		if err != nil {
+			return err
+		}
+
+		hits := "#####"
+		if instr.Hits > 0 {
+			hits = fmt.Sprint(instr.Hits)
+		}
+		fmt.Fprintf(out, "  %5s %5d  %s", hits, instr.PC, text)
+
+		if b := instr.Branch; b != nil {
+			target, next := b.Taken(counts)
+			if b.Goto {
+				fmt.Fprintf(out, "  ; taken %d", target)
+			} else {
+				fmt.Fprintf(out, "  ; taken %d, not taken %d", target, next)
+			}
+		}
+		fmt.Fprintln(out)
+	}
+
+	return out.Flush()
+}
+
+// WriteLCOV writes the coverage of the methods of classes to w in the lcov
+// tracefile format, a record per source file, which is named by the package
+// of the class under root, e.g. src/jpamb/cases/Simple.java. Lines are those
+// of the LineNumberTable of the methods, each hit as many times as the most
+// run of its instructions. Branches are numbered by their pc, and their edges
+// 0 for taken and 1 for not taken.
+func (c *Coverage) WriteLCOV(w io.Writer, classes []*interpreter.Class, root string) error {
+	type record struct {
+		fns, fndas []string
+		lines      map[int]int
+		brs        []string
+		hit        struct{ fns, lines, brs int }
+	}
+
+	var files []string
+	records := make(map[string]*record)
+
+	for _, class := range classes {
+		methods := class.Methods()
+		if len(methods) == 0 {
+			continue
+		}
+
+		source := methods[0].Source
+		if source == "" {
+			source = path.Base(class.Name) + ".java"
+		}
+		file := path.Join(root, path.Dir(class.Name), source)
+
+		r, ok := records[file]
+		if !ok {
+			r = &record{lines: make(map[int]int)}
+			records[file] = r
+			files = append(files, file)
+		}
+
+		for _, m := range methods {
+			counts := c.Counts(m)
+			instrs := c.Instructions(m)
+			name := class.Name + "." + m.String()
+
+			if line := m.Line(0); line >= 0 && len(instrs) > 0 {
+				r.fns = append(r.fns, fmt.Sprintf("FN:%d,%s", line, name))
+				r.fndas = append(r.fndas, fmt.Sprintf("FNDA:%d,%s", instrs[0].Hits, name))
+				if instrs[0].Hits > 0 {
+					r.hit.fns++
+				}
+			}
+
+			for _, instr := range instrs {
+				if instr.Line < 0 {
+					continue
+				}
+				r.lines[instr.Line] = max(r.lines[instr.Line], instr.Hits)
+
+				b := instr.Branch
+				if b == nil {
+					continue
+				}
+				target, next := b.Taken(counts)
+				r.brs = append(r.brs, brda(instr, 0, target))
+				if target > 0 {
+					r.hit.brs++
+				}
+				if !b.Goto {
+					r.brs = append(r.brs, brda(instr, 1, next))
+					if next > 0 {
+						r.hit.brs++
+					}
+				}
+			}
+		}
+	}
+
+	out := bufio.NewWriter(w)
+	slices.Sort(files)
+	for _, file := range files {
+		r := records[file]
+
+		fmt.Fprintln(out, "TN:")
+		fmt.Fprintf(out, "SF:%s\n", file)
+		for _, fn := range slices.Concat(r.fns, r.fndas) {
+			fmt.Fprintln(out, fn)
+		}
+		fmt.Fprintf(out, "FNF:%d\nFNH:%d\n", len(r.fns), r.hit.fns)
+
+		for _, br := range r.brs {
+			fmt.Fprintln(out, br)
+		}
+		fmt.Fprintf(out, "BRF:%d\nBRH:%d\n", len(r.brs), r.hit.brs)
+
+		lines := slices.Sorted(maps.Keys(r.lines))
+		for _, line := range lines {
+			fmt.Fprintf(out, "DA:%d,%d\n", line, r.lines[line])
+			if r.lines[line] > 0 {
+				r.hit.lines++
+			}
+		}
+		fmt.Fprintf(out, "LF:%d\nLH:%d\n", len(lines), r.hit.lines)
+		fmt.Fprintln(out, "end_of_record")
+	}
+
+	return out.Flush()
+}
+
+// brda is the lcov record of an edge of the branch instr, - if it never ran.
+func brda(instr Instruction, edge, taken int) string {
+	count := "-"
+	if instr.Hits > 0 {
+		count = fmt.Sprint(taken)
+	}
+	return fmt.Sprintf("BRDA:%d,%d,%d,%s", instr.Line, instr.PC, edge, count)
+}
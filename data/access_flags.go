@@ -1,6 +1,9 @@
 package data
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
 type AccessFlags uint16
 
@@ -48,3 +51,71 @@ func (af AccessFlags) String() string {
 	}
 	return encoded + "]>"
 }
+
+// FlagsOf is what access flags belong to, which decides the meaning of some
+// of their bits.
+type FlagsOf int
+
+const (
+	CLASS_FLAGS FlagsOf = iota
+	FIELD_FLAGS
+	METHOD_FLAGS
+)
+
+type flagKeyword struct {
+	mask    uint16
+	keyword string
+}
+
+var flagKeywords = [...][]flagKeyword{
+	CLASS_FLAGS: {{0x0001, "public"}, {0x0010, "final"}, {0x0020, "super"}, {0x0200, "interface"},
+		{0x0400, "abstract"}, {0x1000, "synthetic"}, {0x2000, "annotation"}, {0x4000, "enum"}, {0x8000, "module"}},
+	FIELD_FLAGS: {{0x0001, "public"}, {0x0002, "private"}, {0x0004, "protected"}, {0x0008, "static"},
+		{0x0010, "final"}, {0x0040, "volatile"}, {0x0080, "transient"}, {0x1000, "synthetic"}, {0x4000, "enum"}},
+	METHOD_FLAGS: {{0x0001, "public"}, {0x0002, "private"}, {0x0004, "protected"}, {0x0008, "static"},
+		{0x0010, "final"}, {0x0020, "synchronized"}, {0x0040, "bridge"}, {0x0080, "varargs"}, {0x0100, "native"},
+		{0x0400, "abstract"}, {0x0800, "strict"}, {0x1000, "synthetic"}},
+}
+
+// Keywords names the flags set in af, e.g. "public" for ACC_PUBLIC, with the
+// bits that have no name left over in hexadecimal.
+func (af AccessFlags) Keywords(of FlagsOf) []string {
+	var words []string
+	rest := uint16(af)
+	for _, f := range flagKeywords[of] {
+		if rest&f.mask != 0 {
+			words = append(words, f.keyword)
+			rest &^= f.mask
+		}
+	}
+
+	if rest != 0 {
+		words = append(words, fmt.Sprintf("0x%04x", rest))
+	}
+
+	return words
+}
+
+// ParseAccessFlags reads flags named as by Keywords.
+func ParseAccessFlags(words []string, of FlagsOf) (AccessFlags, error) {
+	var flags uint16
+	for _, w := range words {
+		found := false
+		for _, f := range flagKeywords[of] {
+			if f.keyword == w {
+				flags |= f.mask
+				found = true
+			}
+		}
+
+		if !found {
+			v, err := strconv.ParseUint(w, 0, 16)
+			if err != nil {
+				return 0, fmt.Errorf("unknown access flag %q", w)
+			}
+			flags |= uint16(v)
+		}
+	}
+
+	return AccessFlags(flags), nil
+}
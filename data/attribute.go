@@ -49,13 +49,71 @@ func (a AttributeCode) String() string {
 	return str
 }
 
-type AttributeSourceFile struct{ baseData }
+type AttributeSourceFile struct {
+	SourceFile *ConstantUtf8
+	baseData
+}
 
 func (a *AttributeSourceFile) Tag() Tag                                  { return ATTR_SOURCE_FILE }
 func (a *AttributeSourceFile) AttributeSourceFile() *AttributeSourceFile { return a }
 func (d *baseData) AttributeSourceFile() *AttributeSourceFile            { panic(msg(d, "AttributeSourceFile")) }
 
-type AttributeRuntimeVisibleAnnotations struct{ baseData }
+func (a AttributeSourceFile) String() string {
+	return fmt.Sprintf("AttributeSourceFile { %s }", a.SourceFile)
+}
+
+// ElementValue is the value of an annotation element. Tag selects which of the
+// other fields is set. See https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-4.html#jvms-4.7.16.1
+type ElementValue struct {
+	Tag        byte
+	Const      Data          // B C D F I J S Z s
+	EnumType   *ConstantUtf8 // e
+	EnumName   *ConstantUtf8 // e
+	Class      *ConstantUtf8 // c
+	Annotation *Annotation   // @
+	Values     []ElementValue
+}
+
+func (e ElementValue) String() string {
+	switch e.Tag {
+	case 'e':
+		return fmt.Sprintf("%s.%s", e.EnumType, e.EnumName)
+	case 'c':
+		return fmt.Sprintf("%s.class", e.Class)
+	case '@':
+		return e.Annotation.String()
+	case '[':
+		return fmt.Sprint(e.Values)
+	default:
+		return fmt.Sprint(e.Const)
+	}
+}
+
+type ElementValuePair struct {
+	Name  *ConstantUtf8
+	Value ElementValue
+}
+
+type Annotation struct {
+	Type     *ConstantUtf8
+	Elements []ElementValuePair
+}
+
+func (a Annotation) String() string {
+	str := fmt.Sprintf("@%s(", a.Type)
+	for i, e := range a.Elements {
+		if i > 0 {
+			str += ", "
+		}
+		str += fmt.Sprintf("%s=%s", e.Name, e.Value)
+	}
+	return str + ")"
+}
+
+type AttributeRuntimeVisibleAnnotations struct {
+	Annotations []Annotation
+	baseData
+}
 
 func (a *AttributeRuntimeVisibleAnnotations) Tag() Tag { return ATTR_RUNTIME_VISIBLE_ANNOTATIONS }
 func (a *AttributeRuntimeVisibleAnnotations) AttributeRuntimeVisibleAnnotations() *AttributeRuntimeVisibleAnnotations {
@@ -65,7 +123,21 @@ func (d *baseData) AttributeRuntimeVisibleAnnotations() *AttributeRuntimeVisible
 	panic(msg(d, "AttributeRuntimeVisibleAnnotations"))
 }
 
-type AttributeInnerClasses struct{ baseData }
+func (a AttributeRuntimeVisibleAnnotations) String() string {
+	return fmt.Sprintf("AttributeRuntimeVisibleAnnotations %v", a.Annotations)
+}
+
+type InnerClass struct {
+	InnerClass  *ConstantClass
+	OuterClass  *ConstantClass // nil if not a member
+	InnerName   *ConstantUtf8  // nil if anonymous
+	AccessFlags AccessFlags
+}
+
+type AttributeInnerClasses struct {
+	Classes []InnerClass
+	baseData
+}
 
 func (a *AttributeInnerClasses) Tag() Tag                                      { return ATTR_INNER_CLASSES }
 func (a *AttributeInnerClasses) AttributeInnerClasses() *AttributeInnerClasses { return a }
@@ -73,7 +145,19 @@ func (d *baseData) AttributeInnerClasses() *AttributeInnerClasses {
 	panic(msg(d, "AttributeInnerClasses"))
 }
 
-type AttributeLineNumberTable struct{ baseData }
+func (a AttributeInnerClasses) String() string {
+	return fmt.Sprintf("AttributeInnerClasses %v", a.Classes)
+}
+
+type LineNumber struct {
+	StartPC    uint16
+	LineNumber uint16
+}
+
+type AttributeLineNumberTable struct {
+	LineNumbers []LineNumber
+	baseData
+}
 
 func (a *AttributeLineNumberTable) Tag() Tag { return ATTR_LINE_NUMBER_TABLE }
 func (a *AttributeLineNumberTable) AttributeLineNumberTable() *AttributeLineNumberTable {
@@ -83,7 +167,22 @@ func (d *baseData) AttributeLineNumberTable() *AttributeLineNumberTable {
 	panic(msg(d, "AttributeLineNumberTable"))
 }
 
-type AttributeLocalVariableTable struct{ baseData }
+func (a AttributeLineNumberTable) String() string {
+	return fmt.Sprintf("AttributeLineNumberTable %v", a.LineNumbers)
+}
+
+type LocalVariable struct {
+	StartPC    uint16
+	Length     uint16
+	Name       *ConstantUtf8
+	Descriptor *ConstantUtf8
+	Index      uint16
+}
+
+type AttributeLocalVariableTable struct {
+	LocalVariables []LocalVariable
+	baseData
+}
 
 func (a *AttributeLocalVariableTable) Tag() Tag { return ATTR_LOCAL_VARIABLE_TABLE }
 func (a *AttributeLocalVariableTable) AttributeLocalVariableTable() *AttributeLocalVariableTable {
@@ -93,7 +192,42 @@ func (d *baseData) AttributeLocalVariableTable() *AttributeLocalVariableTable {
 	panic(msg(d, "AttributeLocalVariableTable"))
 }
 
-type AttributeStackMapTable struct{ baseData }
+func (a AttributeLocalVariableTable) String() string {
+	return fmt.Sprintf("AttributeLocalVariableTable %v", a.LocalVariables)
+}
+
+// VerificationType tags. See https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-4.html#jvms-4.7.4
+const (
+	VT_TOP uint8 = iota
+	VT_INTEGER
+	VT_FLOAT
+	VT_DOUBLE
+	VT_LONG
+	VT_NULL
+	VT_UNINITIALIZED_THIS
+	VT_OBJECT
+	VT_UNINITIALIZED
+)
+
+type VerificationType struct {
+	Tag    uint8
+	Class  *ConstantClass // VT_OBJECT
+	Offset uint16         // VT_UNINITIALIZED
+}
+
+// StackMapFrame keeps the frame type as found in the class, which determines
+// how OffsetDelta, Locals and Stack are encoded.
+type StackMapFrame struct {
+	FrameType   uint8
+	OffsetDelta uint16
+	Locals      []VerificationType
+	Stack       []VerificationType
+}
+
+type AttributeStackMapTable struct {
+	Frames []StackMapFrame
+	baseData
+}
 
 func (a *AttributeStackMapTable) Tag() Tag { return ATTR_STACK_MAP_TABLE }
 func (a *AttributeStackMapTable) AttributeStackMapTable() *AttributeStackMapTable {
@@ -102,3 +236,7 @@ func (a *AttributeStackMapTable) AttributeStackMapTable() *AttributeStackMapTabl
 func (d *baseData) AttributeStackMapTable() *AttributeStackMapTable {
 	panic(msg(d, "AttributeStackMapTable"))
 }
+
+func (a AttributeStackMapTable) String() string {
+	return fmt.Sprintf("AttributeStackMapTable %v", a.Frames)
+}
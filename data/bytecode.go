@@ -26,8 +26,13 @@ const (
 	OP_ILOAD_3       OpCode = 0x1d
 	OP_ALOAD_0       OpCode = 0x2a
 	OP_ALOAD_1       OpCode = 0x2b
+	OP_ALOAD_2       OpCode = 0x2c
+	OP_ALOAD_3       OpCode = 0x2d
 	OP_IALOAD        OpCode = 0x2e
+	OP_AALOAD        OpCode = 0x32
+	OP_BALOAD        OpCode = 0x33
 	OP_CALOAD        OpCode = 0x34
+	OP_SALOAD        OpCode = 0x35
 	OP_ISTORE        OpCode = 0x36
 	OP_ASTORE        OpCode = 0x3a
 	OP_ISTORE_0      OpCode = 0x3b
@@ -37,7 +42,12 @@ const (
 	OP_ASTORE_0      OpCode = 0x4b
 	OP_ASTORE_1      OpCode = 0x4c
 	OP_ASTORE_2      OpCode = 0x4d
+	OP_ASTORE_3      OpCode = 0x4e
 	OP_IASTORE       OpCode = 0x4f
+	OP_AASTORE       OpCode = 0x53
+	OP_BASTORE       OpCode = 0x54
+	OP_CASTORE       OpCode = 0x55
+	OP_SASTORE       OpCode = 0x56
 	OP_DUP           OpCode = 0x59
 	OP_IADD          OpCode = 0x60
 	OP_ISUB          OpCode = 0x64
@@ -56,19 +66,26 @@ const (
 	OP_IF_ICMPGE     OpCode = 0xa2
 	OP_IF_ICMPGT     OpCode = 0xa3
 	OP_IF_ICMPLE     OpCode = 0xa4
+	OP_IF_ACMPEQ     OpCode = 0xa5
+	OP_IF_ACMPNE     OpCode = 0xa6
 	OP_GOTO          OpCode = 0xa7
 	OP_IRETURN       OpCode = 0xac
 	OP_ARETURN       OpCode = 0xb0
 	OP_RETURN        OpCode = 0xb1
 	OP_GETSTATIC     OpCode = 0xb2
 	OP_PUTSTATIC     OpCode = 0xb3
+	OP_GETFIELD      OpCode = 0xb4
+	OP_PUTFIELD      OpCode = 0xb5
 	OP_INVOKEVIRTUAL OpCode = 0xb6
 	OP_INVOKESPECIAL OpCode = 0xb7
 	OP_INVOKESTATIC  OpCode = 0xb8
 	OP_NEW           OpCode = 0xbb
 	OP_NEWARRAY      OpCode = 0xbc
+	OP_ANEWARRAY     OpCode = 0xbd
 	OP_ARRAYLENGTH   OpCode = 0xbe
 	OP_ATHROW        OpCode = 0xbf
+	OP_IFNULL        OpCode = 0xc6
+	OP_IFNONNULL     OpCode = 0xc7
 )
 
 func (o OpCode) String() string {
@@ -111,10 +128,20 @@ func (o OpCode) String() string {
 		return "aload_0"
 	case OP_ALOAD_1:
 		return "aload_1"
+	case OP_ALOAD_2:
+		return "aload_2"
+	case OP_ALOAD_3:
+		return "aload_3"
 	case OP_IALOAD:
 		return "iaload"
+	case OP_AALOAD:
+		return "aaload"
+	case OP_BALOAD:
+		return "baload"
 	case OP_CALOAD:
 		return "caload"
+	case OP_SALOAD:
+		return "saload"
 	case OP_ISTORE:
 		return "istore"
 	case OP_ASTORE:
@@ -131,8 +158,18 @@ func (o OpCode) String() string {
 		return "astore_1"
 	case OP_ASTORE_2:
 		return "astore_2"
+	case OP_ASTORE_3:
+		return "astore_3"
 	case OP_IASTORE:
 		return "iastore"
+	case OP_AASTORE:
+		return "aastore"
+	case OP_BASTORE:
+		return "bastore"
+	case OP_CASTORE:
+		return "castore"
+	case OP_SASTORE:
+		return "sastore"
 	case OP_DUP:
 		return "dup"
 	case OP_IADD:
@@ -169,6 +206,10 @@ func (o OpCode) String() string {
 		return "if_icmpgt"
 	case OP_IF_ICMPLE:
 		return "if_icmple"
+	case OP_IF_ACMPEQ:
+		return "if_acmpeq"
+	case OP_IF_ACMPNE:
+		return "if_acmpne"
 	case OP_GOTO:
 		return "goto"
 	case OP_IRETURN:
@@ -177,6 +218,10 @@ func (o OpCode) String() string {
 		return "getstatic"
 	case OP_PUTSTATIC:
 		return "putstatic"
+	case OP_GETFIELD:
+		return "getfield"
+	case OP_PUTFIELD:
+		return "putfield"
 	case OP_ARETURN:
 		return "areturn"
 	case OP_RETURN:
@@ -191,10 +236,16 @@ func (o OpCode) String() string {
 		return "new"
 	case OP_NEWARRAY:
 		return "newarray"
+	case OP_ANEWARRAY:
+		return "anewarray"
 	case OP_ARRAYLENGTH:
 		return "arraylength"
 	case OP_ATHROW:
 		return "athrow"
+	case OP_IFNULL:
+		return "ifnull"
+	case OP_IFNONNULL:
+		return "ifnonnull"
 	default:
 		return fmt.Sprintf("UNKNOWN_OP_%02X", byte(o))
 	}
@@ -202,14 +253,15 @@ func (o OpCode) String() string {
 
 func (op OpCode) NArgs() (int, error) {
 	switch byte(op) {
-	case 0x00, 0x01, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x12, 0x1a, 0x1b, 0x1c, 0x1d, 0x2a, 0x2b,
-		0x2e, 0x34, 0x3b, 0x3c, 0x3d, 0x3e, 0x4b, 0x4c, 0x4d, 0x4f, 0x59, 0x60, 0x64, 0x68, 0x6c,
-		0x70, 0x93, 0xac, 0xb0, 0xb1, 0xbe, 0xbf:
+	case 0x00, 0x01, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x1a, 0x1b, 0x1c, 0x1d, 0x2a, 0x2b,
+		0x2c, 0x2d, 0x2e, 0x32, 0x33, 0x34, 0x35, 0x3b, 0x3c, 0x3d, 0x3e, 0x4b, 0x4c, 0x4d, 0x4e,
+		0x4f, 0x53, 0x54, 0x55, 0x56, 0x59, 0x60, 0x64, 0x68, 0x6c, 0x70, 0x93, 0xac, 0xb0, 0xb1,
+		0xbe, 0xbf:
 		return 0, nil
-	case 0x10, 0x15, 0x19, 0x36, 0x3a, 0xbc:
+	case 0x10, 0x12, 0x15, 0x19, 0x36, 0x3a, 0xbc:
 		return 1, nil
-	case 0x11, 0x84, 0x99, 0x9a, 0x9c, 0x9d, 0x9f, 0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa7, 0xb2, 0xb3,
-		0xb6, 0xb7, 0xb8, 0xbb:
+	case 0x11, 0x84, 0x99, 0x9a, 0x9c, 0x9d, 0x9f, 0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+		0xb2, 0xb3, 0xb4, 0xb5, 0xb6, 0xb7, 0xb8, 0xbb, 0xbd, 0xc6, 0xc7:
 		return 2, nil
 	default:
 		return -1, fmt.Errorf("unimplemented bytecode: 0x%02x", byte(op))
@@ -221,6 +273,35 @@ type Op struct {
 	Arg  []byte
 }
 
+// ConstantIndex is the constant pool index operand of o, if it has one.
+func (o Op) ConstantIndex() (uint16, bool) {
+	switch o.Code {
+	case OP_LDC:
+		if len(o.Arg) == 1 {
+			return uint16(o.Arg[0]), true
+		}
+	case OP_GETSTATIC, OP_PUTSTATIC, OP_GETFIELD, OP_PUTFIELD, OP_INVOKEVIRTUAL, OP_INVOKESPECIAL,
+		OP_INVOKESTATIC, OP_NEW, OP_ANEWARRAY:
+		if len(o.Arg) == 2 {
+			return uint16(o.Arg[0])<<8 | uint16(o.Arg[1]), true
+		}
+	}
+	return 0, false
+}
+
+// BranchOffset is the offset from o to the instruction it branches to, if it
+// is a branch.
+func (o Op) BranchOffset() (int, bool) {
+	switch o.Code {
+	case OP_IFEQ, OP_IFNE, OP_IFGE, OP_IFGT, OP_IF_ICMPEQ, OP_IF_ICMPNE, OP_IF_ICMPLT, OP_IF_ICMPGE,
+		OP_IF_ICMPGT, OP_IF_ICMPLE, OP_IF_ACMPEQ, OP_IF_ACMPNE, OP_GOTO, OP_IFNULL, OP_IFNONNULL:
+		if len(o.Arg) == 2 {
+			return int(int16(uint16(o.Arg[0])<<8 | uint16(o.Arg[1]))), true
+		}
+	}
+	return 0, false
+}
+
 func (o Op) String() string {
 	if o.Arg != nil {
 		return fmt.Sprintf("%s %v", o.Code, o.Arg)
@@ -6,8 +6,9 @@ type Class struct {
 	Version      string
 	ConstantPool []Data
 	AccessFlags  AccessFlags
-	ThisClass    ConstantClass
+	ThisClass    *ConstantClass
 	SuperClass   *ConstantClass
+	Interfaces   []*ConstantClass
 	Fields       []MemberInfo
 	Methods      []MemberInfo
 	Attributes   map[Tag]*AttributeHandle
@@ -32,6 +33,9 @@ func (c Class) String() string {
 	str += fmt.Sprintln("  Version:", c.Version)
 	str += "  ConstantPool: [\n"
 	for i, constant := range c.ConstantPool {
+		if constant == nil {
+			continue // Second slot of a long or double
+		}
 		str += fmt.Sprintf("    %2d: %s\n", i+1, constant)
 	}
 	str += "  ]\n"
@@ -42,7 +46,11 @@ func (c Class) String() string {
 	} else {
 		str += "  SuperClass: None\n"
 	}
-	str += "  Fields: [\n"
+	str += "  Interfaces: [\n"
+	for _, iface := range c.Interfaces {
+		str += fmt.Sprintln("   ", *iface)
+	}
+	str += "  ]\n  Fields: [\n"
 	for _, field := range c.Fields {
 		str += fmt.Sprintln("   ", field)
 	}
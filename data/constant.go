@@ -28,6 +28,49 @@ func (c ConstantInteger) String() string {
 	return fmt.Sprint(c.Value)
 }
 
+type ConstantFloat struct {
+	Value float32
+	baseData
+}
+
+func (c *ConstantFloat) Tag() Tag                      { return CP_FLOAT }
+func (c *ConstantFloat) ConstantFloat() *ConstantFloat { return c }
+func (d *baseData) ConstantFloat() *ConstantFloat      { panic(msg(d, "ConstantFloat")) }
+
+func (c ConstantFloat) String() string {
+	return fmt.Sprintf("%vf", c.Value)
+}
+
+// ConstantLong takes up two slots of the constant pool, the second of which
+// is left empty.
+type ConstantLong struct {
+	Value int64
+	baseData
+}
+
+func (c *ConstantLong) Tag() Tag                    { return CP_LONG }
+func (c *ConstantLong) ConstantLong() *ConstantLong { return c }
+func (d *baseData) ConstantLong() *ConstantLong     { panic(msg(d, "ConstantLong")) }
+
+func (c ConstantLong) String() string {
+	return fmt.Sprintf("%dl", c.Value)
+}
+
+// ConstantDouble takes up two slots of the constant pool, the second of which
+// is left empty.
+type ConstantDouble struct {
+	Value float64
+	baseData
+}
+
+func (c *ConstantDouble) Tag() Tag                        { return CP_DOUBLE }
+func (c *ConstantDouble) ConstantDouble() *ConstantDouble { return c }
+func (d *baseData) ConstantDouble() *ConstantDouble       { panic(msg(d, "ConstantDouble")) }
+
+func (c ConstantDouble) String() string {
+	return fmt.Sprintf("%vd", c.Value)
+}
+
 type ConstantClass struct {
 	Name *Data
 	baseData
@@ -38,9 +81,25 @@ func (c *ConstantClass) ConstantClass() *ConstantClass { return c }
 func (d *baseData) ConstantClass() *ConstantClass      { panic(msg(d, "ConstantClass")) }
 
 func (c ConstantClass) String() string {
+	if c.Name == nil {
+		return "<Class ?>"
+	}
 	return fmt.Sprintf("<Class %s>", *c.Name)
 }
 
+type ConstantString struct {
+	Value *Data
+	baseData
+}
+
+func (c *ConstantString) Tag() Tag                        { return CP_STRING }
+func (c *ConstantString) ConstantString() *ConstantString { return c }
+func (d *baseData) ConstantString() *ConstantString       { panic(msg(d, "ConstantString")) }
+
+func (c ConstantString) String() string {
+	return fmt.Sprintf("<String %s>", *c.Value)
+}
+
 type ConstantNameAndType struct {
 	Name       *Data
 	Descriptor *Data
@@ -82,3 +141,26 @@ func (d *baseData) ConstantMethodref() *ConstantMethodref          { panic(msg(d
 func (c ConstantMethodref) String() string {
 	return fmt.Sprintf("<Methodref: %s, %s>", *c.Clazz, *c.NameAndType)
 }
+
+type ConstantInterfaceMethodref struct {
+	Clazz       *Data
+	NameAndType *Data
+	baseData
+}
+
+func (c *ConstantInterfaceMethodref) Tag() Tag { return CP_INTERFACE_METHODREF }
+func (c *ConstantInterfaceMethodref) ConstantInterfaceMethodref() *ConstantInterfaceMethodref {
+	return c
+}
+func (d *baseData) ConstantInterfaceMethodref() *ConstantInterfaceMethodref {
+	panic(msg(d, "ConstantInterfaceMethodref"))
+}
+
+func (c ConstantInterfaceMethodref) String() string {
+	return fmt.Sprintf("<InterfaceMethodref: %s, %s>", *c.Clazz, *c.NameAndType)
+}
+
+// IsWide reports whether c takes up two slots of the constant pool.
+func IsWide(c Data) bool {
+	return c != nil && (c.Tag() == CP_LONG || c.Tag() == CP_DOUBLE)
+}
@@ -12,10 +12,15 @@ const (
 	BYTECODE_HANDLE
 	CP_UTF8
 	CP_INTEGER
+	CP_FLOAT
+	CP_LONG
+	CP_DOUBLE
 	CP_CLASS
+	CP_STRING
 	CP_NAME_AND_TYPE
 	CP_FIELDREF
 	CP_METHODREF
+	CP_INTERFACE_METHODREF
 	ATTR_CODE
 	ATTR_SOURCE_FILE
 	ATTR_RUNTIME_VISIBLE_ANNOTATIONS
@@ -41,14 +46,24 @@ func (t Tag) String() string {
 		return "ConstantUtf8"
 	case CP_INTEGER:
 		return "ConstantInteger"
+	case CP_FLOAT:
+		return "ConstantFloat"
+	case CP_LONG:
+		return "ConstantLong"
+	case CP_DOUBLE:
+		return "ConstantDouble"
 	case CP_CLASS:
 		return "ConstantClass"
+	case CP_STRING:
+		return "ConstantString"
 	case CP_NAME_AND_TYPE:
 		return "ConstantNameAndType"
 	case CP_FIELDREF:
 		return "ConstantFieldref"
 	case CP_METHODREF:
 		return "ConstantMethodref"
+	case CP_INTERFACE_METHODREF:
+		return "ConstantInterfaceMethodref"
 	case ATTR_CODE:
 		return "AttributeCode"
 	case ATTR_SOURCE_FILE:
@@ -80,10 +95,15 @@ type Data interface {
 
 	ConstantUtf8() *ConstantUtf8
 	ConstantInteger() *ConstantInteger
+	ConstantFloat() *ConstantFloat
+	ConstantLong() *ConstantLong
+	ConstantDouble() *ConstantDouble
 	ConstantClass() *ConstantClass
+	ConstantString() *ConstantString
 	ConstantNameAndType() *ConstantNameAndType
 	ConstantFieldref() *ConstantFieldref
 	ConstantMethodref() *ConstantMethodref
+	ConstantInterfaceMethodref() *ConstantInterfaceMethodref
 
 	AttributeCode() *AttributeCode
 	AttributeSourceFile() *AttributeSourceFile
@@ -94,6 +114,9 @@ type Data interface {
 	AttributeStackMapTable() *AttributeStackMapTable
 }
 
+// Resolver resolves a handle into the data it refers to.
+type Resolver func(handle Data) (Data, error)
+
 type baseData struct{}
 
 func msg(b Data, expected string) string {
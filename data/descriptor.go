@@ -0,0 +1,97 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitMethodDescriptor splits a method descriptor such as
+// "(I[Ljava/lang/String;)V" into the field descriptors of its parameters and
+// of its return type.
+func SplitMethodDescriptor(desc string) (params []string, ret string, err error) {
+	rest, ok := strings.CutPrefix(desc, "(")
+	if !ok {
+		return nil, "", fmt.Errorf("method descriptor %q does not start with (", desc)
+	}
+
+	for !strings.HasPrefix(rest, ")") {
+		n := fieldDescriptorLength(rest)
+		if n == 0 {
+			return nil, "", fmt.Errorf("invalid method descriptor %q", desc)
+		}
+		params = append(params, rest[:n])
+		rest = rest[n:]
+	}
+
+	ret = rest[1:]
+	if ret != "V" && fieldDescriptorLength(ret) != len(ret) {
+		return nil, "", fmt.Errorf("invalid return type in method descriptor %q", desc)
+	}
+
+	return params, ret, nil
+}
+
+// fieldDescriptorLength is the length of the field descriptor at the start of
+// s, or 0 if there is none.
+func fieldDescriptorLength(s string) int {
+	dims := 0
+	for dims < len(s) && s[dims] == '[' {
+		dims++
+	}
+
+	if dims == len(s) {
+		return 0
+	}
+
+	switch s[dims] {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z':
+		return dims + 1
+	case 'L':
+		if end := strings.IndexByte(s[dims:], ';'); end > 1 {
+			return dims + end + 1
+		}
+	}
+
+	return 0
+}
+
+// Slots is the number of local variable slots a value of the field
+// descriptor takes up.
+func Slots(desc string) int {
+	if desc == "J" || desc == "D" {
+		return 2
+	}
+	return 1
+}
+
+// JavaType renders a field descriptor as in Java source, e.g. "[I" as
+// "int[]" and "Ljava/lang/String;" as "java.lang.String".
+func JavaType(desc string) string {
+	dims := strings.Count(desc, "[")
+	base := strings.TrimLeft(desc, "[")
+
+	switch base {
+	case "B":
+		base = "byte"
+	case "C":
+		base = "char"
+	case "D":
+		base = "double"
+	case "F":
+		base = "float"
+	case "I":
+		base = "int"
+	case "J":
+		base = "long"
+	case "S":
+		base = "short"
+	case "Z":
+		base = "boolean"
+	case "V":
+		base = "void"
+	default:
+		base = strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(base, "L"), ";"), "/", ".")
+	}
+
+	return base + strings.Repeat("[]", dims)
+}
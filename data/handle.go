@@ -1,10 +1,17 @@
 package data
 
-import "fmt"
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+)
 
 type AttributeHandle struct {
 	AttributeTag Tag
+	Name         *ConstantUtf8
 	Begin        int64
+	Length       uint32
 	baseData
 }
 
@@ -13,7 +20,16 @@ func (a *AttributeHandle) AttributeHandle() *AttributeHandle { return a }
 func (d *baseData) AttributeHandle() *AttributeHandle        { panic(msg(d, "AttributeHandle")) }
 
 func (a AttributeHandle) String() string {
-	return fmt.Sprintf("<%s @ %d>", a.AttributeTag, a.Begin)
+	return fmt.Sprintf("<%s @[%d.. %d]>", a.AttributeTag, a.Begin, a.Begin+int64(a.Length))
+}
+
+// SortedHandles lists the attributes of a class or member in file order.
+func SortedHandles(attrs map[Tag]*AttributeHandle) []*AttributeHandle {
+	handles := slices.Collect(maps.Values(attrs))
+	slices.SortFunc(handles, func(a, b *AttributeHandle) int {
+		return cmp.Compare(a.Begin, b.Begin)
+	})
+	return handles
 }
 
 type BytecodeHandle struct {
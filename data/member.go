@@ -27,11 +27,16 @@ func (m MemberType) String() string {
 type MemberInfo struct {
 	MemberType  MemberType
 	AccessFlags AccessFlags
-	Name        ConstantUtf8
-	Descriptor  ConstantUtf8
+	Name        *ConstantUtf8
+	Descriptor  *ConstantUtf8
 	Attributes  map[Tag]*AttributeHandle
+	Unparsable  bool // set by lenient parsing when the code could not be parsed
 }
 
 func (m MemberInfo) String() string {
-	return fmt.Sprintf("<%s: %s %s %v> -> %v", m.MemberType, m.Name, m.Descriptor, m.AccessFlags, slices.Collect(maps.Values(m.Attributes)))
+	str := fmt.Sprintf("<%s: %s %s %v> -> %v", m.MemberType, m.Name, m.Descriptor, m.AccessFlags, slices.Collect(maps.Values(m.Attributes)))
+	if m.Unparsable {
+		str += " (unparsable)"
+	}
+	return str
 }
@@ -0,0 +1,307 @@
+package data
+
+import (
+	"fmt"
+	"math"
+)
+
+// References lists the constant pool slots c refers to.
+func References(c Data) []*Data {
+	var refs []*Data
+	for _, r := range referenceFields(c) {
+		refs = append(refs, *r)
+	}
+	return refs
+}
+
+// referenceFields of c, so they can be pointed elsewhere.
+func referenceFields(c Data) []**Data {
+	if c == nil {
+		return nil
+	}
+
+	switch c.Tag() {
+	case CP_CLASS:
+		return []**Data{&c.ConstantClass().Name}
+	case CP_STRING:
+		return []**Data{&c.ConstantString().Value}
+	case CP_NAME_AND_TYPE:
+		return []**Data{&c.ConstantNameAndType().Name, &c.ConstantNameAndType().Descriptor}
+	case CP_FIELDREF:
+		return []**Data{&c.ConstantFieldref().Clazz, &c.ConstantFieldref().NameAndType}
+	case CP_METHODREF:
+		return []**Data{&c.ConstantMethodref().Clazz, &c.ConstantMethodref().NameAndType}
+	case CP_INTERFACE_METHODREF:
+		return []**Data{&c.ConstantInterfaceMethodref().Clazz, &c.ConstantInterfaceMethodref().NameAndType}
+	default:
+		return nil
+	}
+}
+
+// PoolBuilder adds constants to the pool of a class, reusing an equal entry
+// when there is one. Constants refer to each other by the address of their
+// slot, so when the pool has to grow the references between its constants
+// are moved along with it.
+//
+// Constants that cannot be added, because they have dangling references or
+// the pool is full, are left out of it, and Index and Slot fail from then on.
+type PoolBuilder struct {
+	class     *Class
+	entries   map[string]int // constant key to slot
+	slots     map[*Data]int  // address of each slot to its position
+	constants map[Data]int   // constant to the first slot holding it
+	err       error
+}
+
+// MaxPoolCount is the largest constant_pool_count, a u2 one more than the
+// number of slots.
+const MaxPoolCount = math.MaxUint16
+
+// NewPoolBuilder indexes the constants already in the pool of class.
+func NewPoolBuilder(class *Class) *PoolBuilder {
+	b := &PoolBuilder{class: class, entries: make(map[string]int), slots: make(map[*Data]int), constants: make(map[Data]int)}
+
+	for i := range class.ConstantPool {
+		b.index(i)
+	}
+
+	for i, c := range class.ConstantPool {
+		if k, ok := b.key(c); ok {
+			if _, dup := b.entries[k]; !dup {
+				b.entries[k] = i
+			}
+		}
+	}
+
+	return b
+}
+
+// key identifies a constant by its contents, and is not ok for constants with
+// broken references.
+func (b *PoolBuilder) key(c Data) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	switch c.Tag() {
+	case CP_UTF8:
+		return "u" + c.ConstantUtf8().Value, true
+	case CP_INTEGER:
+		return fmt.Sprintf("I%d", c.ConstantInteger().Value), true
+	case CP_FLOAT:
+		return fmt.Sprintf("F%08x", math.Float32bits(c.ConstantFloat().Value)), true
+	case CP_LONG:
+		return fmt.Sprintf("J%d", c.ConstantLong().Value), true
+	case CP_DOUBLE:
+		return fmt.Sprintf("D%016x", math.Float64bits(c.ConstantDouble().Value)), true
+	}
+
+	// Referencing constants are keyed by the slots they point to
+	key := fmt.Sprint(int(c.Tag()))
+	for _, r := range References(c) {
+		i, ok := b.slot(r)
+		if !ok {
+			return "", false
+		}
+		key += fmt.Sprintf(":%d", i)
+	}
+
+	return key, true
+}
+
+// slot is the position in the pool of the slot r points to.
+func (b *PoolBuilder) slot(r *Data) (int, bool) {
+	if r == nil {
+		return 0, false
+	}
+
+	if i, ok := b.slots[r]; ok {
+		return i, true
+	}
+
+	// The pool may have moved since r was taken
+	if *r != nil {
+		if i, ok := b.constants[*r]; ok {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// index records the address and constant of slot i.
+func (b *PoolBuilder) index(i int) {
+	b.slots[&b.class.ConstantPool[i]] = i
+	if c := b.class.ConstantPool[i]; c != nil {
+		if _, dup := b.constants[c]; !dup {
+			b.constants[c] = i
+		}
+	}
+}
+
+// intern returns the slot holding a constant equal to c, adding c if there is
+// none. The references of c may point to slots of the pool before it moved.
+// If c cannot be added, the error is kept and c is returned in a slot of its
+// own, outside the pool.
+func (b *PoolBuilder) intern(c Data) *Data {
+	k, ok := b.key(c)
+	if !ok {
+		return b.fail(c, fmt.Errorf("%s has dangling references", c.Tag()))
+	}
+
+	if i, ok := b.entries[k]; ok {
+		return &b.class.ConstantPool[i]
+	}
+
+	n := 1
+	if IsWide(c) {
+		n = 2
+	}
+	if len(b.class.ConstantPool)+n >= MaxPoolCount {
+		return b.fail(c, fmt.Errorf("constant pool is full: adding %s would exceed %d entries", c.Tag(), MaxPoolCount-1))
+	}
+
+	for _, f := range referenceFields(c) {
+		i, _ := b.slot(*f)
+		*f = &b.class.ConstantPool[i]
+	}
+
+	i := len(b.class.ConstantPool)
+	b.grow(c)
+	if IsWide(c) {
+		b.grow(nil)
+	}
+	b.entries[k] = i
+
+	return &b.class.ConstantPool[i]
+}
+
+// fail keeps the first error, returning c outside the pool.
+func (b *PoolBuilder) fail(c Data, err error) *Data {
+	if b.err == nil {
+		b.err = err
+	}
+	return &c
+}
+
+// Err is the error of the first constant that could not be added.
+func (b *PoolBuilder) Err() error {
+	return b.err
+}
+
+// grow appends c to the pool, moving the references between constants if the
+// pool is reallocated.
+func (b *PoolBuilder) grow(c Data) {
+	old := b.class.ConstantPool
+	b.class.ConstantPool = append(old, c)
+
+	if len(old) == 0 || &old[0] == &b.class.ConstantPool[0] {
+		b.index(len(old))
+		return
+	}
+
+	moved := make(map[*Data]*Data, len(old))
+	clear(b.slots)
+	for i := range b.class.ConstantPool {
+		if i < len(old) {
+			moved[&old[i]] = &b.class.ConstantPool[i]
+		}
+		b.index(i)
+	}
+
+	for _, c := range b.class.ConstantPool {
+		for _, f := range referenceFields(c) {
+			if to, ok := moved[*f]; ok {
+				*f = to
+			}
+		}
+	}
+}
+
+// Index of the slot holding c, as written to the class file. It fails if c is
+// not in the pool, or if a constant could not be added to it.
+func (b *PoolBuilder) Index(c Data) (uint16, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	if i, ok := b.constants[c]; ok {
+		return uint16(i + 1), nil
+	}
+
+	return 0, fmt.Errorf("%s is not in the constant pool", c.Tag())
+}
+
+// Slot is the constant pool slot holding c, to be referred to by another
+// constant.
+func (b *PoolBuilder) Slot(c Data) (*Data, error) {
+	i, err := b.Index(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b.class.ConstantPool[i-1], nil
+}
+
+func (b *PoolBuilder) Utf8(v string) *ConstantUtf8 {
+	return (*b.intern(&ConstantUtf8{Value: v})).ConstantUtf8()
+}
+
+func (b *PoolBuilder) Integer(v int32) *ConstantInteger {
+	return (*b.intern(&ConstantInteger{Value: v})).ConstantInteger()
+}
+
+func (b *PoolBuilder) Float(v float32) *ConstantFloat {
+	return (*b.intern(&ConstantFloat{Value: v})).ConstantFloat()
+}
+
+func (b *PoolBuilder) Long(v int64) *ConstantLong {
+	return (*b.intern(&ConstantLong{Value: v})).ConstantLong()
+}
+
+func (b *PoolBuilder) Double(v float64) *ConstantDouble {
+	return (*b.intern(&ConstantDouble{Value: v})).ConstantDouble()
+}
+
+// Class interns the class with the given internal name, e.g. "java/lang/Object".
+func (b *PoolBuilder) Class(name string) *ConstantClass {
+	n := b.intern(&ConstantUtf8{Value: name})
+	return (*b.intern(&ConstantClass{Name: n})).ConstantClass()
+}
+
+func (b *PoolBuilder) String(v string) *ConstantString {
+	s := b.intern(&ConstantUtf8{Value: v})
+	return (*b.intern(&ConstantString{Value: s})).ConstantString()
+}
+
+func (b *PoolBuilder) NameAndType(name, descriptor string) *ConstantNameAndType {
+	n, d := b.intern(&ConstantUtf8{Value: name}), b.intern(&ConstantUtf8{Value: descriptor})
+	return (*b.intern(&ConstantNameAndType{Name: n, Descriptor: d})).ConstantNameAndType()
+}
+
+// member interns the class and name and type of a member reference, returning
+// their slots.
+func (b *PoolBuilder) member(class, name, descriptor string) (*Data, *Data) {
+	c := b.intern(&ConstantClass{Name: b.intern(&ConstantUtf8{Value: class})})
+	nat := b.intern(&ConstantNameAndType{
+		Name:       b.intern(&ConstantUtf8{Value: name}),
+		Descriptor: b.intern(&ConstantUtf8{Value: descriptor}),
+	})
+
+	return c, nat
+}
+
+func (b *PoolBuilder) Fieldref(class, name, descriptor string) *ConstantFieldref {
+	c, nat := b.member(class, name, descriptor)
+	return (*b.intern(&ConstantFieldref{Clazz: c, NameAndType: nat})).ConstantFieldref()
+}
+
+func (b *PoolBuilder) Methodref(class, name, descriptor string) *ConstantMethodref {
+	c, nat := b.member(class, name, descriptor)
+	return (*b.intern(&ConstantMethodref{Clazz: c, NameAndType: nat})).ConstantMethodref()
+}
+
+func (b *PoolBuilder) InterfaceMethodref(class, name, descriptor string) *ConstantInterfaceMethodref {
+	c, nat := b.member(class, name, descriptor)
+	return (*b.intern(&ConstantInterfaceMethodref{Clazz: c, NameAndType: nat})).ConstantInterfaceMethodref()
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPoolBuilder(t *testing.T) {
+	class := &Class{}
+	b := NewPoolBuilder(class)
+
+	// Grow the pool past several reallocations
+	first := b.Methodref("java/lang/Object", "<init>", "()V")
+	for i := range 100 {
+		b.Integer(int32(i))
+	}
+	b.Long(1)
+	b.Double(2)
+
+	if again := b.Methodref("java/lang/Object", "<init>", "()V"); again != first {
+		t.Fatal("equal method reference was not reused")
+	}
+
+	// Every reference must point into the current pool
+	for i, c := range class.ConstantPool {
+		for _, r := range References(c) {
+			if _, err := b.Index(*r); err != nil {
+				t.Fatalf("entry %d: %v", i+1, err)
+			}
+
+			found := false
+			for j := range class.ConstantPool {
+				found = found || r == &class.ConstantPool[j]
+			}
+			if !found {
+				t.Fatalf("entry %d refers to a slot outside the pool", i+1)
+			}
+		}
+	}
+
+	// Longs and doubles take two slots
+	l, _ := b.Index(b.Long(1))
+	d, _ := b.Index(b.Double(2))
+	if d != l+2 || class.ConstantPool[l] != nil {
+		t.Fatalf("long at %d and double at %d do not take two slots each", l, d)
+	}
+
+	if n := len(class.ConstantPool); n != 6+100+4 {
+		t.Fatalf("pool has %d entries", n)
+	}
+
+	// A builder over an existing pool reuses its entries
+	if NewPoolBuilder(class).Utf8("<init>") != *(*first.NameAndType).ConstantNameAndType().Name {
+		t.Fatal("existing entry was not reused")
+	}
+}
+
+func TestPoolBuilderErrors(t *testing.T) {
+	class := &Class{}
+	b := NewPoolBuilder(class)
+
+	var dangling Data = &ConstantUtf8{Value: "elsewhere"}
+	b.intern(&ConstantClass{Name: &dangling})
+	if _, err := b.Index(b.Utf8("x")); err == nil || !strings.Contains(err.Error(), "dangling") {
+		t.Fatalf("indexing after a dangling reference: got error %v", err)
+	}
+
+	class = &Class{}
+	b = NewPoolBuilder(class)
+	for i := range MaxPoolCount - 2 {
+		b.Integer(int32(i))
+	}
+	if _, err := b.Index(b.Integer(0)); err != nil {
+		t.Fatalf("indexing a full pool: %v", err)
+	}
+
+	b.Long(1)
+	if _, err := b.Slot(b.Integer(0)); err == nil || !strings.Contains(err.Error(), "constant pool is full") {
+		t.Fatalf("overflowing the pool: got error %v", err)
+	}
+	if n := len(class.ConstantPool); n != MaxPoolCount-2 {
+		t.Fatalf("pool has %d entries", n)
+	}
+}
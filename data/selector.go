@@ -0,0 +1,74 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Selector picks methods by name, narrowed down by descriptor and by the class
+// declaring them when given, as in "divideByN", "divideByN:(I)I" or jpamb's
+// "jpamb.cases.Simple.divideByN:(I)I".
+type Selector struct {
+	Class      string // internal name, e.g. jpamb/cases/Simple; empty matches any class
+	Name       string
+	Descriptor string // empty matches any descriptor
+}
+
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+
+	head, desc, hasDesc := strings.Cut(s, ":")
+	if hasDesc {
+		if _, _, err := SplitMethodDescriptor(desc); err != nil {
+			return sel, fmt.Errorf("selector %q: %w", s, err)
+		}
+		sel.Descriptor = desc
+	}
+
+	// Names cannot contain dots, so the last one separates the class
+	if i := strings.LastIndexByte(head, '.'); i >= 0 {
+		sel.Class = strings.ReplaceAll(head[:i], ".", "/")
+		head = head[i+1:]
+		if sel.Class == "" {
+			return sel, fmt.Errorf("selector %q: empty class name", s)
+		}
+	}
+
+	if head == "" {
+		return sel, fmt.Errorf("selector %q: empty method name", s)
+	}
+	sel.Name = head
+
+	return sel, nil
+}
+
+// Matches reports whether m is a method of class picked by the selector.
+func (s Selector) Matches(class *Class, m MemberInfo) bool {
+	if m.MemberType != METHOD || m.Name == nil || m.Name.Value != s.Name {
+		return false
+	}
+
+	if s.Descriptor != "" && (m.Descriptor == nil || m.Descriptor.Value != s.Descriptor) {
+		return false
+	}
+
+	if s.Class != "" {
+		if class.ThisClass == nil || class.ThisClass.Name == nil || *class.ThisClass.Name == nil ||
+			(*class.ThisClass.Name).Tag() != CP_UTF8 || (*class.ThisClass.Name).ConstantUtf8().Value != s.Class {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s Selector) String() string {
+	str := s.Name
+	if s.Class != "" {
+		str = strings.ReplaceAll(s.Class, "/", ".") + "." + str
+	}
+	if s.Descriptor != "" {
+		str += ":" + s.Descriptor
+	}
+	return str
+}
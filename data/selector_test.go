@@ -0,0 +1,35 @@
+package data
+
+import "testing"
+
+func TestParseSelector(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Selector
+	}{
+		{"divideByN", Selector{Name: "divideByN"}},
+		{"divideByN:(I)I", Selector{Name: "divideByN", Descriptor: "(I)I"}},
+		{"jpamb.cases.Simple.divideByN:(I)I", Selector{Class: "jpamb/cases/Simple", Name: "divideByN", Descriptor: "(I)I"}},
+		{"Simple.<init>", Selector{Class: "Simple", Name: "<init>"}},
+	} {
+		got, err := ParseSelector(tc.in)
+		if err != nil {
+			t.Errorf("%s: %v", tc.in, err)
+			continue
+		}
+
+		if got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.in, got, tc.want)
+		}
+
+		if got.String() != tc.in {
+			t.Errorf("%s: prints as %s", tc.in, got)
+		}
+	}
+
+	for _, in := range []string{"", "name:I", "name:(I", ".name", "pkg.Class.:()V"} {
+		if _, err := ParseSelector(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+// Package debugger runs the interpreter under the control of commands typed at
+// a prompt: stepping one instruction at a time, over invocations or up to a
+// breakpoint, and printing the operand stack, locals, heap and call stack.
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+// Prompt is written before each command is read.
+const Prompt = "(debug) "
+
+// ErrEnded is returned by commands that run the method once it has ended.
+var ErrEnded = errors.New("the run has ended")
+
+// Breakpoint stops the run on arriving at an instruction: at PC of Method, or
+// at the first instruction of Line in any method of Class.
+type Breakpoint struct {
+	Class  string // internal name
+	Method string // name and descriptor, empty for a line
+	PC     int
+	Line   int // source line, 0 for a pc
+}
+
+func (b Breakpoint) String() string {
+	if b.Method == "" {
+		return fmt.Sprintf("%s line %d", b.Class, b.Line)
+	}
+	return fmt.Sprintf("%s.%s pc %d", b.Class, b.Method, b.PC)
+}
+
+// Debugger drives an Interpreter that has been started.
+type Debugger struct {
+	in       *interpreter.Interpreter
+	out      io.Writer
+	maxSteps int
+
+	breaks []*Breakpoint // nil once deleted, so the others keep their number
+	lines  []position    // last seen of each frame on the call stack
+	last   string        // command an empty line repeats
+	ended  bool
+}
+
+// position of a frame, by the source line it is at.
+type position struct {
+	frame *interpreter.Frame
+	line  int
+}
+
+// New debugger of in, which must have been started, printing to out. The run
+// ends as not terminating after maxSteps instructions.
+func New(in *interpreter.Interpreter, out io.Writer, maxSteps int) *Debugger {
+	return &Debugger{in: in, out: out, maxSteps: maxSteps}
+}
+
+// Run reads commands from r until it is exhausted or quit is given, printing
+// where the run is first. Errors of commands are printed, not returned.
+func (d *Debugger) Run(r io.Reader) error {
+	d.where()
+
+	lines := bufio.NewScanner(r)
+	for {
+		fmt.Fprint(d.out, Prompt)
+		if !lines.Scan() {
+			fmt.Fprintln(d.out)
+			return lines.Err()
+		}
+
+		quit, err := d.Exec(lines.Text())
+		if err != nil {
+			fmt.Fprintf(d.out, "error: %v\n", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+const help = `step [n]         run n instructions, 1 by default, into invoked methods
+next             run one instruction, and any method it invokes
+continue         run until a breakpoint or the end
+break [pc]       stop at pc of the current method, the current pc by default
+break line <n>   stop on arriving at source line n of the current class
+breakpoints      list the breakpoints
+delete [n]       delete breakpoint n, or all of them
+where            print the current instruction
+list             print the current method, marking the pc and breakpoints
+stack            print the operand stack, the top last
+locals           print the local variables
+heap [ref]       print the objects and arrays on the heap, or the one at ref
+frames           print the call stack, the current method first
+help             print this help
+quit             leave the debugger
+An empty line repeats the previous command.
+`
+
+// Exec runs a command line, reporting whether it asks to quit.
+func (d *Debugger) Exec(line string) (quit bool, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		if fields = strings.Fields(d.last); len(fields) == 0 {
+			return false, nil
+		}
+	}
+	d.last = strings.Join(fields, " ")
+
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "step", "s":
+		n := 1
+		if len(args) > 0 {
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				return false, fmt.Errorf("expected a number of steps, got %s", args[0])
+			}
+		}
+		return false, d.run(func(steps int) bool { return steps >= n })
+	case "next", "n":
+		depth := len(d.in.Frames)
+		return false, d.run(func(int) bool { return len(d.in.Frames) <= depth })
+	case "continue", "c":
+		return false, d.run(func(int) bool { return false })
+	case "break", "b":
+		return false, d.breakpoint(args)
+	case "breakpoints":
+		for i, b := range d.breaks {
+			if b != nil {
+				fmt.Fprintf(d.out, "%d: %s\n", i+1, b)
+			}
+		}
+	case "delete", "d":
+		return false, d.delete(args)
+	case "where", "w":
+		d.where()
+	case "list", "l":
+		return false, d.list()
+	case "stack":
+		for i, v := range d.in.Frame().Stack {
+			fmt.Fprintf(d.out, "%d: %s\n", i, d.value(v))
+		}
+	case "locals":
+		for i, v := range d.in.Frame().Locals {
+			fmt.Fprintf(d.out, "%d: %s\n", i, d.value(v))
+		}
+	case "heap":
+		return false, d.heap(args)
+	case "frames", "bt":
+		for i, t := range d.in.Trace() {
+			fmt.Fprintf(d.out, "#%d %s pc %d\n", i, t, t.PC)
+		}
+	case "help", "h":
+		fmt.Fprint(d.out, help)
+	case "quit", "q":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command %s, see help", cmd)
+	}
+	return false, nil
+}
+
+// run steps until done reports true after a step, a breakpoint is arrived at,
+// or the run ends, then prints where it stopped.
+func (d *Debugger) run(done func(steps int) bool) error {
+	if d.ended {
+		return ErrEnded
+	}
+
+	d.arrived()
+	for steps := 1; ; steps++ {
+		var err error
+		if d.in.Steps >= d.maxSteps {
+			err = interpreter.ErrBudget
+		} else {
+			err = d.in.Step()
+		}
+		if err != nil || d.in.Done {
+			return d.end(err)
+		}
+
+		if i := d.hit(); i >= 0 {
+			fmt.Fprintf(d.out, "breakpoint %d, ", i+1)
+			d.where()
+			return nil
+		}
+
+		if done(steps) {
+			d.where()
+			return nil
+		}
+	}
+}
+
+// hit is the index of the breakpoint at the current instruction, or -1.
+func (d *Debugger) hit() int {
+	f, arrived := d.in.Frame(), d.arrived()
+	for i, b := range d.breaks {
+		switch {
+		case b == nil || b.Class != f.Method.ClassName:
+		case b.Method != "":
+			if b.Method == f.Method.String() && b.PC == f.PC {
+				return i
+			}
+		case arrived && f.Method.Line(f.PC) == b.Line:
+			return i
+		}
+	}
+	return -1
+}
+
+// arrived reports whether the current frame is new, or at another line than
+// when last seen, so that returning to the middle of a line is not arriving.
+func (d *Debugger) arrived() bool {
+	f, depth := d.in.Frame(), len(d.in.Frames)
+	at := position{frame: f, line: f.Method.Line(f.PC)}
+
+	d.lines = d.lines[:min(len(d.lines), depth)]
+	for len(d.lines) < depth {
+		d.lines = append(d.lines, position{})
+	}
+
+	arrived := d.lines[depth-1] != at
+	d.lines[depth-1] = at
+	return arrived
+}
+
+// end the run with err, as Step returned it, printing how it terminated.
+func (d *Debugger) end(err error) error {
+	d.ended = true
+
+	t, err := d.in.Classify(err)
+	if t.Thrown != nil {
+		fmt.Fprintf(d.out, "Exception in thread \"main\" %s", t.Thrown.StackTrace())
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case t.Outcome == interpreter.OK:
+		m := d.in.Frames[0].Method
+		_, ret, _ := data.SplitMethodDescriptor(m.Info.Descriptor.Value)
+		fmt.Fprintf(d.out, "returned %s in %d steps, at pc %d\n", d.in.Format(t.Result, ret), d.in.Steps, t.PC)
+	case t.Outcome == interpreter.NON_TERMINATION && t.Thrown == nil:
+		fmt.Fprintf(d.out, "ran out of %d steps, at pc %d\n", d.in.Steps, t.PC)
+	default:
+		fmt.Fprintf(d.out, "threw in %d steps, at pc %d\n", d.in.Steps, t.PC)
+	}
+	fmt.Fprintln(d.out, t.Outcome)
+	return nil
+}
+
+func (d *Debugger) breakpoint(args []string) error {
+	f := d.in.Frame()
+	b := &Breakpoint{Class: f.Method.ClassName, Method: f.Method.String(), PC: f.PC}
+
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "line":
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("expected a line number, got %s", args[1])
+		}
+		b.Method, b.PC, b.Line = "", 0, n
+	case len(args) == 1:
+		pc, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("expected a pc, got %s", args[0])
+		}
+		if _, ok := f.Method.At(pc); !ok {
+			return fmt.Errorf("no instruction at %d of %s", pc, f.Method)
+		}
+		b.PC = pc
+	default:
+		return fmt.Errorf("expected break [pc] or break line <n>")
+	}
+
+	d.breaks = append(d.breaks, b)
+	fmt.Fprintf(d.out, "breakpoint %d at %s\n", len(d.breaks), b)
+	return nil
+}
+
+func (d *Debugger) delete(args []string) error {
+	if len(args) == 0 {
+		d.breaks = nil
+		return nil
+	}
+
+	i, err := strconv.Atoi(args[0])
+	if err != nil || i < 1 || i > len(d.breaks) || d.breaks[i-1] == nil {
+		return fmt.Errorf("no breakpoint %s", args[0])
+	}
+	d.breaks[i-1] = nil
+	return nil
+}
+
+// where prints the current instruction and its source line.
+func (d *Debugger) where() {
+	f := d.in.Frame()
+	fmt.Fprintf(d.out, "%s.%s", f.Method.ClassName, f.Method)
+	if line := f.Method.Line(f.PC); line >= 0 {
+		fmt.Fprintf(d.out, " line %d", line)
+	}
+	fmt.Fprintf(d.out, " pc %d: %s\n", f.PC, d.instruction(f.Method, f.PC))
+}
+
+// list prints the instructions of the current method, with => at the pc and
+// * at breakpoints.
+func (d *Debugger) list() error {
+	f := d.in.Frame()
+	line := -1
+	for i := range f.Method.Ops {
+		pc := f.Method.PC(i)
+		if l := f.Method.Line(pc); l != line && l >= 0 {
+			fmt.Fprintf(d.out, "  ; line %d\n", l)
+			line = l
+		}
+
+		mark := "  "
+		if pc == f.PC {
+			mark = "=>"
+		}
+		for _, b := range d.breaks {
+			if b != nil && *b == (Breakpoint{Class: f.Method.ClassName, Method: f.Method.String(), PC: pc}) {
+				mark = mark[:1] + "*"
+			}
+		}
+
+		instr, err := assembler.Instruction(f.Method.Class, pc, f.Method.Ops[i])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(d.out, "%s %4d  %s\n", mark, pc, instr)
+	}
+	return nil
+}
+
+func (d *Debugger) heap(args []string) error {
+	if len(args) == 0 {
+		for i := range d.in.Heap.Len() {
+			ref := interpreter.Value{Kind: interpreter.REFERENCE, V: int32(i + 1)}
+			fmt.Fprintf(d.out, "%s\n", d.value(ref))
+		}
+		return nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(args[0], "@"))
+	if err != nil || n < 1 || n > d.in.Heap.Len() {
+		return fmt.Errorf("no object at %s", args[0])
+	}
+	fmt.Fprintf(d.out, "%s\n", d.value(interpreter.Value{Kind: interpreter.REFERENCE, V: int32(n)}))
+	return nil
+}
+
+// value is v, followed by what it refers to.
+func (d *Debugger) value(v interpreter.Value) string {
+	if v.Kind != interpreter.REFERENCE || v.IsNull() {
+		return v.String()
+	}
+	cell, err := d.in.Heap.Get(v)
+	if err != nil {
+		return fmt.Sprintf("%s (%v)", v, err)
+	}
+	return fmt.Sprintf("%s %v", v, cell)
+}
+
+// instruction at pc of m, with its operands resolved.
+func (d *Debugger) instruction(m *interpreter.Method, pc int) string {
+	i, ok := m.At(pc)
+	if !ok {
+		return fmt.Sprintf("no instruction at %d", pc)
+	}
+	instr, err := assembler.Instruction(m.Class, pc, m.Ops[i])
+	if err != nil {
+		return err.Error()
+	}
+	return instr
+}
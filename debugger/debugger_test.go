@@ -0,0 +1,150 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/internal/testutil"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+const source = `.class public super jpamb/cases/Loops
+.super java/lang/Object
+.source Loops.java
+
+.method public static sum([I)I
+  .limit stack 3
+  .limit locals 3
+  .line 3
+  iconst_0
+  istore_1
+  .line 4
+  iconst_0
+  istore_2
+L4:
+  iload_2
+  aload_0
+  arraylength
+  if_icmpge L25
+  .line 5
+  iload_1
+  aload_0
+  iload_2
+  iaload
+  invokestatic jpamb/cases/Loops/twice(I)I
+  iadd
+  istore_1
+  .line 4
+  iinc 2 1
+  goto L4
+L25:
+  .line 7
+  iload_1
+  ireturn
+.end method
+
+.method public static twice(I)I
+  .limit stack 2
+  .limit locals 1
+  .line 10
+  iload_0
+  iconst_2
+  imul
+  ireturn
+.end method
+`
+
+func TestDebugger(t *testing.T) {
+	cp, classes := testutil.Classpath(t, source)
+	c := classes[0]
+
+	debug := func(commands string) string {
+		m, err := c.Method(data.Selector{Name: "sum"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		in := interpreter.New(interpreter.WithClasspath(cp))
+		arr, _ := in.Heap.NewArray("I", 2)
+		a, _ := in.Heap.Get(arr)
+		copy(a.(*interpreter.Array).Elems, []int32{3, 4})
+		if err := in.Start(m, []interpreter.Value{arr}); err != nil {
+			t.Fatal(err)
+		}
+
+		var out strings.Builder
+		if err := New(in, &out, 1000).Run(strings.NewReader(commands)); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	tests := []struct {
+		name     string
+		commands string
+		want     []string
+	}{
+		{
+			name:     "step into",
+			commands: "break 14\ncontinue\nstep\nframes\n",
+			want: []string{
+				"jpamb/cases/Loops.sum([I)I line 3 pc 0: iconst_0\n",
+				"breakpoint 1, jpamb/cases/Loops.sum([I)I line 5 pc 14: invokestatic jpamb/cases/Loops/twice(I)I\n",
+				"jpamb/cases/Loops.twice(I)I line 10 pc 0: iload_0\n",
+				"#0 jpamb.cases.Loops.twice(Loops.java:10) pc 0\n#1 jpamb.cases.Loops.sum(Loops.java:5) pc 14\n",
+			},
+		},
+		{
+			name:     "next over",
+			commands: "b 14\nc\nnext\nstack\nlocals\n",
+			want: []string{
+				"jpamb/cases/Loops.sum([I)I line 5 pc 17: iadd\n",
+				"0: 0\n1: 6\n",
+				"0: @1 [I:3,4]\n1: 0\n2: 0\n",
+			},
+		},
+		{
+			name:     "line",
+			commands: "break line 5\nc\nc\nlocals\nc\n",
+			want: []string{
+				"breakpoint 1 at jpamb/cases/Loops line 5\n",
+				"breakpoint 1, jpamb/cases/Loops.sum([I)I line 5 pc 10: iload_1\n",
+				"0: @1 [I:3,4]\n1: 6\n2: 1\n",
+				"returned 14 in 44 steps, at pc 26\nok\n",
+			},
+		},
+		{
+			name:     "repeat",
+			commands: "step 3\n\n\nheap\nlist\n",
+			want: []string{
+				"jpamb/cases/Loops.sum([I)I line 4 pc 3: istore_2\n",
+				"jpamb/cases/Loops.sum([I)I line 4 pc 6: arraylength\n",
+				"jpamb/cases/Loops.sum([I)I line 5 pc 11: aload_0\n",
+				"@1 [I:3,4]\n",
+				"  ; line 5\n     10  iload_1\n=>   11  aload_0\n",
+			},
+		},
+		{
+			name:     "errors",
+			commands: "break 8\nbreak x\nfly\ndelete 2\nc\nstep\n",
+			want: []string{
+				"error: no instruction at 8 of sum([I)I\n",
+				"error: expected a pc, got x\n",
+				"error: unknown command fly, see help\n",
+				"error: no breakpoint 2\n",
+				"error: the run has ended\n",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := debug(tc.commands)
+			for _, want := range tc.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output lacks %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+// Package testutil holds fixtures shared by the tests of several packages.
+package testutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+// Classpath assembles and defines the classes in sources, returning them in
+// order and a classpath holding only them. It fails t if any does not build.
+func Classpath(t *testing.T, sources ...string) (*interpreter.Classpath, []*interpreter.Class) {
+	t.Helper()
+
+	cp := interpreter.NewClasspath(nil)
+	var classes []*interpreter.Class
+	for _, src := range sources {
+		class, resolve, err := assembler.Assemble(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := interpreter.Define(class, resolve)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cp.Add(c)
+		classes = append(classes, c)
+	}
+
+	return cp, classes
+}
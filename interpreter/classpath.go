@@ -0,0 +1,268 @@
+package interpreter
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/parser"
+)
+
+// Class is a class loaded to be run, with the code of its methods resolved.
+type Class struct {
+	Name    string // internal name, e.g. jpamb/cases/Simple
+	Super   string // internal name of the superclass, empty for java/lang/Object
+	Data    *data.Class
+	methods map[string]*Method // by name and descriptor, of those with code
+	broken  map[string]error   // by name and descriptor, of those whose code did not resolve
+}
+
+// Define resolves the methods of class, so that it no longer needs resolve. A
+// method that fails to resolve only fails when it is picked or invoked, so the
+// others still run.
+func Define(class *data.Class, resolve data.Resolver) (*Class, error) {
+	if class.ThisClass == nil {
+		return nil, fmt.Errorf("class has no name")
+	}
+
+	name, err := utf8(class.ThisClass.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Class{Name: name, Data: class, methods: make(map[string]*Method), broken: make(map[string]error)}
+	if class.SuperClass != nil {
+		if c.Super, err = utf8(class.SuperClass.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, info := range class.Methods {
+		if _, ok := info.Attributes[data.ATTR_CODE]; !ok {
+			continue
+		}
+		if info.Unparsable {
+			c.broken[info.Name.Value+info.Descriptor.Value] = fmt.Errorf("%s.%s%s: code could not be parsed", name, info.Name.Value, info.Descriptor.Value)
+			continue
+		}
+
+		m, err := NewMethod(class, info, resolve)
+		if err != nil {
+			c.broken[info.Name.Value+info.Descriptor.Value] = fmt.Errorf("%s.%s%s: %w", name, info.Name.Value, info.Descriptor.Value, err)
+			continue
+		}
+		c.methods[m.String()] = m
+	}
+
+	return c, nil
+}
+
+// unparsable records d as the error of the method whose code it is in,
+// reporting whether there is one.
+func (c *Class) unparsable(d *parser.ParseError) bool {
+	for _, info := range c.Data.Methods {
+		h, ok := info.Attributes[data.ATTR_CODE]
+		if !ok || d.Offset < h.Begin || d.Offset >= h.Begin+int64(h.Length) {
+			continue
+		}
+
+		key := info.Name.Value + info.Descriptor.Value
+		delete(c.methods, key)
+		// the first problem found in the code is the one to report
+		if perr := (*parser.ParseError)(nil); !errors.As(c.broken[key], &perr) {
+			c.broken[key] = fmt.Errorf("%s.%s: %w", c.Name, key, d)
+		}
+		return true
+	}
+	return false
+}
+
+// method by name and descriptor, nil if it has no code, or the error resolving
+// it.
+func (c *Class) method(key string) (*Method, error) {
+	if err, ok := c.broken[key]; ok {
+		return nil, err
+	}
+	return c.methods[key], nil
+}
+
+// Method is the method of the class picked by sel, which must be the only one
+// and have code.
+func (c *Class) Method(sel data.Selector) (*Method, error) {
+	if sel.Class != "" && sel.Class != c.Name {
+		return nil, fmt.Errorf("%s is not a method of %s", sel, c.Name)
+	}
+
+	var found []data.MemberInfo
+	for _, info := range c.Data.Methods {
+		if sel.Matches(c.Data, info) {
+			found = append(found, info)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no method of %s matches %s", c.Name, sel)
+	case 1:
+	default:
+		var descs []string
+		for _, info := range found {
+			descs = append(descs, info.Descriptor.Value)
+		}
+		return nil, fmt.Errorf("%s is ambiguous in %s, give one of the descriptors %s", sel, c.Name, strings.Join(descs, ", "))
+	}
+
+	m, err := c.method(found[0].Name.Value + found[0].Descriptor.Value)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("method %s.%s%s has no code", c.Name, found[0].Name.Value, found[0].Descriptor.Value)
+	}
+	return m, nil
+}
+
+// Methods of the class with resolved code, in the order it declares them.
+func (c *Class) Methods() []*Method {
+	var methods []*Method
+	for _, info := range c.Data.Methods {
+		if m, ok := c.methods[info.Name.Value+info.Descriptor.Value]; ok {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+// Classpath finds classes by internal name in directories and jars, in order,
+// as the -classpath of java does.
+type Classpath struct {
+	entries []string
+	opts    []parser.Option
+	classes map[string]*Class
+}
+
+// NewClasspath searches entries, directories or .jar files, parsing the
+// classes found there with opts.
+func NewClasspath(entries []string, opts ...parser.Option) *Classpath {
+	return &Classpath{entries: entries, opts: opts, classes: make(map[string]*Class)}
+}
+
+// ParseClasspath splits a classpath on the list separator of the OS, : or ;.
+func ParseClasspath(s string, opts ...parser.Option) *Classpath {
+	return NewClasspath(filepath.SplitList(s), opts...)
+}
+
+// Add makes class loadable, taking precedence over the entries.
+func (cp *Classpath) Add(class *Class) {
+	cp.classes[class.Name] = class
+}
+
+// Classes loaded or added so far, by name.
+func (cp *Classpath) Classes() []*Class {
+	return slices.SortedFunc(maps.Values(cp.classes), func(a, b *Class) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+// Load is the class with the internal name, loaded once.
+func (cp *Classpath) Load(ctx context.Context, name string) (*Class, error) {
+	if c, ok := cp.classes[name]; ok {
+		return c, nil
+	}
+
+	b, err := cp.find(name + ".class")
+	if err != nil {
+		return nil, err
+	}
+
+	// parsed leniently, so that code which fails to parse only fails its
+	// method, but any other damage still fails the class
+	s, err := parser.OpenReader(ctx, bytes.NewReader(b), append(slices.Clone(cp.opts), parser.Lenient())...)
+	if err != nil {
+		return nil, err
+	}
+
+	class, err := s.Class()
+	var c *Class
+	if err == nil {
+		c, err = Define(class, s.Request)
+	}
+	diags := s.Close()
+	if err != nil {
+		return nil, fmt.Errorf("class %s: %w", name, err)
+	}
+
+	for _, d := range diags {
+		if !c.unparsable(d) {
+			return nil, fmt.Errorf("class %s: %w", name, d)
+		}
+	}
+
+	if c.Name != name {
+		return nil, fmt.Errorf("class %s was found to be %s", name, c.Name)
+	}
+
+	cp.classes[name] = c
+	return c, nil
+}
+
+// find reads the first file by the name in the entries.
+func (cp *Classpath) find(file string) ([]byte, error) {
+	for _, entry := range cp.entries {
+		var (
+			b   []byte
+			err error
+		)
+		if filepath.Ext(entry) == ".jar" {
+			b, err = readJarEntry(entry, file)
+		} else {
+			b, err = os.ReadFile(filepath.Join(entry, filepath.FromSlash(file)))
+		}
+
+		switch {
+		case err == nil:
+			return b, nil
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("%s not found on the classpath %s", file, strings.Join(cp.entries, string(filepath.ListSeparator)))
+}
+
+// readJarEntry reads the file by the name in jar, which may be no larger than
+// the parser reads of a class by default.
+func readJarEntry(jar, name string) ([]byte, error) {
+	z, err := zip.OpenReader(jar)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	f, err := z.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	max := parser.DefaultLimits().MaxBytesRead
+	if info, err := f.Stat(); err == nil && info.Size() > max {
+		return nil, fmt.Errorf("%s!%s: entry of %d bytes exceeds limit of %d", jar, name, info.Size(), max)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(f, max+1))
+	if err == nil && int64(len(b)) > max {
+		return nil, fmt.Errorf("%s!%s: more than %d bytes, exceeding the limit", jar, name, max)
+	}
+	return b, err
+}
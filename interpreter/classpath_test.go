@@ -0,0 +1,119 @@
+package interpreter_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+	"github.com/luishfonseca/dtu_pa/writer"
+)
+
+func TestClasspath(t *testing.T) {
+	class, resolve, err := assembler.Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := writer.Bytes(class, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	classes := filepath.Join(dir, "classes")
+	if err := os.MkdirAll(filepath.Join(classes, "jpamb/cases"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(classes, "jpamb/cases/Loops.class"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	jar, err := os.Create(filepath.Join(dir, "lib.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := zip.NewWriter(jar)
+	w, err := z.Create("jpamb/cases/Loops.class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(b)
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	jar.Close()
+
+	for _, cp := range []string{classes, jar.Name(), filepath.Join(dir, "missing") + string(filepath.ListSeparator) + jar.Name()} {
+		c, err := interpreter.ParseClasspath(cp).Load(context.Background(), "jpamb/cases/Loops")
+		if err != nil {
+			t.Errorf("%s: %v", cp, err)
+			continue
+		}
+
+		m, err := c.Method(data.Selector{Class: "jpamb/cases/Loops", Name: "divide", Descriptor: "(II)I"})
+		if err != nil {
+			t.Errorf("%s: %v", cp, err)
+			continue
+		}
+
+		in := interpreter.New()
+		if err := in.Start(m, []interpreter.Value{interpreter.Int(6), interpreter.Int(3)}); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := in.Execute(10); err != nil || got.Result != interpreter.Int(2) {
+			t.Errorf("%s: divide(6, 3) = %v, %v", cp, got.Result, err)
+		}
+	}
+
+	cp := interpreter.ParseClasspath(classes)
+	if _, err := cp.Load(context.Background(), "jpamb/cases/Missing"); err == nil {
+		t.Error("expected a missing class not to load")
+	}
+
+	c, err := cp.Load(context.Background(), "jpamb/cases/Loops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sel := range []data.Selector{{Name: "missing"}, {Class: "jpamb/cases/Other", Name: "sum"}, {Name: "sum", Descriptor: "()I"}} {
+		if _, err := c.Method(sel); err == nil {
+			t.Errorf("%s: expected no method", sel)
+		}
+	}
+
+	// ineg, which the parser does not decode, in place of the iadd of sum
+	broken := filepath.Join(dir, "broken")
+	if err := os.MkdirAll(filepath.Join(broken, "jpamb/cases"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(b, []byte{0x1b, 0x1a, 0x60}) != 1 {
+		t.Fatal("expected iload_1 iload_0 iadd once in the class")
+	}
+	b = bytes.Replace(b, []byte{0x1b, 0x1a, 0x60}, []byte{0x1b, 0x1a, 0x74}, 1)
+	if err := os.WriteFile(filepath.Join(broken, "jpamb/cases/Loops.class"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err = interpreter.ParseClasspath(broken).Load(context.Background(), "jpamb/cases/Loops"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := c.Method(data.Selector{Name: "divide"})
+	if err != nil {
+		t.Fatalf("divide with sum unresolved: %v", err)
+	}
+	in := interpreter.New()
+	if err := in.Start(m, []interpreter.Value{interpreter.Int(6), interpreter.Int(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := in.Execute(10); err != nil || got.Result != interpreter.Int(2) {
+		t.Errorf("divide(6, 3) with sum unresolved = %v, %v", got.Result, err)
+	}
+	if _, err := c.Method(data.Selector{Name: "sum"}); err == nil || !strings.Contains(err.Error(), "unimplemented bytecode: 0x74") {
+		t.Errorf("sum = %v, want its bytecode unimplemented", err)
+	}
+}
@@ -0,0 +1,239 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Exception is thrown by an instruction, e.g. java/lang/ArithmeticException by
+// idiv when dividing by zero, and returned by Step when no handler catches it.
+type Exception struct {
+	Class   string // internal name, e.g. java/lang/ArithmeticException
+	Message string
+	PC      int            // where it was thrown, in the innermost frame
+	Ref     Value          // the throwable on the heap
+	Trace   []TraceElement // the frames it was thrown from, innermost first
+	Cause   *Exception     // wrapped by it, e.g. by ExceptionInInitializerError
+}
+
+func (e *Exception) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s at %d", e.Class, e.PC)
+	}
+	return fmt.Sprintf("%s: %s at %d", e.Class, e.Message, e.PC)
+}
+
+// StackTrace as the JVM prints it, e.g.
+//
+//	java.lang.ArithmeticException: / by zero
+//		at jpamb.cases.Simple.divideByN(Simple.java:12)
+func (e *Exception) StackTrace() string {
+	var str strings.Builder
+	str.WriteString(strings.ReplaceAll(e.Class, "/", "."))
+	if e.Message != "" {
+		str.WriteString(": " + e.Message)
+	}
+	str.WriteString("\n")
+	for _, t := range e.Trace {
+		fmt.Fprintf(&str, "\tat %s\n", t)
+	}
+	if e.Cause != nil {
+		str.WriteString("Caused by: " + e.Cause.StackTrace())
+	}
+	return str.String()
+}
+
+// TraceElement is a frame of a stack trace.
+type TraceElement struct {
+	Class  string // internal name
+	Method string
+	File   string // empty without a SourceFile attribute
+	Line   int    // -1 without a LineNumberTable
+	PC     int
+}
+
+func (t TraceElement) String() string {
+	where := "Unknown Source"
+	if t.File != "" {
+		where = t.File
+		if t.Line >= 0 {
+			where += fmt.Sprintf(":%d", t.Line)
+		}
+	}
+	return fmt.Sprintf("%s.%s(%s)", strings.ReplaceAll(t.Class, "/", "."), t.Method, where)
+}
+
+// jdk are the classes of the JDK the interpreter knows without loading them,
+// by their superclass: Object, the classes DefaultNatives model, and the
+// throwables it throws or that are commonly thrown and caught. They have no
+// fields or static initialisers, and their methods are run by Natives.
+var jdk = map[string]string{
+	"java/lang/Object":                          "",
+	"java/lang/Class":                           "java/lang/Object",
+	"java/lang/Math":                            "java/lang/Object",
+	"java/lang/Integer":                         "java/lang/Object",
+	"java/lang/String":                          "java/lang/Object",
+	"java/lang/StringBuilder":                   "java/lang/Object",
+	"java/util/Arrays":                          "java/lang/Object",
+	"java/lang/Throwable":                       "java/lang/Object",
+	"java/lang/Exception":                       "java/lang/Throwable",
+	"java/lang/Error":                           "java/lang/Throwable",
+	"java/lang/RuntimeException":                "java/lang/Exception",
+	"java/lang/ArithmeticException":             "java/lang/RuntimeException",
+	"java/lang/ArrayStoreException":             "java/lang/RuntimeException",
+	"java/lang/ClassCastException":              "java/lang/RuntimeException",
+	"java/lang/IllegalArgumentException":        "java/lang/RuntimeException",
+	"java/lang/NumberFormatException":           "java/lang/IllegalArgumentException",
+	"java/lang/IllegalStateException":           "java/lang/RuntimeException",
+	"java/lang/IndexOutOfBoundsException":       "java/lang/RuntimeException",
+	"java/lang/ArrayIndexOutOfBoundsException":  "java/lang/IndexOutOfBoundsException",
+	"java/lang/StringIndexOutOfBoundsException": "java/lang/IndexOutOfBoundsException",
+	"java/lang/NegativeArraySizeException":      "java/lang/RuntimeException",
+	"java/lang/NullPointerException":            "java/lang/RuntimeException",
+	"java/lang/UnsupportedOperationException":   "java/lang/RuntimeException",
+	"java/lang/AssertionError":                  "java/lang/Error",
+	"java/lang/LinkageError":                    "java/lang/Error",
+	"java/lang/ExceptionInInitializerError":     "java/lang/LinkageError",
+	"java/lang/NoClassDefFoundError":            "java/lang/LinkageError",
+	"java/lang/VirtualMachineError":             "java/lang/Error",
+	"java/lang/StackOverflowError":              "java/lang/VirtualMachineError",
+	"java/lang/OutOfMemoryError":                "java/lang/VirtualMachineError",
+}
+
+func builtin(class string) bool {
+	_, ok := jdk[class]
+	return ok
+}
+
+// superclass of class, loading it if it is not built in.
+func (in *Interpreter) superclass(class string) (string, error) {
+	if super, ok := jdk[class]; ok {
+		return super, nil
+	}
+
+	c, err := in.classpath.Load(in.ctx, class)
+	if err != nil {
+		return "", err
+	}
+	return c.Super, nil
+}
+
+// subclass reports whether class is of, or extends it.
+func (in *Interpreter) subclass(class, of string) (bool, error) {
+	for class != "" {
+		if class == of {
+			return true, nil
+		}
+
+		var err error
+		if class, err = in.superclass(class); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// raise e in the running frame, jumping to the nearest handler that catches
+// it and dropping the frames in between. When there is none, e is returned
+// with its stack trace. Leaving a <clinit> marks its class erroneous and, as
+// the JVM does, wraps exceptions that are not Errors in an
+// ExceptionInInitializerError.
+func (in *Interpreter) raise(e *Exception) error {
+	if e.Ref.Kind == VOID {
+		e.Ref = in.Heap.NewObject(e.Class)
+	}
+
+	// rethrown exceptions keep the message and trace of when they were
+	// first thrown
+	if first, ok := in.thrown[e.Ref.V]; ok {
+		e.Message, e.Trace = first.Message, first.Trace
+	} else {
+		e.Trace = in.Trace()
+		in.thrown[e.Ref.V] = e
+	}
+
+	for i := len(in.Frames) - 1; i >= 0; i-- {
+		f := in.Frames[i]
+		pc, ok, err := in.handler(f, e.Class)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			in.Frames = in.Frames[:i+1]
+			f.Stack, f.PC = f.Stack[:0], pc
+			return f.push(e.Ref)
+		}
+
+		if f.initialiser {
+			in.states[f.Method.ClassName] = erroneous
+			if e, err = in.uninitialised(e, i); err != nil {
+				return err
+			}
+		}
+	}
+
+	return e
+}
+
+// uninitialised is e as it leaves the <clinit> of frame i, for the frame below
+// to catch.
+func (in *Interpreter) uninitialised(e *Exception, i int) (*Exception, error) {
+	if i == 0 {
+		return e, nil
+	}
+
+	if isError, err := in.subclass(e.Class, "java/lang/Error"); err != nil || isError {
+		return e, err
+	}
+
+	wrapped := &Exception{
+		Class: "java/lang/ExceptionInInitializerError",
+		PC:    in.Frames[i-1].PC,
+		Ref:   in.Heap.NewObject("java/lang/ExceptionInInitializerError"),
+		Trace: in.Trace()[len(in.Frames)-i:],
+		Cause: e,
+	}
+	in.thrown[wrapped.Ref.V] = wrapped
+	return wrapped, nil
+}
+
+// handler is the pc of the first handler of f to catch class at its pc.
+func (in *Interpreter) handler(f *Frame, class string) (int, bool, error) {
+	for _, entry := range f.Method.Code.ExceptionTable {
+		if f.PC < int(entry.StartPC) || f.PC >= int(entry.EndPC) {
+			continue
+		}
+
+		if entry.CatchType == nil {
+			return int(entry.HandlerPC), true, nil
+		}
+
+		catch, err := utf8(entry.CatchType.Name)
+		if err != nil {
+			return 0, false, err
+		}
+
+		if ok, err := in.subclass(class, catch); err != nil || ok {
+			return int(entry.HandlerPC), ok, err
+		}
+	}
+
+	return 0, false, nil
+}
+
+// Trace is the call stack, innermost first.
+func (in *Interpreter) Trace() []TraceElement {
+	trace := make([]TraceElement, 0, len(in.Frames))
+	for i := len(in.Frames) - 1; i >= 0; i-- {
+		f := in.Frames[i]
+		trace = append(trace, TraceElement{
+			Class:  f.Method.ClassName,
+			Method: f.Method.Info.Name.Value,
+			File:   f.Method.Source,
+			Line:   f.Method.Line(f.PC),
+			PC:     f.PC,
+		})
+	}
+	return trace
+}
@@ -0,0 +1,158 @@
+package interpreter_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/internal/testutil"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+const exceptions = `.class public super jpamb/cases/Exceptions
+.source Exceptions.java
+
+.method public static fail()I
+  .limit stack 2
+  .limit locals 0
+  .line 3
+  iconst_1
+  .line 4
+  iconst_0
+  idiv
+  ireturn
+.end method
+
+.method public static safeDivide(II)I
+  .limit stack 2
+  .limit locals 2
+L0:
+  iload_0
+  iload_1
+  idiv
+  ireturn
+L4:
+  bipush -1
+  ireturn
+  .catch java/lang/ArithmeticException from L0 to L4 using L4
+.end method
+
+.method public static unwind()I
+  .limit stack 1
+  .limit locals 1
+  .line 10
+L0:
+  invokestatic jpamb/cases/Exceptions/fail()I
+  ireturn
+L4:
+  astore_0
+  iconst_2
+  ireturn
+  .catch java/lang/IllegalStateException from L0 to L4 using L4
+  .catch java/lang/RuntimeException from L0 to L4 using L4
+.end method
+
+.method public static custom()I
+  .limit stack 2
+  .limit locals 1
+L0:
+  new jpamb/cases/Oops
+  dup
+  invokespecial jpamb/cases/Oops/<init>()V
+  athrow
+L8:
+  astore_0
+  iconst_3
+  ireturn
+  .catch java/lang/Exception from L0 to L8 using L8
+.end method
+
+.method public static rethrow()I
+  .limit stack 1
+  .limit locals 1
+  .line 20
+L0:
+  invokestatic jpamb/cases/Exceptions/fail()I
+  ireturn
+L4:
+  .line 22
+  astore_0
+  aload_0
+  athrow
+  .catch all from L0 to L4 using L4
+.end method
+
+.method public static outside()I
+  .limit stack 2
+  .limit locals 0
+L0:
+  nop
+L1:
+  iconst_1
+  iconst_0
+  idiv
+  ireturn
+  .catch all from L0 to L1 using L1
+.end method
+`
+
+const oops = `.class public super jpamb/cases/Oops
+.super java/lang/RuntimeException
+
+.method public <init>()V
+  .limit stack 1
+  .limit locals 1
+  aload_0
+  invokespecial java/lang/RuntimeException/<init>()V
+  return
+.end method
+`
+
+func TestExceptions(t *testing.T) {
+	cp, _ := testutil.Classpath(t, exceptions, oops)
+
+	run := func(name string, args ...interpreter.Value) (interpreter.Value, error) {
+		c, _ := cp.Load(t.Context(), "jpamb/cases/Exceptions")
+		m, err := c.Method(data.Selector{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		in := interpreter.New(interpreter.WithClasspath(cp))
+		if err := in.Start(m, args); err != nil {
+			t.Fatal(err)
+		}
+		err = in.Run(1000)
+		return in.Result, err
+	}
+
+	for name, want := range map[string]int32{"unwind": 2, "custom": 3} {
+		if got, err := run(name); err != nil || got != interpreter.Int(want) {
+			t.Errorf("%s() = %v, %v, want %d", name, got, err, want)
+		}
+	}
+
+	if got, err := run("safeDivide", interpreter.Int(1), interpreter.Int(0)); err != nil || got != interpreter.Int(-1) {
+		t.Errorf("safeDivide(1, 0) = %v, %v", got, err)
+	}
+	if got, err := run("safeDivide", interpreter.Int(6), interpreter.Int(2)); err != nil || got != interpreter.Int(3) {
+		t.Errorf("safeDivide(6, 2) = %v, %v", got, err)
+	}
+
+	var e *interpreter.Exception
+	if _, err := run("outside"); !errors.As(err, &e) || e.Class != "java/lang/ArithmeticException" {
+		t.Errorf("outside(): %v, want it uncaught", err)
+	}
+
+	// the trace is of where it was first thrown, not rethrown
+	_, err := run("rethrow")
+	if !errors.As(err, &e) {
+		t.Fatalf("rethrow(): %v", err)
+	}
+	want := "java.lang.ArithmeticException: / by zero\n" +
+		"\tat jpamb.cases.Exceptions.fail(Exceptions.java:4)\n" +
+		"\tat jpamb.cases.Exceptions.rethrow(Exceptions.java:20)\n"
+	if got := e.StackTrace(); got != want {
+		t.Errorf("rethrow(): got\n%swant\n%s", got, want)
+	}
+}
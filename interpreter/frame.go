@@ -0,0 +1,196 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// Method is a method with its code resolved, ready to be run.
+type Method struct {
+	Class     *data.Class
+	ClassName string // internal name of Class
+	Source    string // file of the SourceFile attribute, if any
+	Info      data.MemberInfo
+	Code      *data.AttributeCode
+	Ops       []data.Op
+	pcs       []int       // pc of each op, and one past the last
+	index     map[int]int // op at each pc
+	lines     []data.LineNumber
+}
+
+// NewMethod resolves the code of info, a method of class.
+func NewMethod(class *data.Class, info data.MemberInfo, resolve data.Resolver) (*Method, error) {
+	h, ok := info.Attributes[data.ATTR_CODE]
+	if !ok || info.Unparsable {
+		return nil, fmt.Errorf("method %s%s has no code", info.Name.Value, info.Descriptor.Value)
+	}
+
+	attr, err := resolve(h)
+	if err != nil {
+		return nil, err
+	}
+	code := attr.AttributeCode()
+
+	bc, err := resolve(&code.CodeHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Method{
+		Class: class,
+		Info:  info,
+		Code:  code,
+		Ops:   bc.Bytecode().Ops,
+		pcs:   make([]int, len(bc.Bytecode().Ops)+1),
+		index: make(map[int]int),
+	}
+
+	for i, op := range m.Ops {
+		m.index[m.pcs[i]] = i
+		m.pcs[i+1] = m.pcs[i] + 1 + len(op.Arg)
+	}
+
+	if class.ThisClass != nil {
+		if m.ClassName, err = utf8(class.ThisClass.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if h, ok := class.Attributes[data.ATTR_SOURCE_FILE]; ok {
+		attr, err := resolve(h)
+		if err != nil {
+			return nil, err
+		}
+		m.Source = attr.AttributeSourceFile().SourceFile.Value
+	}
+
+	for _, h := range code.Attributes {
+		if h.AttributeTag != data.ATTR_LINE_NUMBER_TABLE {
+			continue
+		}
+		attr, err := resolve(&h)
+		if err != nil {
+			return nil, err
+		}
+		m.lines = append(m.lines, attr.AttributeLineNumberTable().LineNumbers...)
+	}
+
+	return m, nil
+}
+
+// Line is the source line of the instruction at pc, or -1 if unknown.
+func (m *Method) Line(pc int) int {
+	line, start := -1, -1
+	for _, ln := range m.lines {
+		if int(ln.StartPC) <= pc && int(ln.StartPC) > start {
+			line, start = int(ln.LineNumber), int(ln.StartPC)
+		}
+	}
+	return line
+}
+
+func (m *Method) String() string {
+	return m.Info.Name.Value + m.Info.Descriptor.Value
+}
+
+// Static reports whether the method has no this.
+func (m *Method) Static() bool { return m.Info.AccessFlags&0x0008 != 0 }
+
+// Frame is the state of a method being run.
+type Frame struct {
+	Method *Method
+	Locals []Value // MaxLocals slots
+	Stack  []Value // at most MaxStack values, the top last
+	PC     int
+
+	initialiser bool // runs <clinit> on behalf of the frame below
+}
+
+func newFrame(m *Method) *Frame {
+	return &Frame{
+		Method: m,
+		Locals: make([]Value, m.Code.MaxLocals),
+		Stack:  make([]Value, 0, m.Code.MaxStack),
+	}
+}
+
+// PC is the pc of instruction i of m, or one past the last for len(m.Ops).
+func (m *Method) PC(i int) int {
+	return m.pcs[i]
+}
+
+// At is the index in Ops of the instruction at pc, if there is one.
+func (m *Method) At(pc int) (int, bool) {
+	i, ok := m.index[pc]
+	return i, ok
+}
+
+// Op is the instruction at the pc.
+func (f *Frame) Op() (data.Op, error) {
+	i, ok := f.Method.At(f.PC)
+	if !ok {
+		return data.Op{}, fmt.Errorf("no instruction at %d", f.PC)
+	}
+	return f.Method.Ops[i], nil
+}
+
+// next is the pc of the instruction following the current one.
+func (f *Frame) next() int {
+	return f.Method.pcs[f.Method.index[f.PC]+1]
+}
+
+// advance the pc to the next instruction.
+func (f *Frame) advance() error {
+	if f.PC = f.next(); f.PC == f.Method.pcs[len(f.Method.Ops)] {
+		return fmt.Errorf("fell off the end of the code of %s", f.Method)
+	}
+	return nil
+}
+
+func (f *Frame) push(v Value) error {
+	if len(f.Stack) == cap(f.Stack) {
+		return fmt.Errorf("operand stack overflow, max_stack is %d", cap(f.Stack))
+	}
+	f.Stack = append(f.Stack, v)
+	return nil
+}
+
+func (f *Frame) pop() (Value, error) {
+	if len(f.Stack) == 0 {
+		return Value{}, fmt.Errorf("operand stack underflow")
+	}
+	v := f.Stack[len(f.Stack)-1]
+	f.Stack = f.Stack[:len(f.Stack)-1]
+	return v, nil
+}
+
+func (f *Frame) popKind(k Kind) (Value, error) {
+	v, err := f.pop()
+	if err == nil && v.Kind != k {
+		err = fmt.Errorf("expected %s on the stack, got %s", k, v.Kind)
+	}
+	return v, err
+}
+
+func (f *Frame) load(i int, k Kind) error {
+	if i >= len(f.Locals) {
+		return fmt.Errorf("local %d out of range, max_locals is %d", i, len(f.Locals))
+	}
+	if f.Locals[i].Kind != k {
+		return fmt.Errorf("expected %s in local %d, got %s", k, i, f.Locals[i].Kind)
+	}
+	return f.push(f.Locals[i])
+}
+
+func (f *Frame) store(i int, k Kind) error {
+	if i >= len(f.Locals) {
+		return fmt.Errorf("local %d out of range, max_locals is %d", i, len(f.Locals))
+	}
+	v, err := f.popKind(k)
+	if err != nil {
+		return err
+	}
+	f.Locals[i] = v
+	return nil
+}
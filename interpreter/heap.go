@@ -0,0 +1,141 @@
+package interpreter
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Array of ints, references, or booleans, bytes, chars or shorts held as ints.
+// Elem is the field descriptor of the elements, e.g. I, C or Ljava/lang/String;
+// and references are held by their address.
+type Array struct {
+	Elem  string
+	Elems []int32
+}
+
+// Get is element i, which must be in bounds.
+func (a *Array) Get(i int32) Value {
+	if a.Elem[0] == 'L' || a.Elem[0] == '[' {
+		return Value{Kind: REFERENCE, V: a.Elems[i]}
+	}
+	return Int(a.Elems[i])
+}
+
+func (a *Array) String() string {
+	elems := make([]string, len(a.Elems))
+	for i := range a.Elems {
+		switch v := a.Get(int32(i)); {
+		case a.Elem == "C":
+			elems[i] = strconv.QuoteRune(rune(uint16(v.V)))
+		case a.Elem == "Z":
+			elems[i] = strconv.FormatBool(v.V != 0)
+		default:
+			elems[i] = v.String()
+		}
+	}
+	return "[" + a.Elem + ":" + strings.Join(elems, ",") + "]"
+}
+
+// Object is an instance of Class, an internal name, with a value for each of
+// its fields and those it inherits, keyed by the class declaring them.
+// Instances of the classes modelled by Natives keep their state in Native,
+// e.g. the chars of a java/lang/String.
+type Object struct {
+	Class  string
+	Fields map[Ref]Value
+	Native any
+}
+
+func (o *Object) String() string {
+	switch n := o.Native.(type) {
+	case []uint16:
+		return o.Class + "(" + strconv.Quote(string(utf16.Decode(n))) + ")"
+	case nil:
+	default:
+		return fmt.Sprintf("%s(%v)", o.Class, n)
+	}
+
+	if len(o.Fields) == 0 {
+		return o.Class
+	}
+
+	fields := slices.SortedFunc(maps.Keys(o.Fields), func(a, b Ref) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	var str strings.Builder
+	str.WriteString(o.Class + "{")
+	for i, f := range fields {
+		if i > 0 {
+			str.WriteString(", ")
+		}
+		fmt.Fprintf(&str, "%s=%s", f.Name, o.Fields[f])
+	}
+	str.WriteString("}")
+	return str.String()
+}
+
+// Heap holds what references point to. Addresses are handed out in
+// allocation order from 1, so runs are reproducible.
+type Heap struct {
+	cells []any
+	elems int // of the arrays allocated
+	max   int // elements the arrays may hold in all, unbounded if 0
+}
+
+func (h *Heap) alloc(cell any) Value {
+	h.cells = append(h.cells, cell)
+	return Value{Kind: REFERENCE, V: int32(len(h.cells))}
+}
+
+// NewArray allocates an array of n elements of the field descriptor elem, set
+// to 0 or null, throwing java/lang/OutOfMemoryError if the arrays would hold
+// more elements than the heap allows.
+func (h *Heap) NewArray(elem string, n int32) (Value, error) {
+	if h.max > 0 && int(n) > h.max-h.elems {
+		return Value{}, Throw("java/lang/OutOfMemoryError", "Java heap space: %d more elements than the %d allowed", h.elems+int(n)-h.max, h.max)
+	}
+	h.elems += int(n)
+	return h.alloc(&Array{Elem: elem, Elems: make([]int32, n)}), nil
+}
+
+// NewObject allocates an instance of class with fields, set to 0 or null.
+func (h *Heap) NewObject(class string, fields ...Ref) Value {
+	o := &Object{Class: class, Fields: make(map[Ref]Value, len(fields))}
+	for _, f := range fields {
+		o.Fields[f] = zero(f.Descriptor)
+	}
+	return h.alloc(o)
+}
+
+// Get is what ref points to, or nil for null.
+func (h *Heap) Get(ref Value) (any, error) {
+	if ref.Kind != REFERENCE {
+		return nil, fmt.Errorf("expected a reference, got %s", ref.Kind)
+	}
+
+	if ref.V == 0 {
+		return nil, nil
+	}
+
+	if ref.V < 0 || int(ref.V) > len(h.cells) {
+		return nil, fmt.Errorf("dangling reference %s", ref)
+	}
+
+	return h.cells[ref.V-1], nil
+}
+
+// Len is the number of cells allocated.
+func (h *Heap) Len() int { return len(h.cells) }
+
+// zero is the default value of the field descriptor desc.
+func zero(desc string) Value {
+	if desc[0] == 'L' || desc[0] == '[' {
+		return Null
+	}
+	return Int(0)
+}
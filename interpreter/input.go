@@ -0,0 +1,260 @@
+package interpreter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// Input is an argument in the syntax of jpamb: an int, a boolean, a char, an
+// array such as [I:1,2,3] or [C:'a','b'], or null.
+type Input struct {
+	Type  string  // field descriptor, e.g. I, Z, C or [I; empty for null
+	Elems []int32 // the value, or the elements of an array
+}
+
+func (i Input) String() string {
+	switch {
+	case i.Type == "":
+		return "null"
+	case i.Type[0] == '[':
+		elems := make([]string, len(i.Elems))
+		for j, e := range i.Elems {
+			elems[j] = Input{Type: i.Type[1:], Elems: []int32{e}}.String()
+		}
+		return i.Type + ":" + strings.Join(elems, ",") + "]"
+	case i.Type == "Z":
+		return strconv.FormatBool(i.Elems[0] != 0)
+	case i.Type == "C":
+		return strconv.QuoteRune(rune(i.Elems[0]))
+	default:
+		return strconv.Itoa(int(i.Elems[0]))
+	}
+}
+
+// ParseInputs parses the arguments of a jpamb case, e.g. (1, [C:'a'], true).
+func ParseInputs(s string) ([]Input, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return nil, fmt.Errorf("inputs %q must be in parentheses", s)
+	}
+
+	p := &inputParser{s: s[1 : len(s)-1]}
+	var inputs []Input
+	for p.skipSpace(); !p.done(); {
+		if len(inputs) > 0 {
+			if err := p.expect(','); err != nil {
+				return nil, fmt.Errorf("inputs %q: %w", s, err)
+			}
+		}
+
+		in, err := p.input()
+		if err != nil {
+			return nil, fmt.Errorf("inputs %q: %w", s, err)
+		}
+		inputs = append(inputs, in)
+	}
+
+	return inputs, nil
+}
+
+type inputParser struct {
+	s   string
+	pos int
+}
+
+func (p *inputParser) done() bool { return p.pos == len(p.s) }
+
+func (p *inputParser) skipSpace() {
+	for !p.done() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *inputParser) expect(c byte) error {
+	p.skipSpace()
+	if p.done() || p.s[p.pos] != c {
+		return fmt.Errorf("expected %q at %d", c, p.pos)
+	}
+	p.pos++
+	p.skipSpace()
+	return nil
+}
+
+func (p *inputParser) input() (Input, error) {
+	if strings.HasPrefix(p.s[p.pos:], "[") {
+		return p.array()
+	}
+
+	v, typ, err := p.scalar()
+	return Input{Type: typ, Elems: []int32{v}}, err
+}
+
+// scalar is an int, boolean or char, or null with an empty type.
+func (p *inputParser) scalar() (int32, string, error) {
+	rest := p.s[p.pos:]
+	for _, word := range []string{"true", "false", "null"} {
+		if strings.HasPrefix(rest, word) {
+			p.pos += len(word)
+			p.skipSpace()
+			switch word {
+			case "true":
+				return 1, "Z", nil
+			case "false":
+				return 0, "Z", nil
+			default:
+				return 0, "", nil
+			}
+		}
+	}
+
+	if strings.HasPrefix(rest, "'") {
+		r, _, tail, err := strconv.UnquoteChar(rest[1:], '\'')
+		if err != nil || !strings.HasPrefix(tail, "'") || r > 0xFFFF {
+			return 0, "", fmt.Errorf("invalid char at %d", p.pos)
+		}
+		p.pos += len(rest) - len(tail) + 1
+		p.skipSpace()
+		return int32(r), "C", nil
+	}
+
+	end := 0
+	for end < len(rest) && (rest[end] == '-' || rest[end] >= '0' && rest[end] <= '9') {
+		end++
+	}
+	v, err := strconv.ParseInt(rest[:end], 10, 32)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid value at %d", p.pos)
+	}
+	p.pos += end
+	p.skipSpace()
+	return int32(v), "I", nil
+}
+
+func (p *inputParser) array() (Input, error) {
+	p.pos++
+	if p.done() || !strings.ContainsRune("IZCBS", rune(p.s[p.pos])) {
+		return Input{}, fmt.Errorf("invalid array type at %d", p.pos)
+	}
+	in := Input{Type: "[" + string(p.s[p.pos]), Elems: []int32{}}
+	p.pos++
+
+	if err := p.expect(':'); err != nil {
+		return Input{}, err
+	}
+
+	for !strings.HasPrefix(p.s[p.pos:], "]") {
+		if len(in.Elems) > 0 {
+			if err := p.expect(','); err != nil {
+				return Input{}, err
+			}
+		}
+
+		at := p.pos
+		v, typ, err := p.scalar()
+		if err != nil {
+			return Input{}, err
+		}
+		if !assignable(in.Type[1:], typ, v) {
+			return Input{}, fmt.Errorf("%s element at %d in an array of %s", typeName(typ), at, data.JavaType(in.Type[1:]))
+		}
+		in.Elems = append(in.Elems, v)
+	}
+
+	p.pos++
+	p.skipSpace()
+	return in, nil
+}
+
+// assignable reports whether a value v of type typ, as parsed, can be of the
+// field descriptor desc.
+func assignable(desc, typ string, v int32) bool {
+	switch {
+	case typ == "":
+		return desc[0] == 'L' || desc[0] == '['
+	case typ != "I":
+		return desc == typ
+	case desc == "B":
+		return v == int32(int8(v))
+	case desc == "S":
+		return v == int32(int16(v))
+	default:
+		return desc == "I"
+	}
+}
+
+func typeName(typ string) string {
+	if typ == "" {
+		return "null"
+	}
+	return data.JavaType(typ)
+}
+
+// Args checks inputs against the parameters of m, which must be static, and
+// allocates their arrays, to Start m with.
+func (in *Interpreter) Args(m *Method, inputs []Input) ([]Value, error) {
+	if !m.Static() {
+		return nil, fmt.Errorf("%s is not static", m)
+	}
+
+	params, _, err := data.SplitMethodDescriptor(m.Info.Descriptor.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(inputs) != len(params) {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", m, len(params), len(inputs))
+	}
+
+	args := make([]Value, len(inputs))
+	for i, input := range inputs {
+		p := params[i]
+		switch {
+		case input.Type == "":
+			if !assignable(p, "", 0) {
+				return nil, fmt.Errorf("argument %d of %s: expected %s, got null", i, m, data.JavaType(p))
+			}
+			args[i] = Null
+		case input.Type[0] == '[':
+			if p != input.Type {
+				return nil, fmt.Errorf("argument %d of %s: expected %s, got %s", i, m, data.JavaType(p), data.JavaType(input.Type))
+			}
+			if args[i], err = in.Heap.NewArray(input.Type[1:], int32(len(input.Elems))); err != nil {
+				return nil, err
+			}
+			cell, _ := in.Heap.Get(args[i])
+			copy(cell.(*Array).Elems, input.Elems)
+		default:
+			if !assignable(p, input.Type, input.Elems[0]) {
+				return nil, fmt.Errorf("argument %d of %s: expected %s, got %s", i, m, data.JavaType(p), input)
+			}
+			args[i] = Int(input.Elems[0])
+		}
+	}
+
+	return args, nil
+}
+
+// Format v, a value of the field descriptor desc, in the syntax of inputs, and
+// Strings quoted.
+func (in *Interpreter) Format(v Value, desc string) string {
+	switch {
+	case v.Kind == INT && strings.Contains("ZCBSI", desc):
+		return Input{Type: desc, Elems: []int32{v.V}}.String()
+	case v.Kind == REFERENCE && !v.IsNull():
+		if cell, err := in.Heap.Get(v); err == nil {
+			switch cell := cell.(type) {
+			case *Array:
+				return cell.String()
+			case *Object:
+				if s, ok := cell.Native.([]uint16); ok {
+					return strconv.Quote(string(utf16.Decode(s)))
+				}
+			}
+		}
+	}
+	return v.String()
+}
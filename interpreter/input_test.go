@@ -0,0 +1,89 @@
+package interpreter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/internal/testutil"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+func TestParseInputs(t *testing.T) {
+	for in, want := range map[string]string{
+		"()":                            "",
+		"(0)":                           "0",
+		"(-12, true, false)":            "-12 true false",
+		"( 'a' , ',', '\\'' )":          "'a' ',' '\\''",
+		"([I:1,2,3], [C:'a','b'])":      "[I:1,2,3] [C:'a','b']",
+		"([I:], [Z:true, false], null)": "[I:] [Z:true,false] null",
+	} {
+		inputs, err := interpreter.ParseInputs(in)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+
+		var got []string
+		for _, i := range inputs {
+			got = append(got, i.String())
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s: got %v, want %s", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"1", "(1,)", "(,)", "(1 2)", "('ab')", "([I:'a'])", "([X:1])", "([I:1)", "(2147483648)", "(tru)"} {
+		if _, err := interpreter.ParseInputs(in); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	_, classes := testutil.Classpath(t, source)
+	c := classes[0]
+
+	args := func(selector, inputs string) error {
+		sel, err := data.ParseSelector(selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := c.Method(sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := interpreter.ParseInputs(inputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		in := interpreter.New()
+		args, err := in.Args(m, values)
+		if err != nil {
+			return err
+		}
+		return in.Start(m, args)
+	}
+
+	for selector, inputs := range map[string]string{
+		"sum":    "(3)",
+		"at":     "([C:'a'], 0)",
+		"length": "(null)",
+	} {
+		if err := args(selector, inputs); err != nil {
+			t.Errorf("%s%s: %v", selector, inputs, err)
+		}
+	}
+
+	for selector, inputs := range map[string]string{
+		"sum":    "(true)",
+		"at":     "([I:1], 0)",
+		"divide": "(1)",
+		"length": "('a')",
+	} {
+		if err := args(selector, inputs); err == nil {
+			t.Errorf("%s%s: expected an error", selector, inputs)
+		}
+	}
+}
@@ -0,0 +1,758 @@
+// Package interpreter runs the bytecode of methods concretely, as a reference
+// for what the analyses are meant to approximate.
+//
+// Values are ints and references; all ints of the class file format
+// (booleans, bytes, chars, shorts and ints) are held as int32, and references
+// are addresses on a Heap that is deterministic across runs. An instruction
+// the JVM would reject, such as popping an empty stack, is an error, while an
+// exception the JVM would throw, such as dividing by zero, goes to the handlers
+// in the exception tables of the methods running, and is an *Exception when
+// none catches it. Methods of the JDK have no bytecode to run, and are run by
+// the Natives modelling them instead.
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// ErrBudget is returned by Run when the method takes more steps than allowed.
+var ErrBudget = errors.New("step budget exhausted")
+
+// DefaultMaxDepth is how many frames the call stack holds unless WithMaxDepth
+// says otherwise.
+const DefaultMaxDepth = 1024
+
+// DefaultMaxHeap is how many elements the arrays on the heap hold in all
+// unless WithMaxHeap says otherwise.
+const DefaultMaxHeap = 1 << 24
+
+// Interpreter runs a method one instruction at a time.
+type Interpreter struct {
+	Heap   *Heap
+	Frames []*Frame // the call stack, the running method last
+	Steps  int      // instructions run
+	Result Value    // returned by the method, once Done
+	Done   bool
+
+	Statics   map[Ref]Value         // static fields, keyed by the class declaring them
+	states    map[string]classState // of initialisation, by class
+	classes   map[string]Value      // instances of java/lang/Class
+	thrown    map[int32]*Exception  // by the address of the throwable
+	strings   map[string]Value      // String constants, interned
+	observers []Observer
+
+	ctx       context.Context
+	classpath *Classpath
+	natives   Natives
+	maxDepth  int
+}
+
+// Option configures an Interpreter.
+type Option func(*Interpreter)
+
+// WithClasspath loads the classes of the methods invoked from cp.
+func WithClasspath(cp *Classpath) Option {
+	return func(in *Interpreter) {
+		in.classpath = cp
+	}
+}
+
+// WithNatives runs the methods modelled by n in Go instead of their bytecode,
+// in place of DefaultNatives.
+func WithNatives(n Natives) Option {
+	return func(in *Interpreter) {
+		in.natives = n
+	}
+}
+
+// WithObserver notifies o of each instruction run, after any other observers.
+func WithObserver(o Observer) Option {
+	return func(in *Interpreter) {
+		in.observers = append(in.observers, o)
+	}
+}
+
+// WithMaxDepth bounds the call stack to n frames, beyond which invoking a
+// method throws java/lang/StackOverflowError.
+func WithMaxDepth(n int) Option {
+	return func(in *Interpreter) {
+		in.maxDepth = n
+	}
+}
+
+// WithMaxHeap bounds the elements of all the arrays allocated to n, beyond
+// which allocating one throws java/lang/OutOfMemoryError.
+func WithMaxHeap(n int) Option {
+	return func(in *Interpreter) {
+		in.Heap.max = n
+	}
+}
+
+// WithContext stops Run when ctx is done, and loads classes with it.
+func WithContext(ctx context.Context) Option {
+	return func(in *Interpreter) {
+		in.ctx = ctx
+	}
+}
+
+func New(opts ...Option) *Interpreter {
+	in := &Interpreter{
+		Heap:      &Heap{max: DefaultMaxHeap},
+		Statics:   make(map[Ref]Value),
+		states:    make(map[string]classState),
+		classes:   make(map[string]Value),
+		thrown:    make(map[int32]*Exception),
+		strings:   make(map[string]Value),
+		ctx:       context.Background(),
+		classpath: NewClasspath(nil),
+		natives:   DefaultNatives(),
+		maxDepth:  DefaultMaxDepth,
+	}
+	for _, opt := range opts {
+		opt(in)
+	}
+	return in
+}
+
+// Frame is the frame of the running method.
+func (in *Interpreter) Frame() *Frame {
+	return in.Frames[len(in.Frames)-1]
+}
+
+// Start prepares to run m with args, which must match its descriptor, and
+// include this first if it is not static.
+func (in *Interpreter) Start(m *Method, args []Value) error {
+	f, err := in.frame(m, args)
+	if err != nil {
+		return err
+	}
+
+	in.Frames, in.Steps, in.Result, in.Done = []*Frame{f}, 0, Value{}, false
+	return nil
+}
+
+// frame is a new frame to run m with args in.
+func (in *Interpreter) frame(m *Method, args []Value) (*Frame, error) {
+	params, _, err := data.SplitMethodDescriptor(m.Info.Descriptor.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.Static() {
+		params = append([]string{"Ljava/lang/Object;"}, params...)
+	}
+
+	if len(args) != len(params) {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", m, len(params), len(args))
+	}
+
+	f := newFrame(m)
+	slot := 0
+	for i, p := range params {
+		if err := in.check(p, args[i]); err != nil {
+			return nil, fmt.Errorf("argument %d of %s: %w", i, m, err)
+		}
+
+		if slot >= len(f.Locals) {
+			return nil, fmt.Errorf("arguments of %s do not fit in %d locals", m, len(f.Locals))
+		}
+		f.Locals[slot] = args[i]
+		slot += data.Slots(p)
+	}
+
+	return f, nil
+}
+
+// check that v can be a value of the field descriptor desc.
+func (in *Interpreter) check(desc string, v Value) error {
+	switch desc[0] {
+	case 'Z', 'B', 'C', 'S', 'I':
+		if v.Kind != INT {
+			return fmt.Errorf("expected %s, got %s", data.JavaType(desc), v.Kind)
+		}
+	case 'L', '[':
+		if v.Kind != REFERENCE {
+			return fmt.Errorf("expected %s, got %s", data.JavaType(desc), v.Kind)
+		}
+
+		cell, err := in.Heap.Get(v)
+		if err != nil {
+			return err
+		}
+
+		if a, ok := cell.(*Array); ok && desc != "["+a.Elem && desc != "Ljava/lang/Object;" {
+			return fmt.Errorf("expected %s, got %s[]", data.JavaType(desc), data.JavaType(a.Elem))
+		}
+	default:
+		return fmt.Errorf("%s values are not supported", data.JavaType(desc))
+	}
+
+	return nil
+}
+
+// Run steps until the method returns, fails, or has run maxSteps
+// instructions in total, when it returns ErrBudget.
+func (in *Interpreter) Run(maxSteps int) error {
+	for !in.Done {
+		if in.Steps >= maxSteps {
+			return ErrBudget
+		}
+
+		if in.Steps%1024 == 0 {
+			if err := in.ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		if err := in.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Step runs the instruction at the pc.
+func (in *Interpreter) Step() error {
+	if in.Done {
+		return fmt.Errorf("method already returned")
+	}
+
+	f := in.Frame()
+	op, err := f.Op()
+	if err != nil {
+		return err
+	}
+
+	in.Steps++
+
+	for _, o := range in.observers {
+		o.Before(in, f, op)
+	}
+	err = in.step(f, op)
+	for _, o := range in.observers {
+		o.After(in, f, op, err)
+	}
+	return err
+}
+
+// step runs op, the instruction at the pc of f, and moves the pc on.
+func (in *Interpreter) step(f *Frame, op data.Op) error {
+	jump, err := in.execute(f, op)
+	if err != nil {
+		var e *Exception
+		if errors.As(err, &e) {
+			e.PC = f.PC
+			return in.raise(e)
+		}
+		return fmt.Errorf("%s at %d: %w", op.Code, f.PC, err)
+	}
+
+	switch {
+	case in.Done:
+	case in.Frame() != f:
+		// invoked a method, or returned to the caller, which then moved on
+	case jump != nil:
+		if _, ok := f.Method.At(*jump); !ok {
+			return fmt.Errorf("%s at %d: jump to %d, which is not an instruction", op.Code, f.PC, *jump)
+		}
+		f.PC = *jump
+	default:
+		return f.advance()
+	}
+
+	return nil
+}
+
+// Throw is the exception the current instruction, or a Native, throws, with a
+// message formatted from format and args.
+func Throw(class, format string, args ...any) error {
+	return &Exception{Class: class, Message: fmt.Sprintf(format, args...)}
+}
+
+// execute op in f, and return the pc to jump to if it branches.
+func (in *Interpreter) execute(f *Frame, op data.Op) (*int, error) {
+	if n, err := op.Code.NArgs(); err != nil || n != len(op.Arg) {
+		return nil, fmt.Errorf("malformed instruction")
+	}
+
+	switch op.Code {
+	case data.OP_NOP:
+		return nil, nil
+
+	case data.OP_ACONST_NULL:
+		return nil, f.push(Null)
+
+	case data.OP_ICONST_0, data.OP_ICONST_1, data.OP_ICONST_2, data.OP_ICONST_3, data.OP_ICONST_4, data.OP_ICONST_5:
+		return nil, f.push(Int(int32(op.Code - data.OP_ICONST_0)))
+
+	case data.OP_BIPUSH:
+		return nil, f.push(Int(int32(int8(op.Arg[0]))))
+
+	case data.OP_SIPUSH:
+		return nil, f.push(Int(int32(int16(uint16(op.Arg[0])<<8 | uint16(op.Arg[1])))))
+
+	case data.OP_LDC:
+		return nil, in.ldc(f, op)
+
+	case data.OP_ILOAD:
+		return nil, f.load(int(op.Arg[0]), INT)
+	case data.OP_ILOAD_0, data.OP_ILOAD_1, data.OP_ILOAD_2, data.OP_ILOAD_3:
+		return nil, f.load(int(op.Code-data.OP_ILOAD_0), INT)
+	case data.OP_ALOAD:
+		return nil, f.load(int(op.Arg[0]), REFERENCE)
+	case data.OP_ALOAD_0, data.OP_ALOAD_1, data.OP_ALOAD_2, data.OP_ALOAD_3:
+		return nil, f.load(int(op.Code-data.OP_ALOAD_0), REFERENCE)
+
+	case data.OP_ISTORE:
+		return nil, f.store(int(op.Arg[0]), INT)
+	case data.OP_ISTORE_0, data.OP_ISTORE_1, data.OP_ISTORE_2, data.OP_ISTORE_3:
+		return nil, f.store(int(op.Code-data.OP_ISTORE_0), INT)
+	case data.OP_ASTORE:
+		return nil, f.store(int(op.Arg[0]), REFERENCE)
+	case data.OP_ASTORE_0, data.OP_ASTORE_1, data.OP_ASTORE_2, data.OP_ASTORE_3:
+		return nil, f.store(int(op.Code-data.OP_ASTORE_0), REFERENCE)
+
+	case data.OP_NEWARRAY:
+		return nil, in.newarray(f, op)
+	case data.OP_ANEWARRAY:
+		return nil, in.anewarray(f, op)
+	case data.OP_ARRAYLENGTH:
+		return nil, in.arraylength(f)
+	case data.OP_IALOAD, data.OP_AALOAD, data.OP_BALOAD, data.OP_CALOAD, data.OP_SALOAD:
+		return nil, in.arrayLoad(f, op.Code)
+	case data.OP_IASTORE, data.OP_AASTORE, data.OP_BASTORE, data.OP_CASTORE, data.OP_SASTORE:
+		return nil, in.arrayStore(f, op.Code)
+
+	case data.OP_DUP:
+		v, err := f.pop()
+		if err != nil {
+			return nil, err
+		}
+		if err := f.push(v); err != nil {
+			return nil, err
+		}
+		return nil, f.push(v)
+
+	case data.OP_IADD, data.OP_ISUB, data.OP_IMUL, data.OP_IDIV, data.OP_IREM:
+		return nil, arithmetic(f, op.Code)
+
+	case data.OP_IINC:
+		i := int(op.Arg[0])
+		if i >= len(f.Locals) || f.Locals[i].Kind != INT {
+			return nil, fmt.Errorf("expected int in local %d", i)
+		}
+		f.Locals[i].V += int32(int8(op.Arg[1]))
+		return nil, nil
+
+	case data.OP_I2S:
+		v, err := f.popKind(INT)
+		if err != nil {
+			return nil, err
+		}
+		return nil, f.push(Int(int32(int16(v.V))))
+
+	case data.OP_IFEQ, data.OP_IFNE, data.OP_IFGE, data.OP_IFGT:
+		v, err := f.popKind(INT)
+		if err != nil {
+			return nil, err
+		}
+		return branch(f, op, compare(op.Code, v.V, 0))
+
+	case data.OP_IF_ICMPEQ, data.OP_IF_ICMPNE, data.OP_IF_ICMPLT, data.OP_IF_ICMPGE, data.OP_IF_ICMPGT, data.OP_IF_ICMPLE:
+		b, err := f.popKind(INT)
+		if err != nil {
+			return nil, err
+		}
+		a, err := f.popKind(INT)
+		if err != nil {
+			return nil, err
+		}
+		return branch(f, op, compare(op.Code, a.V, b.V))
+
+	case data.OP_IFNULL, data.OP_IFNONNULL:
+		v, err := f.popKind(REFERENCE)
+		if err != nil {
+			return nil, err
+		}
+		return branch(f, op, v.IsNull() == (op.Code == data.OP_IFNULL))
+
+	case data.OP_IF_ACMPEQ, data.OP_IF_ACMPNE:
+		b, err := f.popKind(REFERENCE)
+		if err != nil {
+			return nil, err
+		}
+		a, err := f.popKind(REFERENCE)
+		if err != nil {
+			return nil, err
+		}
+		return branch(f, op, (a == b) == (op.Code == data.OP_IF_ACMPEQ))
+
+	case data.OP_GOTO:
+		return branch(f, op, true)
+
+	case data.OP_IRETURN, data.OP_ARETURN, data.OP_RETURN:
+		return nil, in.ret(f, op.Code)
+
+	case data.OP_GETSTATIC, data.OP_PUTSTATIC:
+		return nil, in.static(f, op)
+	case data.OP_GETFIELD, data.OP_PUTFIELD:
+		return nil, in.field(f, op)
+	case data.OP_NEW:
+		return nil, in.new(f, op)
+	case data.OP_INVOKESTATIC, data.OP_INVOKESPECIAL, data.OP_INVOKEVIRTUAL:
+		return nil, in.invoke(f, op)
+	case data.OP_ATHROW:
+		return nil, in.athrow(f)
+
+	default:
+		return nil, fmt.Errorf("not supported by the interpreter")
+	}
+}
+
+func (in *Interpreter) ldc(f *Frame, op data.Op) error {
+	i, _ := op.ConstantIndex()
+	pool := f.Method.Class.ConstantPool
+	if i == 0 || int(i) > len(pool) || pool[i-1] == nil {
+		return fmt.Errorf("constant index %d out of range", i)
+	}
+
+	switch c := pool[i-1]; c.Tag() {
+	case data.CP_INTEGER:
+		return f.push(Int(c.ConstantInteger().Value))
+	case data.CP_STRING:
+		s, err := utf8(c.ConstantString().Value)
+		if err != nil {
+			return err
+		}
+		return f.push(in.intern(s))
+	case data.CP_CLASS:
+		name, err := utf8(c.ConstantClass().Name)
+		if err != nil {
+			return err
+		}
+		return f.push(in.classObject(name))
+	default:
+		return fmt.Errorf("%s constants are not supported", c.Tag())
+	}
+}
+
+func (in *Interpreter) newarray(f *Frame, op data.Op) error {
+	var elem string
+	switch op.Arg[0] {
+	case 4:
+		elem = "Z"
+	case 5:
+		elem = "C"
+	case 8:
+		elem = "B"
+	case 9:
+		elem = "S"
+	case 10:
+		elem = "I"
+	default:
+		return fmt.Errorf("arrays of type %d are not supported", op.Arg[0])
+	}
+
+	n, err := f.popKind(INT)
+	if err != nil {
+		return err
+	}
+
+	if n.V < 0 {
+		return Throw("java/lang/NegativeArraySizeException", "%d", n.V)
+	}
+
+	a, err := in.Heap.NewArray(elem, n.V)
+	if err != nil {
+		return err
+	}
+	return f.push(a)
+}
+
+func (in *Interpreter) anewarray(f *Frame, op data.Op) error {
+	i, _ := op.ConstantIndex()
+	c, err := constant(f.Method.Class, i, data.CP_CLASS)
+	if err != nil {
+		return err
+	}
+
+	name, err := utf8(c.ConstantClass().Name)
+	if err != nil {
+		return err
+	}
+
+	elem := name
+	if name[0] != '[' {
+		elem = "L" + name + ";"
+	}
+
+	n, err := f.popKind(INT)
+	if err != nil {
+		return err
+	}
+
+	if n.V < 0 {
+		return Throw("java/lang/NegativeArraySizeException", "%d", n.V)
+	}
+
+	a, err := in.Heap.NewArray(elem, n.V)
+	if err != nil {
+		return err
+	}
+	return f.push(a)
+}
+
+// array pops a reference to an array.
+func (in *Interpreter) array(f *Frame) (*Array, error) {
+	ref, err := f.popKind(REFERENCE)
+	if err != nil {
+		return nil, err
+	}
+
+	cell, err := in.Heap.Get(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if cell == nil {
+		return nil, Throw("java/lang/NullPointerException", "")
+	}
+
+	a, ok := cell.(*Array)
+	if !ok {
+		return nil, fmt.Errorf("expected an array, got %T", cell)
+	}
+
+	return a, nil
+}
+
+func (in *Interpreter) arraylength(f *Frame) error {
+	a, err := in.array(f)
+	if err != nil {
+		return err
+	}
+	return f.push(Int(int32(len(a.Elems))))
+}
+
+// index pops an index and the array it is into, which must hold elements
+// that code loads or stores.
+func (in *Interpreter) index(f *Frame, code data.OpCode) (*Array, int32, error) {
+	i, err := f.popKind(INT)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	a, err := in.array(f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var ok bool
+	switch code {
+	case data.OP_IALOAD, data.OP_IASTORE:
+		ok = a.Elem == "I"
+	case data.OP_BALOAD, data.OP_BASTORE:
+		ok = a.Elem == "B" || a.Elem == "Z"
+	case data.OP_CALOAD, data.OP_CASTORE:
+		ok = a.Elem == "C"
+	case data.OP_SALOAD, data.OP_SASTORE:
+		ok = a.Elem == "S"
+	case data.OP_AALOAD, data.OP_AASTORE:
+		ok = a.Elem[0] == 'L' || a.Elem[0] == '['
+	}
+	if !ok {
+		return nil, 0, fmt.Errorf("%s on an array of %s", code, data.JavaType(a.Elem))
+	}
+
+	if i.V < 0 || int(i.V) >= len(a.Elems) {
+		return nil, 0, Throw("java/lang/ArrayIndexOutOfBoundsException", "Index %d out of bounds for length %d", i.V, len(a.Elems))
+	}
+
+	return a, i.V, nil
+}
+
+func (in *Interpreter) arrayLoad(f *Frame, code data.OpCode) error {
+	a, i, err := in.index(f, code)
+	if err != nil {
+		return err
+	}
+	return f.push(a.Get(i))
+}
+
+func (in *Interpreter) arrayStore(f *Frame, code data.OpCode) error {
+	kind := INT
+	if code == data.OP_AASTORE {
+		kind = REFERENCE
+	}
+
+	v, err := f.popKind(kind)
+	if err != nil {
+		return err
+	}
+
+	// index pops the array, so it is seen first
+	var ref Value
+	if len(f.Stack) >= 2 {
+		ref = f.Stack[len(f.Stack)-2]
+	}
+
+	a, i, err := in.index(f, code)
+	if err != nil {
+		return err
+	}
+
+	// stores truncate to the type of the elements
+	switch a.Elem {
+	case "Z":
+		v.V &= 1
+	case "B":
+		v.V = int32(int8(v.V))
+	case "C":
+		v.V = int32(uint16(v.V))
+	case "S":
+		v.V = int32(int16(v.V))
+	}
+
+	in.store(ref, a, i, v.V)
+	return nil
+}
+
+func arithmetic(f *Frame, code data.OpCode) error {
+	b, err := f.popKind(INT)
+	if err != nil {
+		return err
+	}
+	a, err := f.popKind(INT)
+	if err != nil {
+		return err
+	}
+
+	var r int32
+	switch code {
+	case data.OP_IADD:
+		r = a.V + b.V
+	case data.OP_ISUB:
+		r = a.V - b.V
+	case data.OP_IMUL:
+		r = a.V * b.V
+	case data.OP_IDIV, data.OP_IREM:
+		if b.V == 0 {
+			return Throw("java/lang/ArithmeticException", "/ by zero")
+		}
+
+		// Go, like Java, wraps the quotient of math.MinInt32 / -1
+		if code == data.OP_IDIV {
+			r = a.V / b.V
+		} else {
+			r = a.V % b.V
+		}
+	}
+
+	return f.push(Int(r))
+}
+
+func compare(code data.OpCode, a, b int32) bool {
+	switch code {
+	case data.OP_IFEQ, data.OP_IF_ICMPEQ:
+		return a == b
+	case data.OP_IFNE, data.OP_IF_ICMPNE:
+		return a != b
+	case data.OP_IF_ICMPLT:
+		return a < b
+	case data.OP_IFGE, data.OP_IF_ICMPGE:
+		return a >= b
+	case data.OP_IFGT, data.OP_IF_ICMPGT:
+		return a > b
+	case data.OP_IF_ICMPLE:
+		return a <= b
+	default:
+		return false
+	}
+}
+
+// branch to the target of op if taken.
+func branch(f *Frame, op data.Op, taken bool) (*int, error) {
+	if !taken {
+		return nil, nil
+	}
+	offset, ok := op.BranchOffset()
+	if !ok {
+		return nil, fmt.Errorf("%s is not a branch", op.Code)
+	}
+	target := f.PC + offset
+	return &target, nil
+}
+
+func (in *Interpreter) ret(f *Frame, code data.OpCode) error {
+	_, ret, err := data.SplitMethodDescriptor(f.Method.Info.Descriptor.Value)
+	if err != nil {
+		return err
+	}
+
+	want := kindOf(ret)
+
+	var v Value
+	switch code {
+	case data.OP_IRETURN:
+		v, err = f.popKind(INT)
+	case data.OP_ARETURN:
+		v, err = f.popKind(REFERENCE)
+	}
+	if err != nil {
+		return err
+	}
+
+	if v.Kind != want {
+		return fmt.Errorf("%s cannot return %s", f.Method, v.Kind)
+	}
+
+	if len(in.Frames) == 1 {
+		in.Result, in.Done = v, true
+		return nil
+	}
+
+	in.Frames = in.Frames[:len(in.Frames)-1]
+	caller := in.Frame()
+	if f.initialiser {
+		// the caller runs again the instruction that needed the class
+		in.states[f.Method.ClassName] = initialised
+		return nil
+	}
+	if v.Kind != VOID {
+		if err := caller.push(v); err != nil {
+			return err
+		}
+	}
+	return caller.advance()
+}
+
+func (in *Interpreter) athrow(f *Frame) error {
+	ref, err := f.popKind(REFERENCE)
+	if err != nil {
+		return err
+	}
+
+	cell, err := in.Heap.Get(ref)
+	if err != nil {
+		return err
+	}
+
+	switch cell := cell.(type) {
+	case nil:
+		return Throw("java/lang/NullPointerException", "")
+	case *Object:
+		e := &Exception{Class: cell.Class, Ref: ref}
+		if msg, ok := cell.Native.(Value); ok && !msg.IsNull() {
+			if e.Message, err = in.StringOf(msg); err != nil {
+				return err
+			}
+		}
+		return e
+	default:
+		return fmt.Errorf("expected a throwable, got %T", cell)
+	}
+}
@@ -0,0 +1,284 @@
+package interpreter_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/assembler"
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/internal/testutil"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+const source = `.class public super jpamb/cases/Loops
+
+.field static final synthetic $assertionsDisabled Z
+
+.method static <clinit>()V
+  .limit stack 1
+  .limit locals 0
+  ldc class jpamb/cases/Loops
+  invokevirtual java/lang/Class/desiredAssertionStatus()Z
+  ifne L13
+  iconst_1
+  goto L14
+L13:
+  iconst_0
+L14:
+  putstatic jpamb/cases/Loops/$assertionsDisabled Z
+  return
+.end method
+
+.method public static sum(I)I
+  .limit stack 2
+  .limit locals 2
+  iconst_0
+  istore_1
+L2:
+  iload_0
+  ifeq L14
+  iload_1
+  iload_0
+  iadd
+  istore_1
+  iinc 0 -1
+  goto L2
+L14:
+  iload_1
+  ireturn
+.end method
+
+.method public static divide(II)I
+  .limit stack 2
+  .limit locals 2
+  iload_0
+  iload_1
+  idiv
+  ireturn
+.end method
+
+.method public static squares(I)[I
+  .limit stack 4
+  .limit locals 2
+  iload_0
+  newarray int
+  astore_1
+L4:
+  iload_0
+  ifeq L20
+  iinc 0 -1
+  aload_1
+  iload_0
+  iload_0
+  iload_0
+  imul
+  iastore
+  goto L4
+L20:
+  aload_1
+  areturn
+.end method
+
+.method public static at([CI)I
+  .limit stack 2
+  .limit locals 2
+  aload_0
+  iload_1
+  caload
+  ireturn
+.end method
+
+.method public static length([I)I
+  .limit stack 1
+  .limit locals 1
+  aload_0
+  arraylength
+  ireturn
+.end method
+
+.method public static forever()V
+  .limit stack 0
+  .limit locals 0
+L0:
+  goto L0
+.end method
+
+.method public static assertPositive(I)V
+  .limit stack 2
+  .limit locals 1
+  getstatic jpamb/cases/Loops/$assertionsDisabled Z
+  ifne L18
+  iload_0
+  ifgt L18
+  new java/lang/AssertionError
+  dup
+  invokespecial java/lang/AssertionError/<init>()V
+  athrow
+L18:
+  return
+.end method
+
+.method public static underflow()I
+  .limit stack 1
+  .limit locals 0
+  iadd
+  ireturn
+.end method
+`
+
+func TestInterpreter(t *testing.T) {
+	class, resolve, err := assembler.Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	method := func(name string) *interpreter.Method {
+		for _, info := range class.Methods {
+			if info.Name.Value == name {
+				m, err := interpreter.NewMethod(class, info, resolve)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return m
+			}
+		}
+		t.Fatalf("no method %s", name)
+		return nil
+	}
+
+	run := func(name string, args func(h *interpreter.Heap) []interpreter.Value) (*interpreter.Interpreter, error) {
+		in := interpreter.New()
+		if err := in.Start(method(name), args(in.Heap)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return in, in.Run(1000)
+	}
+
+	ints := func(vs ...int32) func(*interpreter.Heap) []interpreter.Value {
+		return func(*interpreter.Heap) []interpreter.Value {
+			var args []interpreter.Value
+			for _, v := range vs {
+				args = append(args, interpreter.Int(v))
+			}
+			return args
+		}
+	}
+
+	if in, err := run("sum", ints(10)); err != nil || in.Result != interpreter.Int(55) {
+		t.Errorf("sum(10) = %v, %v", in.Result, err)
+	}
+
+	if in, err := run("divide", ints(-7, 2)); err != nil || in.Result != interpreter.Int(-3) {
+		t.Errorf("divide(-7, 2) = %v, %v", in.Result, err)
+	}
+
+	var e *interpreter.Exception
+	if _, err := run("divide", ints(1, 0)); !errors.As(err, &e) || e.Class != "java/lang/ArithmeticException" || e.PC != 2 {
+		t.Errorf("divide(1, 0): %v", err)
+	}
+
+	in, err := run("squares", ints(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cell, err := in.Heap.Get(in.Result); err != nil || cell.(*interpreter.Array).String() != "[I:0,1,4,9]" {
+		t.Errorf("squares(4) = %v, %v", cell, err)
+	}
+
+	if _, err := run("squares", ints(-1)); !errors.As(err, &e) || e.Class != "java/lang/NegativeArraySizeException" {
+		t.Errorf("squares(-1): %v", err)
+	}
+
+	if _, err := run("squares", ints(math.MaxInt32)); !errors.As(err, &e) || e.Class != "java/lang/OutOfMemoryError" {
+		t.Errorf("squares(%d): %v", math.MaxInt32, err)
+	}
+
+	chars := func(i int32) func(h *interpreter.Heap) []interpreter.Value {
+		return func(h *interpreter.Heap) []interpreter.Value {
+			a, _ := h.NewArray("C", 2)
+			cell, _ := h.Get(a)
+			copy(cell.(*interpreter.Array).Elems, []int32{'a', 'b'})
+			return []interpreter.Value{a, interpreter.Int(i)}
+		}
+	}
+
+	if in, err := run("at", chars(1)); err != nil || in.Result != interpreter.Int('b') {
+		t.Errorf("at(['a', 'b'], 1) = %v, %v", in.Result, err)
+	}
+
+	if _, err := run("at", chars(2)); !errors.As(err, &e) || e.Class != "java/lang/ArrayIndexOutOfBoundsException" || e.PC != 2 {
+		t.Errorf("at(['a', 'b'], 2): %v", err)
+	}
+
+	if _, err := run("length", func(*interpreter.Heap) []interpreter.Value { return []interpreter.Value{interpreter.Null} }); !errors.As(err, &e) || e.Class != "java/lang/NullPointerException" {
+		t.Errorf("length(null): %v", err)
+	}
+
+	if in, err := run("forever", ints()); !errors.Is(err, interpreter.ErrBudget) || in.Steps != 1000 {
+		t.Errorf("forever: %v after %d steps", err, in.Steps)
+	}
+
+	if _, err := run("underflow", ints()); err == nil || errors.As(err, &e) {
+		t.Errorf("underflow: %v", err)
+	}
+
+	in = interpreter.New()
+	ints1, _ := in.Heap.NewArray("I", 1)
+	if err := in.Start(method("at"), []interpreter.Value{ints1, interpreter.Int(0)}); err == nil {
+		t.Error("expected an int[] not to be accepted as a char[]")
+	}
+	if err := in.Start(method("sum"), []interpreter.Value{interpreter.Null}); err == nil {
+		t.Error("expected null not to be accepted as an int")
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	cp, classes := testutil.Classpath(t, source)
+	c := classes[0]
+
+	for _, tc := range []struct {
+		method string
+		args   func(h *interpreter.Heap) []interpreter.Value
+		want   string
+	}{
+		{"sum", func(*interpreter.Heap) []interpreter.Value { return []interpreter.Value{interpreter.Int(3)} }, "ok at 17"},
+		{"divide", func(*interpreter.Heap) []interpreter.Value {
+			return []interpreter.Value{interpreter.Int(1), interpreter.Int(0)}
+		}, "divide by zero at 2"},
+		{"at", func(h *interpreter.Heap) []interpreter.Value {
+			a, _ := h.NewArray("C", 0)
+			return []interpreter.Value{a, interpreter.Int(0)}
+		}, "out of bounds at 2"},
+		{"length", func(*interpreter.Heap) []interpreter.Value { return []interpreter.Value{interpreter.Null} }, "null pointer at 1"},
+		{"assertPositive", func(*interpreter.Heap) []interpreter.Value { return []interpreter.Value{interpreter.Int(0)} }, "assertion error at 17"},
+		{"assertPositive", func(*interpreter.Heap) []interpreter.Value { return []interpreter.Value{interpreter.Int(1)} }, "ok at 18"},
+		{"forever", func(*interpreter.Heap) []interpreter.Value { return nil }, "* at 0"},
+	} {
+		m, err := c.Method(data.Selector{Name: tc.method})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		in := interpreter.New(interpreter.WithClasspath(cp))
+		if err := in.Start(m, tc.args(in.Heap)); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := in.Execute(100); err != nil || got.String() != tc.want {
+			t.Errorf("%s: got %v, %v, want %s", tc.method, got, err, tc.want)
+		}
+	}
+
+	m, err := c.Method(data.Selector{Name: "squares"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := interpreter.New(interpreter.WithClasspath(cp))
+	if err := in.Start(m, []interpreter.Value{interpreter.Int(-1)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Execute(100); err == nil {
+		t.Error("expected NegativeArraySizeException to have no outcome")
+	}
+}
@@ -0,0 +1,170 @@
+package interpreter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// invoke a method, pushing a frame for it with the arguments popped from f.
+// Its result is pushed onto f when it returns, or at once if a Native models
+// it.
+func (in *Interpreter) invoke(f *Frame, op data.Op) error {
+	i, _ := op.ConstantIndex()
+	tag := data.CP_METHODREF
+	if c, err := constant(f.Method.Class, i, data.CP_INTERFACE_METHODREF); err == nil && op.Code != data.OP_INVOKEVIRTUAL {
+		tag = c.Tag() // a static or private method of an interface, from 52.0
+	}
+	r, err := ref(f.Method.Class, i, tag)
+	if err != nil {
+		return err
+	}
+
+	params, ret, err := data.SplitMethodDescriptor(r.Descriptor)
+	if err != nil {
+		return err
+	}
+
+	n := len(params)
+	if op.Code != data.OP_INVOKESTATIC {
+		n++
+	}
+	if len(f.Stack) < n {
+		return fmt.Errorf("%s takes %d arguments, the stack holds %d", r, n, len(f.Stack))
+	}
+
+	var (
+		m      *Method
+		native Native
+	)
+	if op.Code == data.OP_INVOKESTATIC {
+		// initialised before the arguments are popped, as the instruction
+		// is run again after <clinit>
+		var class string
+		if m, native, class, err = in.resolve(r.Class, r.Name, r.Descriptor); err != nil {
+			return err
+		}
+		if native == nil {
+			if pending, err := in.initialise(class); err != nil || pending {
+				return err
+			}
+		}
+	}
+
+	args := slices.Clone(f.Stack[len(f.Stack)-n:])
+	f.Stack = f.Stack[:len(f.Stack)-n]
+
+	var receiver any
+	if op.Code != data.OP_INVOKESTATIC {
+		if args[0].Kind != REFERENCE {
+			return fmt.Errorf("expected a reference to invoke %s on, got %s", r, args[0].Kind)
+		}
+
+		if receiver, err = in.Heap.Get(args[0]); err != nil {
+			return err
+		}
+		if receiver == nil {
+			return Throw("java/lang/NullPointerException", "")
+		}
+
+		class := r.Class
+		if op.Code == data.OP_INVOKEVIRTUAL {
+			obj, ok := receiver.(*Object)
+			if !ok {
+				return fmt.Errorf("invoking %s on %T is not supported", r, receiver)
+			}
+			class = obj.Class
+		}
+
+		if m, native, _, err = in.resolve(class, r.Name, r.Descriptor); err != nil {
+			return err
+		}
+	}
+
+	if native != nil {
+		if op.Code != data.OP_INVOKESTATIC {
+			params = append([]string{"Ljava/lang/Object;"}, params...)
+		}
+		for i, p := range params {
+			if err := in.check(p, args[i]); err != nil {
+				return fmt.Errorf("argument %d of %s: %w", i, r, err)
+			}
+		}
+
+		v, err := native(in, args)
+		if err != nil {
+			return err
+		}
+		if want := kindOf(ret); v.Kind != want {
+			return fmt.Errorf("model of %s returned %s, not %s", r, v.Kind, want)
+		}
+		if v.Kind == VOID {
+			return nil
+		}
+		return f.push(v)
+	}
+
+	if m.Static() != (op.Code == data.OP_INVOKESTATIC) {
+		return fmt.Errorf("%s cannot invoke %s", op.Code, r)
+	}
+
+	if len(in.Frames) >= in.maxDepth {
+		return Throw("java/lang/StackOverflowError", "more than %d frames", in.maxDepth)
+	}
+
+	callee, err := in.frame(m, args)
+	if err != nil {
+		return err
+	}
+
+	in.Frames = append(in.Frames, callee)
+	return nil
+}
+
+// resolve the method by name and descriptor in class, or else in the nearest
+// of its superclasses to declare it, which is returned with it. A Native of the
+// method in a class is taken over its bytecode, and is the only way to run the
+// methods of the JDK.
+func (in *Interpreter) resolve(class, name, descriptor string) (*Method, Native, string, error) {
+	for next := class; next != ""; {
+		if fn, ok := in.natives[Ref{Class: next, Name: name, Descriptor: descriptor}]; ok {
+			return nil, fn, next, nil
+		}
+
+		if super, ok := jdk[next]; ok {
+			next = super
+			continue
+		}
+
+		c, err := in.classpath.Load(in.ctx, next)
+		if err != nil {
+			if strings.HasPrefix(next, "java/") {
+				return nil, nil, "", noModel(class, name, descriptor)
+			}
+			return nil, nil, "", err
+		}
+
+		if m, err := c.method(name + descriptor); err != nil || m != nil {
+			return m, nil, c.Name, err
+		}
+
+		sel := data.Selector{Name: name, Descriptor: descriptor}
+		for _, info := range c.Data.Methods {
+			if sel.Matches(c.Data, info) {
+				return nil, nil, "", fmt.Errorf("method %s.%s%s has no code", c.Name, name, descriptor)
+			}
+		}
+
+		next = c.Super
+	}
+
+	// every class extends java/lang/Object, which the JDK declares
+	return nil, nil, "", noModel(class, name, descriptor)
+}
+
+// noModel is the diagnostic for a method only the JDK could declare.
+func noModel(class, name, descriptor string) error {
+	return fmt.Errorf("%w of %s.%s%s, and no bytecode for it: register a Native for it with Natives.Register", ErrNoModel, class, name, descriptor)
+}
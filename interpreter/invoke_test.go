@@ -0,0 +1,170 @@
+package interpreter_test
+
+import (
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/internal/testutil"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+const calls = `.class public super jpamb/cases/Calls
+.super jpamb/cases/Base
+
+.method public <init>()V
+  .limit stack 1
+  .limit locals 1
+  aload_0
+  invokespecial jpamb/cases/Base/<init>()V
+  return
+.end method
+
+.method public value()I
+  .limit stack 1
+  .limit locals 1
+  iconst_2
+  ireturn
+.end method
+
+.method public static factorial(I)I
+  .limit stack 3
+  .limit locals 1
+  iload_0
+  ifgt L6
+  iconst_1
+  ireturn
+L6:
+  iload_0
+  iload_0
+  iconst_1
+  isub
+  invokestatic jpamb/cases/Calls/factorial(I)I
+  imul
+  ireturn
+.end method
+
+.method public static dispatch()I
+  .limit stack 2
+  .limit locals 0
+  new jpamb/cases/Calls
+  dup
+  invokespecial jpamb/cases/Calls/<init>()V
+  invokevirtual jpamb/cases/Base/value()I
+  ireturn
+.end method
+
+.method public static inherited()I
+  .limit stack 2
+  .limit locals 0
+  new jpamb/cases/Calls
+  dup
+  invokespecial jpamb/cases/Calls/<init>()V
+  invokevirtual jpamb/cases/Calls/base()I
+  ireturn
+.end method
+
+.method public static onNull()I
+  .limit stack 1
+  .limit locals 0
+  aconst_null
+  invokevirtual jpamb/cases/Base/value()I
+  ireturn
+.end method
+
+.method public static sides()I
+  .limit stack 1
+  .limit locals 0
+  invokestatic interface jpamb/cases/Polygon/sides()I
+  ireturn
+.end method
+
+.method public static recurse()V
+  .limit stack 0
+  .limit locals 0
+  invokestatic jpamb/cases/Calls/recurse()V
+  return
+.end method
+`
+
+const base = `.class public super jpamb/cases/Base
+.super java/lang/Object
+
+.method public <init>()V
+  .limit stack 1
+  .limit locals 1
+  aload_0
+  invokespecial java/lang/Object/<init>()V
+  return
+.end method
+
+.method public value()I
+  .limit stack 1
+  .limit locals 1
+  iconst_1
+  ireturn
+.end method
+
+.method public base()I
+  .limit stack 1
+  .limit locals 1
+  aload_0
+  invokevirtual jpamb/cases/Base/value()I
+  ireturn
+.end method
+`
+
+const polygon = `.version 52.0
+.class public interface abstract jpamb/cases/Polygon
+.super java/lang/Object
+
+.method public static sides()I
+  .limit stack 1
+  .limit locals 0
+  iconst_4
+  ireturn
+.end method
+`
+
+func TestInvoke(t *testing.T) {
+	cp, _ := testutil.Classpath(t, calls, base, polygon)
+
+	run := func(name string, args ...interpreter.Value) (*interpreter.Interpreter, interpreter.Termination, error) {
+		c, _ := cp.Load(t.Context(), "jpamb/cases/Calls")
+		m, err := c.Method(data.Selector{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		in := interpreter.New(interpreter.WithClasspath(cp), interpreter.WithMaxDepth(64))
+		if err := in.Start(m, args); err != nil {
+			t.Fatal(err)
+		}
+		term, err := in.Execute(10000)
+		return in, term, err
+	}
+
+	if in, got, err := run("factorial", interpreter.Int(5)); err != nil || got.Result != interpreter.Int(120) || len(in.Frames) != 1 {
+		t.Errorf("factorial(5) = %v, %v with %d frames left", got.Result, err, len(in.Frames))
+	}
+
+	if _, got, err := run("dispatch"); err != nil || got.Result != interpreter.Int(2) {
+		t.Errorf("dispatch() = %v, %v, want the override", got.Result, err)
+	}
+
+	if _, got, err := run("inherited"); err != nil || got.Result != interpreter.Int(2) {
+		t.Errorf("inherited() = %v, %v, want the override", got.Result, err)
+	}
+
+	if _, got, err := run("sides"); err != nil || got.Result != interpreter.Int(4) {
+		t.Errorf("sides() = %v, %v, want the static method of the interface", got.Result, err)
+	}
+
+	if _, got, err := run("onNull"); err != nil || got.String() != "null pointer at 1" {
+		t.Errorf("onNull() = %v, %v", got, err)
+	}
+
+	if in, got, err := run("recurse"); err != nil || got.Outcome != interpreter.NON_TERMINATION || got.Thrown == nil ||
+		got.Thrown.Class != "java/lang/StackOverflowError" || len(in.Frames) != 64 {
+		t.Errorf("recurse() = %v, %v with %d frames", got, err, len(in.Frames))
+	}
+}
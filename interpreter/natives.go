@@ -0,0 +1,542 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Native is a model of a method in Go, for methods of the JDK, which has no
+// bytecode to run them from, or stubs of any other. It is run on the arguments
+// of the method, this first if it is not static, and returns its result, or a
+// Value of kind VOID for void methods. It throws an exception by returning the
+// error of Throw.
+type Native func(in *Interpreter, args []Value) (Value, error)
+
+// ErrNoModel is returned when invoking a method of the JDK that has no Native.
+var ErrNoModel = errors.New("no model")
+
+// Natives are the models of methods, by the class, name and descriptor of the
+// method they model. The interpreter consults them before loading bytecode, in
+// the classes of the method and then its superclasses, so that a model also
+// stands in for overrides in classes that do not declare one.
+type Natives map[Ref]Native
+
+// Register fn as the model of the method of class, an internal name, by name
+// and descriptor, replacing any other, e.g.
+//
+//	natives := interpreter.DefaultNatives()
+//	natives.Register("jpamb/utils/Support", "assume", "(Z)V", func(in *interpreter.Interpreter, args []interpreter.Value) (interpreter.Value, error) {
+//		return interpreter.Value{}, nil
+//	})
+//	in := interpreter.New(interpreter.WithNatives(natives))
+func (n Natives) Register(class, name, descriptor string, fn Native) {
+	n[Ref{Class: class, Name: name, Descriptor: descriptor}] = fn
+}
+
+// DefaultNatives are a copy of the models the interpreter ships with:
+//
+//   - the constructors of java/lang/Object, and of the throwables in the
+//     hierarchy the interpreter knows, without arguments or with a message,
+//     and of java/lang/AssertionError with an Object, boolean, char or int
+//   - java/lang/Throwable.getMessage
+//   - java/lang/Class.desiredAssertionStatus, which is true, as with java -ea
+//   - java/lang/Math.abs, min, max, addExact, subtractExact, multiplyExact,
+//     negateExact, floorDiv and floorMod of ints
+//   - java/lang/Integer.parseInt, valueOf, intValue, toString and compare
+//   - java/lang/String.length, charAt, isEmpty, equals, hashCode, concat,
+//     toCharArray and valueOf of ints and chars
+//   - java/lang/StringBuilder constructors, append of ints, chars, booleans
+//     and Strings, length and toString
+//   - java/util/Arrays.toString, sort, fill, copyOf and equals of int arrays
+func DefaultNatives() Natives {
+	n := make(Natives, len(defaultNatives))
+	for ref, fn := range defaultNatives {
+		n[ref] = fn
+	}
+	return n
+}
+
+var defaultNatives = func() Natives {
+	n := make(Natives)
+
+	none := func(*Interpreter, []Value) (Value, error) { return Value{}, nil }
+	n.Register("java/lang/Object", "<init>", "()V", none)
+
+	// constructors store the message of the throwable, if given
+	withMessage := func(in *Interpreter, args []Value) (Value, error) {
+		o, err := in.object(args[0])
+		if err == nil {
+			o.Native = args[1]
+		}
+		return Value{}, err
+	}
+	for class := range jdk {
+		if throwable(class) {
+			n.Register(class, "<init>", "()V", none)
+			n.Register(class, "<init>", "(Ljava/lang/String;)V", withMessage)
+		}
+	}
+
+	// new AssertionError(detail) takes the string of detail as message
+	assertion := func(format func(in *Interpreter, v Value) (string, error)) Native {
+		return func(in *Interpreter, args []Value) (Value, error) {
+			o, err := in.object(args[0])
+			if err != nil {
+				return Value{}, err
+			}
+			s, err := format(in, args[1])
+			if err != nil {
+				return Value{}, err
+			}
+			o.Native = in.NewString(s)
+			return Value{}, nil
+		}
+	}
+	n.Register("java/lang/AssertionError", "<init>", "(Ljava/lang/Object;)V", assertion((*Interpreter).valueOf))
+	n.Register("java/lang/AssertionError", "<init>", "(Z)V", assertion(func(_ *Interpreter, v Value) (string, error) {
+		return strconv.FormatBool(v.V != 0), nil
+	}))
+	n.Register("java/lang/AssertionError", "<init>", "(C)V", assertion(func(_ *Interpreter, v Value) (string, error) {
+		return string(rune(uint16(v.V))), nil
+	}))
+	n.Register("java/lang/AssertionError", "<init>", "(I)V", assertion(func(_ *Interpreter, v Value) (string, error) {
+		return strconv.Itoa(int(v.V)), nil
+	}))
+
+	n.Register("java/lang/Throwable", "getMessage", "()Ljava/lang/String;", func(in *Interpreter, args []Value) (Value, error) {
+		o, err := in.object(args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		if msg, ok := o.Native.(Value); ok {
+			return msg, nil
+		}
+		return Null, nil
+	})
+
+	n.Register("java/lang/Class", "desiredAssertionStatus", "()Z", func(*Interpreter, []Value) (Value, error) {
+		return Bool(true), nil
+	})
+
+	registerMath(n)
+	registerStrings(n)
+	registerArrays(n)
+
+	return n
+}()
+
+// throwable reports whether class of the JDK extends java/lang/Throwable.
+func throwable(class string) bool {
+	for ; class != ""; class = jdk[class] {
+		if class == "java/lang/Throwable" {
+			return true
+		}
+	}
+	return false
+}
+
+// ints models a static method of ints returning an int.
+func ints(fn func(args []int32) (int32, error)) Native {
+	return func(_ *Interpreter, args []Value) (Value, error) {
+		vs := make([]int32, len(args))
+		for i, a := range args {
+			vs[i] = a.V
+		}
+		v, err := fn(vs)
+		return Int(v), err
+	}
+}
+
+func registerMath(n Natives) {
+	exact := func(r int64) (int32, error) {
+		if r != int64(int32(r)) {
+			return 0, Throw("java/lang/ArithmeticException", "integer overflow")
+		}
+		return int32(r), nil
+	}
+
+	n.Register("java/lang/Math", "abs", "(I)I", ints(func(a []int32) (int32, error) {
+		if a[0] < 0 {
+			return -a[0], nil // abs of MinInt32 is itself, as in Java
+		}
+		return a[0], nil
+	}))
+	n.Register("java/lang/Math", "min", "(II)I", ints(func(a []int32) (int32, error) { return min(a[0], a[1]), nil }))
+	n.Register("java/lang/Math", "max", "(II)I", ints(func(a []int32) (int32, error) { return max(a[0], a[1]), nil }))
+	n.Register("java/lang/Math", "addExact", "(II)I", ints(func(a []int32) (int32, error) {
+		return exact(int64(a[0]) + int64(a[1]))
+	}))
+	n.Register("java/lang/Math", "subtractExact", "(II)I", ints(func(a []int32) (int32, error) {
+		return exact(int64(a[0]) - int64(a[1]))
+	}))
+	n.Register("java/lang/Math", "multiplyExact", "(II)I", ints(func(a []int32) (int32, error) {
+		return exact(int64(a[0]) * int64(a[1]))
+	}))
+	n.Register("java/lang/Math", "negateExact", "(I)I", ints(func(a []int32) (int32, error) {
+		return exact(-int64(a[0]))
+	}))
+	n.Register("java/lang/Math", "floorDiv", "(II)I", ints(func(a []int32) (int32, error) {
+		if a[1] == 0 {
+			return 0, Throw("java/lang/ArithmeticException", "/ by zero")
+		}
+		q := a[0] / a[1]
+		if a[0]%a[1] != 0 && (a[0] < 0) != (a[1] < 0) {
+			q--
+		}
+		return q, nil
+	}))
+	n.Register("java/lang/Math", "floorMod", "(II)I", ints(func(a []int32) (int32, error) {
+		if a[1] == 0 {
+			return 0, Throw("java/lang/ArithmeticException", "/ by zero")
+		}
+		m := a[0] % a[1]
+		if m != 0 && (m < 0) != (a[1] < 0) {
+			m += a[1]
+		}
+		return m, nil
+	}))
+
+	n.Register("java/lang/Integer", "compare", "(II)I", ints(func(a []int32) (int32, error) {
+		switch {
+		case a[0] < a[1]:
+			return -1, nil
+		case a[0] > a[1]:
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	}))
+}
+
+func registerStrings(n Natives) {
+	str := func(fn func(in *Interpreter, s []uint16, args []Value) (Value, error)) Native {
+		return func(in *Interpreter, args []Value) (Value, error) {
+			s, err := in.chars(args[0])
+			if err != nil {
+				return Value{}, err
+			}
+			return fn(in, s, args[1:])
+		}
+	}
+
+	n.Register("java/lang/Integer", "parseInt", "(Ljava/lang/String;)I", str(func(_ *Interpreter, s []uint16, _ []Value) (Value, error) {
+		v, err := strconv.ParseInt(string(utf16.Decode(s)), 10, 32)
+		if err != nil {
+			return Value{}, Throw("java/lang/NumberFormatException", "For input string: %q", string(utf16.Decode(s)))
+		}
+		return Int(int32(v)), nil
+	}))
+	n.Register("java/lang/Integer", "toString", "(I)Ljava/lang/String;", func(in *Interpreter, args []Value) (Value, error) {
+		return in.NewString(strconv.Itoa(int(args[0].V))), nil
+	})
+	n.Register("java/lang/Integer", "valueOf", "(I)Ljava/lang/Integer;", func(in *Interpreter, args []Value) (Value, error) {
+		v := in.Heap.NewObject("java/lang/Integer")
+		o, _ := in.object(v)
+		o.Native = args[0].V
+		return v, nil
+	})
+	n.Register("java/lang/Integer", "intValue", "()I", func(in *Interpreter, args []Value) (Value, error) {
+		o, err := in.object(args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		v, ok := o.Native.(int32)
+		if !ok {
+			return Value{}, fmt.Errorf("%s has no value", o.Class)
+		}
+		return Int(v), nil
+	})
+
+	n.Register("java/lang/String", "length", "()I", str(func(_ *Interpreter, s []uint16, _ []Value) (Value, error) {
+		return Int(int32(len(s))), nil
+	}))
+	n.Register("java/lang/String", "isEmpty", "()Z", str(func(_ *Interpreter, s []uint16, _ []Value) (Value, error) {
+		return Bool(len(s) == 0), nil
+	}))
+	n.Register("java/lang/String", "charAt", "(I)C", str(func(_ *Interpreter, s []uint16, args []Value) (Value, error) {
+		if i := args[0].V; i < 0 || int(i) >= len(s) {
+			return Value{}, Throw("java/lang/StringIndexOutOfBoundsException", "Index %d out of bounds for length %d", i, len(s))
+		}
+		return Int(int32(s[args[0].V])), nil
+	}))
+	n.Register("java/lang/String", "equals", "(Ljava/lang/Object;)Z", str(func(in *Interpreter, s []uint16, args []Value) (Value, error) {
+		if args[0].IsNull() {
+			return Bool(false), nil
+		}
+		o, err := in.object(args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		other, ok := o.Native.([]uint16)
+		return Bool(ok && o.Class == "java/lang/String" && slices.Equal(s, other)), nil
+	}))
+	n.Register("java/lang/String", "hashCode", "()I", str(func(_ *Interpreter, s []uint16, _ []Value) (Value, error) {
+		var h int32
+		for _, c := range s {
+			h = 31*h + int32(c)
+		}
+		return Int(h), nil
+	}))
+	n.Register("java/lang/String", "concat", "(Ljava/lang/String;)Ljava/lang/String;", str(func(in *Interpreter, s []uint16, args []Value) (Value, error) {
+		other, err := in.chars(args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		return in.newString(slices.Concat(s, other)), nil
+	}))
+	n.Register("java/lang/String", "toCharArray", "()[C", str(func(in *Interpreter, s []uint16, _ []Value) (Value, error) {
+		v, err := in.Heap.NewArray("C", int32(len(s)))
+		if err != nil {
+			return Value{}, err
+		}
+		a, _ := in.Array(v)
+		for i, c := range s {
+			in.store(v, a, int32(i), int32(c))
+		}
+		return v, nil
+	}))
+	n.Register("java/lang/String", "valueOf", "(I)Ljava/lang/String;", func(in *Interpreter, args []Value) (Value, error) {
+		return in.NewString(strconv.Itoa(int(args[0].V))), nil
+	})
+	n.Register("java/lang/String", "valueOf", "(C)Ljava/lang/String;", func(in *Interpreter, args []Value) (Value, error) {
+		return in.newString([]uint16{uint16(args[0].V)}), nil
+	})
+
+	// StringBuilders hold their chars as Strings do
+	const sb = "java/lang/StringBuilder"
+	builder := func(fn func(in *Interpreter, o *Object, args []Value) error) Native {
+		return func(in *Interpreter, args []Value) (Value, error) {
+			o, err := in.object(args[0])
+			if err != nil {
+				return Value{}, err
+			}
+			if o.Native == nil {
+				o.Native = []uint16{}
+			}
+			return args[0], fn(in, o, args[1:])
+		}
+	}
+	appendString := func(s func(in *Interpreter, v Value) ([]uint16, error)) Native {
+		return builder(func(in *Interpreter, o *Object, args []Value) error {
+			chars, err := s(in, args[0])
+			if err == nil {
+				o.Native = append(o.Native.([]uint16), chars...)
+			}
+			return err
+		})
+	}
+	constructor := func(fn Native) Native {
+		return func(in *Interpreter, args []Value) (Value, error) {
+			_, err := fn(in, args)
+			return Value{}, err
+		}
+	}
+	text := func(s string) []uint16 { return utf16.Encode([]rune(s)) }
+
+	n.Register(sb, "<init>", "()V", constructor(builder(func(*Interpreter, *Object, []Value) error { return nil })))
+	n.Register(sb, "<init>", "(Ljava/lang/String;)V", constructor(appendString(func(in *Interpreter, v Value) ([]uint16, error) {
+		return in.chars(v)
+	})))
+	n.Register(sb, "append", "(Ljava/lang/String;)Ljava/lang/StringBuilder;", appendString(func(in *Interpreter, v Value) ([]uint16, error) {
+		if v.IsNull() {
+			return text("null"), nil
+		}
+		return in.chars(v)
+	}))
+	n.Register(sb, "append", "(I)Ljava/lang/StringBuilder;", appendString(func(_ *Interpreter, v Value) ([]uint16, error) {
+		return text(strconv.Itoa(int(v.V))), nil
+	}))
+	n.Register(sb, "append", "(C)Ljava/lang/StringBuilder;", appendString(func(_ *Interpreter, v Value) ([]uint16, error) {
+		return []uint16{uint16(v.V)}, nil
+	}))
+	n.Register(sb, "append", "(Z)Ljava/lang/StringBuilder;", appendString(func(_ *Interpreter, v Value) ([]uint16, error) {
+		return text(strconv.FormatBool(v.V != 0)), nil
+	}))
+	n.Register(sb, "length", "()I", func(in *Interpreter, args []Value) (Value, error) {
+		s, err := in.chars(args[0])
+		return Int(int32(len(s))), err
+	})
+	n.Register(sb, "toString", "()Ljava/lang/String;", func(in *Interpreter, args []Value) (Value, error) {
+		s, err := in.chars(args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		return in.newString(slices.Clone(s)), nil
+	})
+}
+
+func registerArrays(n Natives) {
+	const arrays = "java/util/Arrays"
+
+	n.Register(arrays, "toString", "([I)Ljava/lang/String;", func(in *Interpreter, args []Value) (Value, error) {
+		if args[0].IsNull() {
+			return in.NewString("null"), nil
+		}
+		a, err := in.Array(args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		elems := make([]string, len(a.Elems))
+		for i, e := range a.Elems {
+			elems[i] = strconv.Itoa(int(e))
+		}
+		return in.NewString("[" + strings.Join(elems, ", ") + "]"), nil
+	})
+	n.Register(arrays, "sort", "([I)V", func(in *Interpreter, args []Value) (Value, error) {
+		a, err := in.Array(args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		for i, e := range slices.Sorted(slices.Values(a.Elems)) {
+			in.store(args[0], a, int32(i), e)
+		}
+		return Value{}, nil
+	})
+	n.Register(arrays, "fill", "([II)V", func(in *Interpreter, args []Value) (Value, error) {
+		a, err := in.Array(args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		for i := range a.Elems {
+			in.store(args[0], a, int32(i), args[1].V)
+		}
+		return Value{}, nil
+	})
+	n.Register(arrays, "copyOf", "([II)[I", func(in *Interpreter, args []Value) (Value, error) {
+		a, err := in.Array(args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		if args[1].V < 0 {
+			return Value{}, Throw("java/lang/NegativeArraySizeException", "%d", args[1].V)
+		}
+		v, err := in.Heap.NewArray("I", args[1].V)
+		if err != nil {
+			return Value{}, err
+		}
+		c, _ := in.Array(v)
+		for i := range min(len(a.Elems), len(c.Elems)) {
+			in.store(v, c, int32(i), a.Elems[i])
+		}
+		return v, nil
+	})
+	n.Register(arrays, "equals", "([I[I)Z", func(in *Interpreter, args []Value) (Value, error) {
+		if args[0].IsNull() || args[1].IsNull() {
+			return Bool(args[0] == args[1]), nil
+		}
+		a, err := in.Array(args[0])
+		if err != nil {
+			return Value{}, err
+		}
+		b, err := in.Array(args[1])
+		if err != nil {
+			return Value{}, err
+		}
+		return Bool(slices.Equal(a.Elems, b.Elems)), nil
+	})
+}
+
+// NewString allocates a java/lang/String of s.
+func (in *Interpreter) NewString(s string) Value {
+	return in.newString(utf16.Encode([]rune(s)))
+}
+
+func (in *Interpreter) newString(chars []uint16) Value {
+	v := in.Heap.NewObject("java/lang/String")
+	o, _ := in.object(v)
+	o.Native = chars
+	return v
+}
+
+// intern is the String of the constant s, the same each time.
+func (in *Interpreter) intern(s string) Value {
+	if v, ok := in.strings[s]; ok {
+		return v
+	}
+	v := in.NewString(s)
+	in.strings[s] = v
+	return v
+}
+
+// StringOf is the string v refers to, a java/lang/String or StringBuilder.
+func (in *Interpreter) StringOf(v Value) (string, error) {
+	s, err := in.chars(v)
+	return string(utf16.Decode(s)), err
+}
+
+func (in *Interpreter) chars(v Value) ([]uint16, error) {
+	o, err := in.object(v)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := o.Native.([]uint16)
+	if !ok {
+		return nil, fmt.Errorf("expected a string, got %s", o.Class)
+	}
+	return s, nil
+}
+
+// valueOf is the string of v as String.valueOf gives it: the contents of
+// strings, the value of boxed ints, and the class and identity hash, here its
+// address, of other objects and arrays.
+func (in *Interpreter) valueOf(v Value) (string, error) {
+	cell, err := in.Heap.Get(v)
+	if err != nil {
+		return "", err
+	}
+
+	switch c := cell.(type) {
+	case nil:
+		return "null", nil
+	case *Array:
+		return fmt.Sprintf("[%s@%x", strings.ReplaceAll(c.Elem, "/", "."), v.V), nil
+	case *Object:
+		switch n := c.Native.(type) {
+		case []uint16:
+			return string(utf16.Decode(n)), nil
+		case int32:
+			if c.Class == "java/lang/Integer" {
+				return strconv.Itoa(int(n)), nil
+			}
+		}
+		return fmt.Sprintf("%s@%x", strings.ReplaceAll(c.Class, "/", "."), v.V), nil
+	}
+
+	return "", fmt.Errorf("unexpected %T", cell)
+}
+
+// object is the object v refers to, throwing NullPointerException for null.
+func (in *Interpreter) object(v Value) (*Object, error) {
+	cell, err := in.Heap.Get(v)
+	if err != nil {
+		return nil, err
+	}
+	if cell == nil {
+		return nil, Throw("java/lang/NullPointerException", "")
+	}
+	o, ok := cell.(*Object)
+	if !ok {
+		return nil, fmt.Errorf("expected an object, got %T", cell)
+	}
+	return o, nil
+}
+
+// Array is the array v refers to, throwing NullPointerException for null.
+func (in *Interpreter) Array(v Value) (*Array, error) {
+	cell, err := in.Heap.Get(v)
+	if err != nil {
+		return nil, err
+	}
+	if cell == nil {
+		return nil, Throw("java/lang/NullPointerException", "")
+	}
+	a, ok := cell.(*Array)
+	if !ok {
+		return nil, fmt.Errorf("expected an array, got %T", cell)
+	}
+	return a, nil
+}
@@ -0,0 +1,213 @@
+package interpreter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/internal/testutil"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+const models = `.class public super jpamb/cases/Models
+.super java/lang/Object
+
+.method public static text(I)Ljava/lang/String;
+  .limit stack 3
+  .limit locals 1
+  new java/lang/StringBuilder
+  dup
+  ldc "n="
+  invokespecial java/lang/StringBuilder/<init>(Ljava/lang/String;)V
+  iload_0
+  invokestatic java/lang/Math/abs(I)I
+  invokevirtual java/lang/StringBuilder/append(I)Ljava/lang/StringBuilder;
+  invokevirtual java/lang/StringBuilder/toString()Ljava/lang/String;
+  areturn
+.end method
+
+.method public static sorted([I)Ljava/lang/String;
+  .limit stack 1
+  .limit locals 1
+  aload_0
+  invokestatic java/util/Arrays/sort([I)V
+  aload_0
+  invokestatic java/util/Arrays/toString([I)Ljava/lang/String;
+  areturn
+.end method
+
+.method public static parse()I
+  .limit stack 1
+  .limit locals 0
+  ldc "12x"
+  invokestatic java/lang/Integer/parseInt(Ljava/lang/String;)I
+  ireturn
+.end method
+
+.method public static overflow(I)I
+  .limit stack 2
+  .limit locals 1
+  iload_0
+  iload_0
+  invokestatic java/lang/Math/multiplyExact(II)I
+  ireturn
+.end method
+
+.method public static fail(I)V
+  .limit stack 3
+  .limit locals 1
+  new java/lang/AssertionError
+  dup
+  iload_0
+  invokespecial java/lang/AssertionError/<init>(I)V
+  athrow
+.end method
+
+.method public static boxed(I)V
+  .limit stack 3
+  .limit locals 1
+  new java/lang/AssertionError
+  dup
+  iload_0
+  invokestatic java/lang/Integer/valueOf(I)Ljava/lang/Integer;
+  invokespecial java/lang/AssertionError/<init>(Ljava/lang/Object;)V
+  athrow
+.end method
+
+.method public static array(I)V
+  .limit stack 3
+  .limit locals 1
+  new java/lang/AssertionError
+  dup
+  iload_0
+  newarray int
+  invokespecial java/lang/AssertionError/<init>(Ljava/lang/Object;)V
+  athrow
+.end method
+
+.method public static assume(I)I
+  .limit stack 1
+  .limit locals 1
+  iload_0
+  invokestatic jpamb/utils/Support/assume(I)I
+  ireturn
+.end method
+
+.method public static unmodelled()I
+  .limit stack 1
+  .limit locals 0
+  ldc "x"
+  invokevirtual java/lang/String/intern()Ljava/lang/String;
+  invokevirtual java/lang/String/length()I
+  ireturn
+.end method
+
+.method public static unboxed()I
+  .limit stack 1
+  .limit locals 0
+  new java/lang/Integer
+  invokevirtual java/lang/Integer/intValue()I
+  ireturn
+.end method
+
+.method public static mistyped()I
+  .limit stack 1
+  .limit locals 0
+  aconst_null
+  invokestatic java/lang/Math/abs(I)I
+  ireturn
+.end method
+
+.method public static broken()I
+  .limit stack 1
+  .limit locals 0
+  invokestatic jpamb/utils/Support/broken()I
+  ireturn
+.end method
+`
+
+func TestNatives(t *testing.T) {
+	cp, classes := testutil.Classpath(t, models)
+	c := classes[0]
+
+	natives := interpreter.DefaultNatives()
+	natives.Register("jpamb/utils/Support", "assume", "(I)I", func(in *interpreter.Interpreter, args []interpreter.Value) (interpreter.Value, error) {
+		return interpreter.Int(args[0].V + 1), nil
+	})
+	natives.Register("jpamb/utils/Support", "broken", "()I", func(in *interpreter.Interpreter, args []interpreter.Value) (interpreter.Value, error) {
+		return interpreter.Null, nil
+	})
+
+	run := func(name string, inputs string) (*interpreter.Interpreter, interpreter.Termination, error) {
+		m, err := c.Method(data.Selector{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		in := interpreter.New(interpreter.WithClasspath(cp), interpreter.WithNatives(natives))
+		ins, err := interpreter.ParseInputs(inputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args, err := in.Args(m, ins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := in.Start(m, args); err != nil {
+			t.Fatal(err)
+		}
+		term, err := in.Execute(1000)
+		return in, term, err
+	}
+
+	returns := map[string]struct{ inputs, want string }{
+		"text":   {"(-7)", `"n=7"`},
+		"sorted": {"([I:3,1,2])", `"[1, 2, 3]"`},
+		"assume": {"(1)", "2"},
+	}
+	for name, tc := range returns {
+		in, got, err := run(name, tc.inputs)
+		if err != nil {
+			t.Errorf("%s%s: %v", name, tc.inputs, err)
+			continue
+		}
+		desc := "I"
+		if got.Result.Kind == interpreter.REFERENCE {
+			desc = "Ljava/lang/String;"
+		}
+		if s := in.Format(got.Result, desc); s != tc.want {
+			t.Errorf("%s%s = %s, want %s", name, tc.inputs, s, tc.want)
+		}
+	}
+
+	throws := map[string]struct{ inputs, class, message string }{
+		"parse":    {"()", "java/lang/NumberFormatException", `For input string: "12x"`},
+		"overflow": {"(65536)", "java/lang/ArithmeticException", "integer overflow"},
+		"fail":     {"(3)", "java/lang/AssertionError", "3"},
+		"boxed":    {"(5)", "java/lang/AssertionError", "5"},
+	}
+	for name, tc := range throws {
+		_, got, _ := run(name, tc.inputs)
+		if e := got.Thrown; e == nil || e.Class != tc.class || e.Message != tc.message {
+			t.Errorf("%s%s threw %v, want %s: %s", name, tc.inputs, e, tc.class, tc.message)
+		}
+	}
+
+	if _, got, _ := run("array", "(2)"); got.Thrown == nil || !strings.HasPrefix(got.Thrown.Message, "[I@") {
+		t.Errorf("array(2) threw %v, want a message of [I@ and its hash", got.Thrown)
+	}
+
+	if _, _, err := run("unmodelled", "()"); !errors.Is(err, interpreter.ErrNoModel) || !strings.Contains(err.Error(), "java/lang/String.intern()") {
+		t.Errorf("unmodelled() = %v, want %v", err, interpreter.ErrNoModel)
+	}
+
+	for name, want := range map[string]string{
+		"unboxed":  "java/lang/Integer has no value",
+		"mistyped": "argument 0 of java/lang/Math.abs:(I)I: expected int, got reference",
+		"broken":   "returned reference, not int",
+	} {
+		if _, _, err := run(name, "()"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s() = %v, want %s", name, err, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package interpreter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// classState is how far the initialisation of a class has got.
+type classState int
+
+const (
+	uninitialised classState = iota
+	initialising             // its <clinit> is running
+	initialised
+	erroneous // its <clinit> threw
+)
+
+// initialise class before its first use, running the <clinit> of its
+// superclasses and then its own, as the JVM does. It reports whether a frame
+// was pushed to do so, in which case the instruction that needed the class is
+// run again once it returns, initialising the next. A class whose
+// initialisation failed throws NoClassDefFoundError.
+func (in *Interpreter) initialise(class string) (bool, error) {
+	var classes []*Class // the class first, its furthest superclass last
+	for name := class; name != "" && !builtin(name); {
+		switch in.states[name] {
+		case erroneous:
+			return false, Throw("java/lang/NoClassDefFoundError", "Could not initialize class %s", strings.ReplaceAll(name, "/", "."))
+		case uninitialised:
+		default:
+			name = ""
+			continue
+		}
+
+		c, err := in.classpath.Load(in.ctx, name)
+		if err != nil {
+			return false, err
+		}
+		classes = append(classes, c)
+		name = c.Super
+	}
+
+	for _, c := range slices.Backward(classes) {
+		m, err := c.method("<clinit>()V")
+		if err != nil {
+			return false, err
+		}
+		if m != nil && len(in.Frames) >= in.maxDepth {
+			return false, Throw("java/lang/StackOverflowError", "more than %d frames", in.maxDepth)
+		}
+
+		// marked before <clinit> runs, so that it can use the class itself
+		for _, info := range c.Data.Fields {
+			if info.AccessFlags&0x0008 != 0 {
+				in.Statics[Ref{Class: c.Name, Name: info.Name.Value, Descriptor: info.Descriptor.Value}] = zero(info.Descriptor.Value)
+			}
+		}
+		if m == nil {
+			in.states[c.Name] = initialised
+			continue
+		}
+
+		in.states[c.Name] = initialising
+		f := newFrame(m)
+		f.initialiser = true
+		in.Frames = append(in.Frames, f)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// resolveField finds the field named by the Fieldref constant i of the pool of
+// class in the class it names, or else the nearest of its superclasses to
+// declare it, and reports whether it is static.
+func (in *Interpreter) resolveField(class *data.Class, i uint16) (Ref, bool, error) {
+	r, err := ref(class, i, data.CP_FIELDREF)
+	if err != nil {
+		return Ref{}, false, err
+	}
+
+	for name := r.Class; name != ""; {
+		c, err := in.classpath.Load(in.ctx, name)
+		if err != nil {
+			return Ref{}, false, err
+		}
+
+		for _, info := range c.Data.Fields {
+			if info.Name.Value == r.Name && info.Descriptor.Value == r.Descriptor {
+				return Ref{Class: c.Name, Name: r.Name, Descriptor: r.Descriptor}, info.AccessFlags&0x0008 != 0, nil
+			}
+		}
+
+		name = c.Super
+	}
+
+	return Ref{}, false, fmt.Errorf("no field %s in %s or its superclasses", r.Name+":"+r.Descriptor, r.Class)
+}
+
+// static runs getstatic and putstatic.
+func (in *Interpreter) static(f *Frame, op data.Op) error {
+	i, _ := op.ConstantIndex()
+	r, static, err := in.resolveField(f.Method.Class, i)
+	if err != nil {
+		return err
+	}
+	if !static {
+		return fmt.Errorf("field %s is not static", r)
+	}
+
+	if pending, err := in.initialise(r.Class); err != nil || pending {
+		return err
+	}
+
+	if op.Code == data.OP_GETSTATIC {
+		return f.push(in.Statics[r])
+	}
+
+	v, err := f.popKind(kindOf(r.Descriptor))
+	if err != nil {
+		return err
+	}
+	in.Statics[r] = v
+	in.write(Write{Ref: Null, Field: r, Value: v})
+	return nil
+}
+
+// field runs getfield and putfield.
+func (in *Interpreter) field(f *Frame, op data.Op) error {
+	i, _ := op.ConstantIndex()
+	r, static, err := in.resolveField(f.Method.Class, i)
+	if err != nil {
+		return err
+	}
+	if static {
+		return fmt.Errorf("field %s is static", r)
+	}
+
+	var v Value
+	if op.Code == data.OP_PUTFIELD {
+		if v, err = f.popKind(kindOf(r.Descriptor)); err != nil {
+			return err
+		}
+	}
+
+	ref, err := f.popKind(REFERENCE)
+	if err != nil {
+		return err
+	}
+
+	cell, err := in.Heap.Get(ref)
+	if err != nil {
+		return err
+	}
+	if cell == nil {
+		return Throw("java/lang/NullPointerException", "")
+	}
+
+	obj, ok := cell.(*Object)
+	if !ok {
+		return fmt.Errorf("expected an object with field %s, got %T", r, cell)
+	}
+	if _, ok := obj.Fields[r]; !ok {
+		return fmt.Errorf("%s has no field %s", obj.Class, r)
+	}
+
+	if op.Code == data.OP_GETFIELD {
+		return f.push(obj.Fields[r])
+	}
+	obj.Fields[r] = v
+	in.write(Write{Ref: ref, Field: r, Value: v})
+	return nil
+}
+
+func (in *Interpreter) new(f *Frame, op data.Op) error {
+	i, _ := op.ConstantIndex()
+	c, err := constant(f.Method.Class, i, data.CP_CLASS)
+	if err != nil {
+		return err
+	}
+
+	name, err := utf8(c.ConstantClass().Name)
+	if err != nil {
+		return err
+	}
+
+	if pending, err := in.initialise(name); err != nil || pending {
+		return err
+	}
+
+	fields, err := in.instanceFields(name)
+	if err != nil {
+		return err
+	}
+
+	return f.push(in.Heap.NewObject(name, fields...))
+}
+
+// instanceFields are the fields of instances of class, declared by it or its
+// superclasses.
+func (in *Interpreter) instanceFields(class string) ([]Ref, error) {
+	var fields []Ref
+	for name := class; name != "" && !builtin(name); {
+		c, err := in.classpath.Load(in.ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, info := range c.Data.Fields {
+			if info.AccessFlags&0x0008 == 0 {
+				fields = append(fields, Ref{Class: name, Name: info.Name.Value, Descriptor: info.Descriptor.Value})
+			}
+		}
+
+		name = c.Super
+	}
+	return fields, nil
+}
+
+// classObject is the instance of java/lang/Class for the class, the same
+// each time.
+func (in *Interpreter) classObject(class string) Value {
+	if v, ok := in.classes[class]; ok {
+		return v
+	}
+	v := in.Heap.NewObject("java/lang/Class")
+	in.classes[class] = v
+	return v
+}
@@ -0,0 +1,281 @@
+package interpreter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/internal/testutil"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+const point = `.class public super jpamb/cases/Point
+.super jpamb/cases/Shape
+
+.field static order I
+.field x I
+.field next Ljpamb/cases/Point;
+
+.method static <clinit>()V
+  .limit stack 2
+  .limit locals 0
+  getstatic jpamb/cases/Shape/order I
+  iconst_2
+  imul
+  iconst_2
+  iadd
+  putstatic jpamb/cases/Point/order I
+  return
+.end method
+
+.method public <init>(I)V
+  .limit stack 2
+  .limit locals 2
+  aload_0
+  invokespecial jpamb/cases/Shape/<init>()V
+  aload_0
+  iload_1
+  putfield jpamb/cases/Point/x I
+  return
+.end method
+
+.method public static order()I
+  .limit stack 1
+  .limit locals 0
+  getstatic jpamb/cases/Point/order I
+  ireturn
+.end method
+
+.method public static fields()I
+  .limit stack 3
+  .limit locals 1
+  new jpamb/cases/Point
+  dup
+  bipush 7
+  invokespecial jpamb/cases/Point/<init>(I)V
+  astore_0
+  aload_0
+  getfield jpamb/cases/Point/sides I
+  aload_0
+  getfield jpamb/cases/Point/x I
+  iadd
+  ireturn
+.end method
+
+.method public static chain()Z
+  .limit stack 3
+  .limit locals 2
+  new jpamb/cases/Point
+  dup
+  iconst_1
+  invokespecial jpamb/cases/Point/<init>(I)V
+  astore_0
+  aload_0
+  getfield jpamb/cases/Point/next Ljpamb/cases/Point;
+  ifnonnull L30
+  aload_0
+  aload_0
+  putfield jpamb/cases/Point/next Ljpamb/cases/Point;
+  aload_0
+  getfield jpamb/cases/Point/next Ljpamb/cases/Point;
+  aload_0
+  if_acmpne L30
+  iconst_1
+  ireturn
+L30:
+  iconst_0
+  ireturn
+.end method
+
+.method public static points()Ljpamb/cases/Point;
+  .limit stack 5
+  .limit locals 1
+  iconst_2
+  anewarray jpamb/cases/Point
+  astore_0
+  aload_0
+  iconst_1
+  new jpamb/cases/Point
+  dup
+  iconst_3
+  invokespecial jpamb/cases/Point/<init>(I)V
+  aastore
+  aload_0
+  iconst_1
+  aaload
+  areturn
+.end method
+
+.method public static bytes()I
+  .limit stack 4
+  .limit locals 1
+  iconst_1
+  newarray byte
+  astore_0
+  aload_0
+  iconst_0
+  sipush 200
+  bastore
+  aload_0
+  iconst_0
+  baload
+  ireturn
+.end method
+
+.method public static onNull()I
+  .limit stack 1
+  .limit locals 0
+  aconst_null
+  getfield jpamb/cases/Point/x I
+  ireturn
+.end method
+`
+
+const shape = `.class public super jpamb/cases/Shape
+
+.field static order I
+.field sides I
+
+.method static <clinit>()V
+  .limit stack 1
+  .limit locals 0
+  iconst_1
+  putstatic jpamb/cases/Shape/order I
+  return
+.end method
+
+.method public <init>()V
+  .limit stack 2
+  .limit locals 1
+  aload_0
+  invokespecial java/lang/Object/<init>()V
+  aload_0
+  iconst_4
+  putfield jpamb/cases/Shape/sides I
+  return
+.end method
+`
+
+func TestHeap(t *testing.T) {
+	cp, _ := testutil.Classpath(t, point, shape)
+
+	run := func(name string) (*interpreter.Interpreter, interpreter.Termination) {
+		c, _ := cp.Load(t.Context(), "jpamb/cases/Point")
+		m, err := c.Method(data.Selector{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		in := interpreter.New(interpreter.WithClasspath(cp))
+		if err := in.Start(m, nil); err != nil {
+			t.Fatal(err)
+		}
+		got, err := in.Execute(1000)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return in, got
+	}
+
+	// Shape is initialised first, and Point reads what it set
+	if in, got := run("order"); got.Result != interpreter.Int(4) || in.Statics[interpreter.Ref{"jpamb/cases/Shape", "order", "I"}] != interpreter.Int(1) {
+		t.Errorf("order() = %v, statics %v", got.Result, in.Statics)
+	}
+
+	if _, got := run("fields"); got.Result != interpreter.Int(11) {
+		t.Errorf("fields() = %v, want the inherited sides and x", got.Result)
+	}
+
+	if _, got := run("chain"); got.Result != interpreter.Int(1) {
+		t.Errorf("chain() = %v", got.Result)
+	}
+
+	in, got := run("points")
+	if cell, err := in.Heap.Get(got.Result); err != nil || cell.(*interpreter.Object).String() != "jpamb/cases/Point{next=null, x=3, sides=4}" {
+		t.Errorf("points() = %v, %v", cell, err)
+	}
+
+	if _, got := run("bytes"); got.Result != interpreter.Int(-56) {
+		t.Errorf("bytes() = %v, want 200 truncated to a byte", got.Result)
+	}
+
+	if _, got := run("onNull"); got.Outcome != interpreter.NULL_POINTER {
+		t.Errorf("onNull() = %v", got)
+	}
+}
+
+const broken = `.class public super jpamb/cases/Broken
+
+.field static s I
+
+.method static <clinit>()V
+  .limit stack 2
+  .limit locals 0
+  iconst_1
+  iconst_0
+  idiv
+  putstatic jpamb/cases/Broken/s I
+  return
+.end method
+
+.method public static divide()I
+  .limit stack 1
+  .limit locals 1
+L0:
+  getstatic jpamb/cases/Broken/s I
+  ireturn
+L4:
+  astore_0
+  getstatic jpamb/cases/Broken/s I
+  ireturn
+  .catch java/lang/ArithmeticException from L0 to L4 using L4
+.end method
+
+.method public static again()I
+  .limit stack 1
+  .limit locals 1
+L0:
+  getstatic jpamb/cases/Broken/s I
+  ireturn
+L4:
+  astore_0
+  getstatic jpamb/cases/Broken/s I
+  ireturn
+  .catch java/lang/ExceptionInInitializerError from L0 to L4 using L4
+.end method
+`
+
+func TestFailedInitialisation(t *testing.T) {
+	cp, classes := testutil.Classpath(t, broken)
+
+	run := func(name string) *interpreter.Exception {
+		m, err := classes[0].Method(data.Selector{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		in := interpreter.New(interpreter.WithClasspath(cp))
+		if err := in.Start(m, nil); err != nil {
+			t.Fatal(err)
+		}
+		got, err := in.Execute(1000)
+		if got.Thrown == nil {
+			t.Fatalf("%s() = %v, %v, want an exception", name, got, err)
+		}
+		return got.Thrown
+	}
+
+	// the ArithmeticException of <clinit> does not reach the handler
+	e := run("divide")
+	if e.Class != "java/lang/ExceptionInInitializerError" || e.Cause == nil || e.Cause.Class != "java/lang/ArithmeticException" {
+		t.Errorf("divide() threw %v caused by %v", e, e.Cause)
+	}
+	if !strings.Contains(e.StackTrace(), "Caused by: java.lang.ArithmeticException") {
+		t.Errorf("stack trace lacks the cause:\n%s", e.StackTrace())
+	}
+
+	// once it failed, the class cannot be used
+	if e := run("again"); e.Class != "java/lang/NoClassDefFoundError" || e.Message != "Could not initialize class jpamb.cases.Broken" {
+		t.Errorf("again() threw %v", e)
+	}
+}
@@ -0,0 +1,48 @@
+package interpreter
+
+import "github.com/luishfonseca/dtu_pa/data"
+
+// Observer is notified of the instructions an Interpreter runs, and of the
+// writes to the heap and static fields they make, e.g. to record a trace of
+// the run. It is called from Step, before and after the instruction runs.
+type Observer interface {
+	// Before op, the instruction at the pc of f, runs.
+	Before(in *Interpreter, f *Frame, op data.Op)
+	// Write made by the instruction running, or a Native it invoked.
+	Write(w Write)
+	// After op has run, with the error Step returns. The pc of f, if it is
+	// still on the call stack, is the next to run in it.
+	After(in *Interpreter, f *Frame, op data.Op, err error)
+}
+
+// Write of Value to element Index of the array at Ref, or to Field of the
+// object at Ref, or of its class if Ref is null.
+type Write struct {
+	Ref   Value
+	Field Ref // zero for elements
+	Index int32
+	Value Value
+}
+
+func (w Write) String() string {
+	switch {
+	case w.Field == Ref{}:
+		return w.Ref.String() + "[" + Int(w.Index).String() + "] = " + w.Value.String()
+	case w.Ref.IsNull():
+		return w.Field.String() + " = " + w.Value.String()
+	default:
+		return w.Ref.String() + "." + w.Field.String() + " = " + w.Value.String()
+	}
+}
+
+func (in *Interpreter) write(w Write) {
+	for _, o := range in.observers {
+		o.Write(w)
+	}
+}
+
+// store v in element i of a, the array at ref.
+func (in *Interpreter) store(ref Value, a *Array, i int32, v int32) {
+	a.Elems[i] = v
+	in.write(Write{Ref: ref, Index: i, Value: a.Get(i)})
+}
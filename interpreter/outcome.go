@@ -0,0 +1,93 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Outcome is how a run of a method ends, in the categories of the course
+// benchmark.
+type Outcome int
+
+const (
+	OK Outcome = iota
+	ASSERTION_ERROR
+	DIVIDE_BY_ZERO
+	OUT_OF_BOUNDS
+	NULL_POINTER
+	NON_TERMINATION // the step budget ran out, or the stack overflowed
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OK:
+		return "ok"
+	case ASSERTION_ERROR:
+		return "assertion error"
+	case DIVIDE_BY_ZERO:
+		return "divide by zero"
+	case OUT_OF_BOUNDS:
+		return "out of bounds"
+	case NULL_POINTER:
+		return "null pointer"
+	case NON_TERMINATION:
+		return "*"
+	default:
+		return fmt.Sprintf("Outcome(%d)", int(o))
+	}
+}
+
+// outcomes of the exceptions that have one.
+var outcomes = map[string]Outcome{
+	"java/lang/AssertionError":                 ASSERTION_ERROR,
+	"java/lang/ArithmeticException":            DIVIDE_BY_ZERO,
+	"java/lang/ArrayIndexOutOfBoundsException": OUT_OF_BOUNDS,
+	"java/lang/NullPointerException":           NULL_POINTER,
+	"java/lang/StackOverflowError":             NON_TERMINATION, // of runaway recursion
+}
+
+// Termination is how and where a run ended.
+type Termination struct {
+	Outcome Outcome
+	PC      int        // of the return, the throw, or the next instruction when out of steps
+	Result  Value      // returned, when OK
+	Thrown  *Exception // when it ended with an exception
+}
+
+func (t Termination) String() string {
+	return fmt.Sprintf("%s at %d", t.Outcome, t.PC)
+}
+
+// Classify is the termination of a run from the error Run returned. Errors
+// that are not an uncaught exception with an outcome or ErrBudget are
+// returned, with the exception in the termination if there was one.
+func (in *Interpreter) Classify(err error) (Termination, error) {
+	t := Termination{PC: in.Frame().PC}
+
+	var e *Exception
+	switch {
+	case err == nil && in.Done:
+		t.Outcome, t.Result = OK, in.Result
+	case errors.Is(err, ErrBudget):
+		t.Outcome = NON_TERMINATION
+	case errors.As(err, &e):
+		t.PC, t.Thrown = e.PC, e
+		o, ok := outcomes[e.Class]
+		if !ok {
+			return t, fmt.Errorf("uncaught %w has no outcome", e)
+		}
+		t.Outcome = o
+	case err != nil:
+		return t, err
+	default:
+		return t, fmt.Errorf("method has not returned")
+	}
+
+	return t, nil
+}
+
+// Execute runs the started method to its termination, taking at most
+// maxSteps instructions before it is deemed not to terminate.
+func (in *Interpreter) Execute(maxSteps int) (Termination, error) {
+	return in.Classify(in.Run(maxSteps))
+}
@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// constant i of the pool of class, which must have tag.
+func constant(class *data.Class, i uint16, tag data.Tag) (data.Data, error) {
+	pool := class.ConstantPool
+	if i == 0 || int(i) > len(pool) || pool[i-1] == nil {
+		return nil, fmt.Errorf("constant index %d out of range", i)
+	}
+	if c := pool[i-1]; c.Tag() != tag {
+		return nil, fmt.Errorf("constant #%d is a %s, expected a %s", i, c.Tag(), tag)
+	}
+	return pool[i-1], nil
+}
+
+// utf8 is the string r points to.
+func utf8(r *data.Data) (string, error) {
+	if r == nil || *r == nil || (*r).Tag() != data.CP_UTF8 {
+		return "", fmt.Errorf("expected a Utf8 constant")
+	}
+	return (*r).ConstantUtf8().Value, nil
+}
+
+// className is the internal name of the class constant r points to.
+func className(r *data.Data) (string, error) {
+	if r == nil || *r == nil || (*r).Tag() != data.CP_CLASS {
+		return "", fmt.Errorf("expected a Class constant")
+	}
+	return utf8((*r).ConstantClass().Name)
+}
+
+// Ref is a field or method as named by a Fieldref, Methodref or
+// InterfaceMethodref constant.
+type Ref struct {
+	Class      string // internal name
+	Name       string
+	Descriptor string
+}
+
+func (r Ref) String() string {
+	return r.Class + "." + r.Name + ":" + r.Descriptor
+}
+
+// ref resolves the Fieldref, Methodref or InterfaceMethodref constant i of the
+// pool of class.
+func ref(class *data.Class, i uint16, tag data.Tag) (Ref, error) {
+	c, err := constant(class, i, tag)
+	if err != nil {
+		return Ref{}, err
+	}
+
+	var clazz, nat *data.Data
+	switch tag {
+	case data.CP_FIELDREF:
+		clazz, nat = c.ConstantFieldref().Clazz, c.ConstantFieldref().NameAndType
+	case data.CP_METHODREF:
+		clazz, nat = c.ConstantMethodref().Clazz, c.ConstantMethodref().NameAndType
+	case data.CP_INTERFACE_METHODREF:
+		clazz, nat = c.ConstantInterfaceMethodref().Clazz, c.ConstantInterfaceMethodref().NameAndType
+	}
+
+	var r Ref
+	if r.Class, err = className(clazz); err != nil {
+		return Ref{}, err
+	}
+
+	if nat == nil || *nat == nil || (*nat).Tag() != data.CP_NAME_AND_TYPE {
+		return Ref{}, fmt.Errorf("expected a NameAndType constant")
+	}
+	if r.Name, err = utf8((*nat).ConstantNameAndType().Name); err != nil {
+		return Ref{}, err
+	}
+	if r.Descriptor, err = utf8((*nat).ConstantNameAndType().Descriptor); err != nil {
+		return Ref{}, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,72 @@
+package interpreter
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type Kind int
+
+const (
+	VOID      Kind = iota // no value: locals not yet stored, results of void methods
+	INT                   // also booleans, bytes, chars and shorts
+	REFERENCE             // address on the heap, 0 for null
+)
+
+func (k Kind) String() string {
+	switch k {
+	case VOID:
+		return "void"
+	case INT:
+		return "int"
+	case REFERENCE:
+		return "reference"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// kindOf the values of the field descriptor desc, or VOID for V.
+func kindOf(desc string) Kind {
+	switch desc[0] {
+	case 'Z', 'B', 'C', 'S', 'I':
+		return INT
+	case 'L', '[':
+		return REFERENCE
+	default:
+		return VOID
+	}
+}
+
+// Value is what the operand stack and local variables hold.
+type Value struct {
+	Kind Kind
+	V    int32 // the int, or the address of a reference
+}
+
+// Null is the null reference.
+var Null = Value{Kind: REFERENCE}
+
+func Int(v int32) Value { return Value{Kind: INT, V: v} }
+
+func Bool(b bool) Value {
+	if b {
+		return Int(1)
+	}
+	return Int(0)
+}
+
+func (v Value) IsNull() bool { return v.Kind == REFERENCE && v.V == 0 }
+
+func (v Value) String() string {
+	switch {
+	case v.Kind == VOID:
+		return "void"
+	case v.Kind == INT:
+		return strconv.Itoa(int(v.V))
+	case v.IsNull():
+		return "null"
+	default:
+		return "@" + strconv.Itoa(int(v.V))
+	}
+}
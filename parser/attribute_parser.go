@@ -2,7 +2,6 @@ package parser
 
 import (
 	"fmt"
-	"io"
 
 	"github.com/luishfonseca/dtu_pa/data"
 	"github.com/luishfonseca/dtu_pa/state"
@@ -10,100 +9,483 @@ import (
 
 func attribute(attr data.AttributeHandle) state.Fn[*Parser] {
 	return func(p *Parser) state.Fn[*Parser] {
-		// seek to attribute position
-		if _, err := p.input.Seek(attr.Begin, io.SeekStart); err != nil {
+		d, err := parseAttributeContent(p, attr)
+		if err != nil {
 			return state.Fail[*Parser](err)
 		}
 
-		switch attr.AttributeTag {
-		case data.ATTR_CODE:
-			return attributeCode(attr)
-		default:
-			return state.Fail[*Parser](fmt.Errorf("unimplemented attribute: %s", attr.AttributeTag))
+		p.attributes[attr] = d
+
+		if err := p.send(p.attributes[attr]); err != nil {
+			return state.Fail[*Parser](err)
 		}
+
+		return state.Goto(p, waitReq)
 	}
 }
 
-func attributeCode(attr data.AttributeHandle) state.Fn[*Parser] {
-	return func(p *Parser) state.Fn[*Parser] {
-		var maxStack uint16
-		if err := p.readDecode(&maxStack); err != nil {
-			return state.Fail[*Parser](err)
+// maxElementValueDepth bounds the nesting of annotation element values.
+const maxElementValueDepth = 64
+
+// parseAttributeContent parses the contents of attr, which must span exactly
+// the length of the attribute.
+func parseAttributeContent(p *Parser, attr data.AttributeHandle) (data.Data, error) {
+	p.at("%s", p.labels[attr.Begin])
+
+	// seek to attribute position
+	if err := p.seek(attr.Begin); err != nil {
+		return nil, err
+	}
+
+	var d data.Data
+	var err error
+
+	switch attr.AttributeTag {
+	case data.ATTR_CODE:
+		d, err = parseCode(p, attr)
+	case data.ATTR_SOURCE_FILE:
+		d, err = parseSourceFile(p)
+	case data.ATTR_RUNTIME_VISIBLE_ANNOTATIONS:
+		d, err = parseRuntimeVisibleAnnotations(p)
+	case data.ATTR_INNER_CLASSES:
+		d, err = parseInnerClasses(p)
+	case data.ATTR_LINE_NUMBER_TABLE:
+		d, err = parseLineNumberTable(p)
+	case data.ATTR_LOCAL_VARIABLE_TABLE:
+		d, err = parseLocalVariableTable(p)
+	case data.ATTR_STACK_MAP_TABLE:
+		d, err = parseStackMapTable(p)
+	default:
+		return nil, fmt.Errorf("unimplemented attribute: %s", attr.AttributeTag)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if end := attr.Begin + int64(attr.Length); p.offset != end {
+		return nil, fmt.Errorf("attribute content ends at offset %d, expected %d", p.offset, end)
+	}
+
+	return d, nil
+}
+
+// parseCode parses the contents of the Code attribute attr, assuming the input
+// is already positioned at its beginning.
+func parseCode(p *Parser, attr data.AttributeHandle) (*data.AttributeCode, error) {
+	var maxStack uint16
+	if err := p.readDecode(&maxStack); err != nil {
+		return nil, err
+	}
+
+	var maxLocals uint16
+	if err := p.readDecode(&maxLocals); err != nil {
+		return nil, err
+	}
+
+	var codeHandle data.BytecodeHandle
+	if err := p.readDecode(&codeHandle.Length); err != nil {
+		return nil, err
+	}
+
+	if err := p.limits.checkCodeLength(codeHandle.Length); err != nil {
+		return nil, err
+	}
+
+	if begin, err := p.skip(int64(codeHandle.Length)); err != nil {
+		return nil, err
+	} else {
+		codeHandle.Begin = begin
+		p.labels[begin] = p.labels[attr.Begin] + " code"
+	}
+
+	var n uint16
+	if err := p.readDecode(&n); err != nil {
+		return nil, err
+	}
+
+	exceptionTable := make([]data.ExceptionTableEntry, n)
+	for i := range n {
+		p.at("%s exception table entry %d", p.labels[attr.Begin], i)
+
+		if err := p.readDecode(&exceptionTable[i].StartPC); err != nil {
+			return nil, err
 		}
 
-		var maxLocals uint16
-		if err := p.readDecode(&maxLocals); err != nil {
-			return state.Fail[*Parser](err)
+		if err := p.readDecode(&exceptionTable[i].EndPC); err != nil {
+			return nil, err
 		}
 
-		var codeHandle data.BytecodeHandle
-		if err := p.readDecode(&codeHandle.Length); err != nil {
-			return state.Fail[*Parser](err)
+		if err := p.readDecode(&exceptionTable[i].HandlerPC); err != nil {
+			return nil, err
 		}
 
-		if begin, err := p.input.Seek(0, io.SeekCurrent); err != nil {
-			return state.Fail[*Parser](err)
-		} else {
-			codeHandle.Begin = begin
+		var idx uint16
+		if err := p.readDecode(&idx); err != nil {
+			return nil, err
 		}
 
-		if _, err := p.input.Seek(int64(codeHandle.Length), io.SeekCurrent); err != nil {
-			return state.Fail[*Parser](err)
+		if idx != 0 {
+			if c, err := p.constantOf(idx, data.CP_CLASS); err != nil {
+				return nil, err
+			} else {
+				exceptionTable[i].CatchType = c.ConstantClass()
+			}
+		}
+	}
+
+	p.at("%s attributes count", p.labels[attr.Begin])
+	if err := p.readDecode(&n); err != nil {
+		return nil, err
+	}
+
+	owner := p.labels[attr.Begin]
+	attrs := make([]data.AttributeHandle, 0, n)
+	for range n {
+		if attr, err := parseAttribute(p, owner); err != nil {
+			return nil, err
+		} else if attr != nil {
+			attrs = append(attrs, *attr)
 		}
+	}
 
-		var n uint16
-		if err := p.readDecode(&n); err != nil {
-			return state.Fail[*Parser](err)
+	return &data.AttributeCode{
+		MaxStack:       maxStack,
+		MaxLocals:      maxLocals,
+		CodeHandle:     codeHandle,
+		ExceptionTable: exceptionTable,
+		Attributes:     attrs,
+	}, nil
+}
+
+func parseSourceFile(p *Parser) (*data.AttributeSourceFile, error) {
+	var idx uint16
+	if err := p.readDecode(&idx); err != nil {
+		return nil, err
+	}
+
+	name, err := p.utf8(idx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.AttributeSourceFile{SourceFile: name}, nil
+}
+
+func parseLineNumberTable(p *Parser) (*data.AttributeLineNumberTable, error) {
+	var n uint16
+	if err := p.readDecode(&n); err != nil {
+		return nil, err
+	}
+
+	table := &data.AttributeLineNumberTable{LineNumbers: make([]data.LineNumber, n)}
+	for i := range n {
+		if err := p.readDecode(&table.LineNumbers[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return table, nil
+}
+
+func parseLocalVariableTable(p *Parser) (*data.AttributeLocalVariableTable, error) {
+	var n uint16
+	if err := p.readDecode(&n); err != nil {
+		return nil, err
+	}
+
+	table := &data.AttributeLocalVariableTable{LocalVariables: make([]data.LocalVariable, n)}
+	for i := range n {
+		var entry struct{ StartPC, Length, Name, Descriptor, Index uint16 }
+		if err := p.readDecode(&entry); err != nil {
+			return nil, err
+		}
+
+		name, err := p.utf8(entry.Name, false)
+		if err != nil {
+			return nil, err
+		}
+
+		descriptor, err := p.utf8(entry.Descriptor, false)
+		if err != nil {
+			return nil, err
+		}
+
+		table.LocalVariables[i] = data.LocalVariable{
+			StartPC:    entry.StartPC,
+			Length:     entry.Length,
+			Name:       name,
+			Descriptor: descriptor,
+			Index:      entry.Index,
+		}
+	}
+
+	return table, nil
+}
+
+func parseInnerClasses(p *Parser) (*data.AttributeInnerClasses, error) {
+	var n uint16
+	if err := p.readDecode(&n); err != nil {
+		return nil, err
+	}
+
+	attr := &data.AttributeInnerClasses{Classes: make([]data.InnerClass, n)}
+	for i := range n {
+		var entry struct{ Inner, Outer, Name, AccessFlags uint16 }
+		if err := p.readDecode(&entry); err != nil {
+			return nil, err
+		}
+
+		inner, err := p.classConstant(entry.Inner, false)
+		if err != nil {
+			return nil, err
+		}
+
+		outer, err := p.classConstant(entry.Outer, true)
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := p.utf8(entry.Name, true)
+		if err != nil {
+			return nil, err
+		}
+
+		attr.Classes[i] = data.InnerClass{
+			InnerClass:  inner,
+			OuterClass:  outer,
+			InnerName:   name,
+			AccessFlags: data.AccessFlags(entry.AccessFlags),
+		}
+	}
+
+	return attr, nil
+}
+
+func parseStackMapTable(p *Parser) (*data.AttributeStackMapTable, error) {
+	var n uint16
+	if err := p.readDecode(&n); err != nil {
+		return nil, err
+	}
+
+	attr := &data.AttributeStackMapTable{Frames: make([]data.StackMapFrame, n)}
+	for i := range n {
+		frame := &attr.Frames[i]
+		if err := p.readDecode(&frame.FrameType); err != nil {
+			return nil, err
 		}
 
-		exceptionTable := make([]data.ExceptionTableEntry, n)
-		for i := range n {
-			if err := p.readDecode(&exceptionTable[i].StartPC); err != nil {
-				return state.Fail[*Parser](err)
+		var nLocals, nStack uint16
+		switch t := frame.FrameType; {
+		case t <= 63: // same_frame
+			frame.OffsetDelta = uint16(t)
+		case t <= 127: // same_locals_1_stack_item_frame
+			frame.OffsetDelta = uint16(t - 64)
+			nStack = 1
+		case t < 247:
+			return nil, fmt.Errorf("reserved stack map frame type %d", t)
+		default:
+			if err := p.readDecode(&frame.OffsetDelta); err != nil {
+				return nil, err
 			}
 
-			if err := p.readDecode(&exceptionTable[i].EndPC); err != nil {
-				return state.Fail[*Parser](err)
+			switch {
+			case t == 247: // same_locals_1_stack_item_frame_extended
+				nStack = 1
+			case t <= 251: // chop_frame and same_frame_extended
+			case t <= 254: // append_frame
+				nLocals = uint16(t - 251)
+			default: // full_frame
+				if err := p.readDecode(&nLocals); err != nil {
+					return nil, err
+				}
+
+				locals, err := parseVerificationTypes(p, nLocals)
+				if err != nil {
+					return nil, err
+				}
+				frame.Locals = locals
+
+				nLocals = 0
+				if err := p.readDecode(&nStack); err != nil {
+					return nil, err
+				}
 			}
+		}
 
-			if err := p.readDecode(&exceptionTable[i].HandlerPC); err != nil {
-				return state.Fail[*Parser](err)
+		if nLocals > 0 {
+			locals, err := parseVerificationTypes(p, nLocals)
+			if err != nil {
+				return nil, err
 			}
+			frame.Locals = locals
+		}
 
+		if nStack > 0 {
+			stack, err := parseVerificationTypes(p, nStack)
+			if err != nil {
+				return nil, err
+			}
+			frame.Stack = stack
+		}
+	}
+
+	return attr, nil
+}
+
+func parseVerificationTypes(p *Parser, n uint16) ([]data.VerificationType, error) {
+	types := make([]data.VerificationType, 0, min(n, 256))
+	for range n {
+		var vt data.VerificationType
+		if err := p.readDecode(&vt.Tag); err != nil {
+			return nil, err
+		}
+
+		switch vt.Tag {
+		case data.VT_OBJECT:
 			var idx uint16
 			if err := p.readDecode(&idx); err != nil {
-				return state.Fail[*Parser](err)
+				return nil, err
 			}
 
-			if idx != 0 {
-				fmt.Println("CatchType index:", idx)
-				exceptionTable[i].CatchType = p.class.ConstantPool[idx-1].ConstantClass()
+			class, err := p.classConstant(idx, false)
+			if err != nil {
+				return nil, err
+			}
+			vt.Class = class
+		case data.VT_UNINITIALIZED:
+			if err := p.readDecode(&vt.Offset); err != nil {
+				return nil, err
+			}
+		default:
+			if vt.Tag > data.VT_UNINITIALIZED {
+				return nil, fmt.Errorf("unknown verification type %d", vt.Tag)
 			}
 		}
 
-		if err := p.readDecode(&n); err != nil {
-			return state.Fail[*Parser](err)
+		types = append(types, vt)
+	}
+
+	return types, nil
+}
+
+func parseRuntimeVisibleAnnotations(p *Parser) (*data.AttributeRuntimeVisibleAnnotations, error) {
+	var n uint16
+	if err := p.readDecode(&n); err != nil {
+		return nil, err
+	}
+
+	attr := &data.AttributeRuntimeVisibleAnnotations{Annotations: make([]data.Annotation, n)}
+	for i := range n {
+		if err := parseAnnotation(p, &attr.Annotations[i], 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return attr, nil
+}
+
+func parseAnnotation(p *Parser, a *data.Annotation, depth int) error {
+	var idx, n uint16
+	if err := p.readDecode(&idx); err != nil {
+		return err
+	}
+
+	typ, err := p.utf8(idx, false)
+	if err != nil {
+		return err
+	}
+	a.Type = typ
+
+	if err := p.readDecode(&n); err != nil {
+		return err
+	}
+
+	a.Elements = make([]data.ElementValuePair, 0, min(n, 256))
+	for range n {
+		var pair data.ElementValuePair
+		if err := p.readDecode(&idx); err != nil {
+			return err
 		}
 
-		attrs := make([]data.AttributeHandle, n)
-		for i := range n {
-			if attr, err := parseAttribute(p); err != nil {
-				return state.Fail[*Parser](err)
-			} else {
-				attrs[i] = *attr
-			}
+		if pair.Name, err = p.utf8(idx, false); err != nil {
+			return err
 		}
 
-		p.attributes[attr] = &data.AttributeCode{
-			MaxStack:       maxStack,
-			MaxLocals:      maxLocals,
-			CodeHandle:     codeHandle,
-			ExceptionTable: exceptionTable,
-			Attributes:     attrs,
+		if err := parseElementValue(p, &pair.Value, depth); err != nil {
+			return err
 		}
 
-		p.dataCh <- p.attributes[attr]
+		a.Elements = append(a.Elements, pair)
+	}
+
+	return nil
+}
+
+func parseElementValue(p *Parser, e *data.ElementValue, depth int) error {
+	if depth > maxElementValueDepth {
+		return fmt.Errorf("annotation element values nested deeper than %d", maxElementValueDepth)
+	}
 
-		return waitReq
+	if err := p.readDecode(&e.Tag); err != nil {
+		return err
 	}
+
+	var idx uint16
+	var err error
+
+	switch e.Tag {
+	case 'B', 'C', 'I', 'S', 'Z', 'D', 'F', 'J', 's':
+		tag := map[byte]data.Tag{'D': data.CP_DOUBLE, 'F': data.CP_FLOAT, 'J': data.CP_LONG, 's': data.CP_UTF8}[e.Tag]
+		if tag == data.UNKNOWN {
+			tag = data.CP_INTEGER
+		}
+
+		if err := p.readDecode(&idx); err != nil {
+			return err
+		}
+
+		e.Const, err = p.constantOf(idx, tag)
+	case 'e':
+		if err := p.readDecode(&idx); err != nil {
+			return err
+		}
+
+		if e.EnumType, err = p.utf8(idx, false); err != nil {
+			return err
+		}
+
+		if err := p.readDecode(&idx); err != nil {
+			return err
+		}
+
+		e.EnumName, err = p.utf8(idx, false)
+	case 'c':
+		if err := p.readDecode(&idx); err != nil {
+			return err
+		}
+
+		e.Class, err = p.utf8(idx, false)
+	case '@':
+		e.Annotation = &data.Annotation{}
+		err = parseAnnotation(p, e.Annotation, depth+1)
+	case '[':
+		var n uint16
+		if err := p.readDecode(&n); err != nil {
+			return err
+		}
+
+		e.Values = make([]data.ElementValue, 0, min(n, 256))
+		for range n {
+			var v data.ElementValue
+			if err := parseElementValue(p, &v, depth+1); err != nil {
+				return err
+			}
+			e.Values = append(e.Values, v)
+		}
+	default:
+		return fmt.Errorf("unsupported element value tag %q", e.Tag)
+	}
+
+	return err
 }
@@ -8,6 +8,8 @@ import (
 )
 
 func magic(p *Parser) state.Fn[*Parser] {
+	p.at("magic")
+
 	b, err := p.read(4)
 	if err != nil {
 		return state.Fail[*Parser](err)
@@ -17,10 +19,12 @@ func magic(p *Parser) state.Fn[*Parser] {
 		return state.Fail[*Parser](fmt.Errorf("invalid magic number: %x", b))
 	}
 
-	return version
+	return state.Goto(p, version)
 }
 
 func version(p *Parser) state.Fn[*Parser] {
+	p.at("version")
+
 	var m, M uint16
 
 	if err := p.readDecode(&m); err != nil {
@@ -33,19 +37,32 @@ func version(p *Parser) state.Fn[*Parser] {
 
 	p.class.Version = fmt.Sprintf("%d.%d", M, m)
 
-	return constantPool
+	return state.Goto(p, constantPool)
 }
 
 func constantPool(p *Parser) state.Fn[*Parser] {
+	p.at("constant pool count")
+
 	var n uint16
 	if err := p.readDecode(&n); err != nil {
 		return state.Fail[*Parser](err)
 	}
 
+	if n == 0 {
+		return state.Fail[*Parser](fmt.Errorf("constant pool count must be at least 1"))
+	}
+
+	if err := p.limits.checkConstantPool(n); err != nil {
+		return state.Fail[*Parser](err)
+	}
+
 	// The constant_pool table is indexed from 1 to constant_pool_count-1
 	p.class.ConstantPool = make([]data.Data, n-1)
 
-	for i := range n - 1 {
+	var err error
+	for i := uint16(0); i < n-1; i++ {
+		p.at("constant pool entry %d", i+1)
+
 		var tag uint8
 		if err := p.readDecode(&tag); err != nil {
 			return state.Fail[*Parser](err)
@@ -75,6 +92,34 @@ func constantPool(p *Parser) state.Fn[*Parser] {
 			}
 
 			p.class.ConstantPool[i] = info
+		case 4: // CONSTANT_Float
+			info := &data.ConstantFloat{}
+
+			if err := p.readDecode(&info.Value); err != nil {
+				return state.Fail[*Parser](err)
+			}
+
+			p.class.ConstantPool[i] = info
+		case 5, 6: // CONSTANT_Long and CONSTANT_Double, which take up two slots
+			if i+1 >= n-1 {
+				return state.Fail[*Parser](fmt.Errorf("8-byte constant in the last slot of the constant pool"))
+			}
+
+			if tag == 5 {
+				info := &data.ConstantLong{}
+				if err := p.readDecode(&info.Value); err != nil {
+					return state.Fail[*Parser](err)
+				}
+				p.class.ConstantPool[i] = info
+			} else {
+				info := &data.ConstantDouble{}
+				if err := p.readDecode(&info.Value); err != nil {
+					return state.Fail[*Parser](err)
+				}
+				p.class.ConstantPool[i] = info
+			}
+
+			i++
 		case 7: // CONSTANT_Class
 			info := &data.ConstantClass{}
 
@@ -83,7 +128,21 @@ func constantPool(p *Parser) state.Fn[*Parser] {
 				return state.Fail[*Parser](err)
 			}
 
-			info.Name = &p.class.ConstantPool[cpIndex-1]
+			if info.Name, err = p.constant(cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
+			p.class.ConstantPool[i] = info
+		case 8: // CONSTANT_String
+			info := &data.ConstantString{}
+
+			var cpIndex uint16
+			if err := p.readDecode(&cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
+
+			if info.Value, err = p.constant(cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
 			p.class.ConstantPool[i] = info
 		case 9: // CONSTANT_Fieldref
 			info := &data.ConstantFieldref{}
@@ -93,13 +152,17 @@ func constantPool(p *Parser) state.Fn[*Parser] {
 				return state.Fail[*Parser](err)
 			}
 
-			info.Clazz = &p.class.ConstantPool[cpIndex-1]
+			if info.Clazz, err = p.constant(cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
 
 			if err := p.readDecode(&cpIndex); err != nil {
 				return state.Fail[*Parser](err)
 			}
 
-			info.NameAndType = &p.class.ConstantPool[cpIndex-1]
+			if info.NameAndType, err = p.constant(cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
 			p.class.ConstantPool[i] = info
 		case 10: // CONSTANT_Methodref
 			info := &data.ConstantMethodref{}
@@ -109,13 +172,37 @@ func constantPool(p *Parser) state.Fn[*Parser] {
 				return state.Fail[*Parser](err)
 			}
 
-			info.Clazz = &p.class.ConstantPool[cpIndex-1]
+			if info.Clazz, err = p.constant(cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
 
 			if err := p.readDecode(&cpIndex); err != nil {
 				return state.Fail[*Parser](err)
 			}
 
-			info.NameAndType = &p.class.ConstantPool[cpIndex-1]
+			if info.NameAndType, err = p.constant(cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
+			p.class.ConstantPool[i] = info
+		case 11: // CONSTANT_InterfaceMethodref
+			info := &data.ConstantInterfaceMethodref{}
+
+			var cpIndex uint16
+			if err := p.readDecode(&cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
+
+			if info.Clazz, err = p.constant(cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
+
+			if err := p.readDecode(&cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
+
+			if info.NameAndType, err = p.constant(cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
 			p.class.ConstantPool[i] = info
 		case 12: // CONSTANT_NameAndType
 			info := &data.ConstantNameAndType{}
@@ -125,102 +212,209 @@ func constantPool(p *Parser) state.Fn[*Parser] {
 				return state.Fail[*Parser](err)
 			}
 
-			info.Name = &p.class.ConstantPool[cpIndex-1]
+			if info.Name, err = p.constant(cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
 
 			if err := p.readDecode(&cpIndex); err != nil {
 				return state.Fail[*Parser](err)
 			}
 
-			info.Descriptor = &p.class.ConstantPool[cpIndex-1]
+			if info.Descriptor, err = p.constant(cpIndex); err != nil {
+				return state.Fail[*Parser](err)
+			}
 			p.class.ConstantPool[i] = info
 		default:
 			return state.Fail[*Parser](fmt.Errorf("unknown cp_info_tag: %d. See https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-4.html#jvms-4.4-140", int(tag)))
 		}
 	}
 
-	return access
+	return state.Goto(p, constantPoolRefs)
+}
+
+// constantPoolRefs checks that constants referring to other constants, which
+// may come later in the pool, refer to ones of the right kind.
+func constantPoolRefs(p *Parser) state.Fn[*Parser] {
+	if err := checkConstantPool(p); err != nil {
+		return state.Fail[*Parser](err)
+	}
+
+	return state.Goto(p, access)
+}
+
+// checkConstantPool validates the references between constants. In lenient
+// mode, offending constants are emptied instead, which may in turn invalidate
+// the constants referring to them, until none are left. Once checked, the
+// references can be followed without running into cycles.
+func checkConstantPool(p *Parser) error {
+	check := func(ref *data.Data, tag data.Tag) error {
+		if tagOf(*ref) != tag {
+			return fmt.Errorf("expected reference to %s got %s", tag, tagOf(*ref))
+		}
+		return nil
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for i, c := range p.class.ConstantPool {
+			if c == nil {
+				continue
+			}
+
+			p.at("constant pool entry %d", i+1)
+
+			var err error
+			switch c.Tag() {
+			case data.CP_CLASS:
+				err = check(c.ConstantClass().Name, data.CP_UTF8)
+			case data.CP_STRING:
+				err = check(c.ConstantString().Value, data.CP_UTF8)
+			case data.CP_NAME_AND_TYPE:
+				if err = check(c.ConstantNameAndType().Name, data.CP_UTF8); err == nil {
+					err = check(c.ConstantNameAndType().Descriptor, data.CP_UTF8)
+				}
+			case data.CP_FIELDREF:
+				if err = check(c.ConstantFieldref().Clazz, data.CP_CLASS); err == nil {
+					err = check(c.ConstantFieldref().NameAndType, data.CP_NAME_AND_TYPE)
+				}
+			case data.CP_METHODREF:
+				if err = check(c.ConstantMethodref().Clazz, data.CP_CLASS); err == nil {
+					err = check(c.ConstantMethodref().NameAndType, data.CP_NAME_AND_TYPE)
+				}
+			case data.CP_INTERFACE_METHODREF:
+				if err = check(c.ConstantInterfaceMethodref().Clazz, data.CP_CLASS); err == nil {
+					err = check(c.ConstantInterfaceMethodref().NameAndType, data.CP_NAME_AND_TYPE)
+				}
+			}
+
+			if err != nil {
+				if !p.tolerate(err) {
+					return err
+				}
+
+				p.class.ConstantPool[i] = nil
+				changed = true
+			}
+		}
+	}
+
+	p.poolChecked = true
+
+	return nil
 }
 
 func access(p *Parser) state.Fn[*Parser] {
+	p.at("access flags")
+
 	if err := p.readDecode(&p.class.AccessFlags); err != nil {
 		return state.Fail[*Parser](err)
 	}
 
-	return thisClass
+	return state.Goto(p, thisClass)
 }
 
 func thisClass(p *Parser) state.Fn[*Parser] {
+	p.at("this class")
+
 	var cpIndex uint16
 	if err := p.readDecode(&cpIndex); err != nil {
 		return state.Fail[*Parser](err)
 	}
 
-	p.class.ThisClass = *p.class.ConstantPool[cpIndex-1].ConstantClass()
+	if c, err := p.constantOf(cpIndex, data.CP_CLASS); err != nil {
+		return state.Fail[*Parser](err)
+	} else {
+		p.class.ThisClass = c.ConstantClass()
+	}
 
-	return superClass
+	return state.Goto(p, superClass)
 }
 
 func superClass(p *Parser) state.Fn[*Parser] {
+	p.at("super class")
+
 	var cpIndex uint16
 	if err := p.readDecode(&cpIndex); err != nil {
 		return state.Fail[*Parser](err)
 	}
 
 	if cpIndex != 0 {
-		p.class.SuperClass = p.class.ConstantPool[cpIndex-1].ConstantClass()
+		if c, err := p.constantOf(cpIndex, data.CP_CLASS); err != nil {
+			return state.Fail[*Parser](err)
+		} else {
+			p.class.SuperClass = c.ConstantClass()
+		}
 	}
 
-	return interfaces
+	return state.Goto(p, interfaces)
 }
 
 func interfaces(p *Parser) state.Fn[*Parser] {
+	p.at("interfaces")
+
 	var n uint16
 	if err := p.readDecode(&n); err != nil {
 		return state.Fail[*Parser](err)
 	}
 
 	for range n {
-		return state.Fail[*Parser](fmt.Errorf("interfaces not implemented"))
+		var cpIndex uint16
+		if err := p.readDecode(&cpIndex); err != nil {
+			return state.Fail[*Parser](err)
+		}
+
+		if c, err := p.constantOf(cpIndex, data.CP_CLASS); err != nil {
+			return state.Fail[*Parser](err)
+		} else {
+			p.class.Interfaces = append(p.class.Interfaces, c.ConstantClass())
+		}
 	}
 
-	return fields
+	return state.Goto(p, fields)
 }
 
 func fields(p *Parser) state.Fn[*Parser] {
+	p.at("fields count")
+
 	var n uint16
 	if err := p.readDecode(&n); err != nil {
 		return state.Fail[*Parser](err)
 	}
 
-	for range n {
-		if field, err := parseMember(p, data.FIELD); err != nil {
+	for i := range n {
+		if field, err := parseMember(p, data.FIELD, i); err != nil {
 			return state.Fail[*Parser](err)
 		} else {
 			p.class.Fields = append(p.class.Fields, *field)
 		}
 	}
 
-	return methods
+	return state.Goto(p, methods)
 }
 
 func methods(p *Parser) state.Fn[*Parser] {
+	p.at("methods count")
+
 	var n uint16
 	if err := p.readDecode(&n); err != nil {
 		return state.Fail[*Parser](err)
 	}
 
-	for range n {
-		if method, err := parseMember(p, data.METHOD); err != nil {
+	for i := range n {
+		if method, err := parseMember(p, data.METHOD, i); err != nil {
 			return state.Fail[*Parser](err)
 		} else {
 			p.class.Methods = append(p.class.Methods, *method)
 		}
 	}
 
-	return attributes
+	return state.Goto(p, attributes)
 }
 
 func attributes(p *Parser) state.Fn[*Parser] {
+	p.at("class attributes count")
+
 	var n uint16
 	if err := p.readDecode(&n); err != nil {
 		return state.Fail[*Parser](err)
@@ -228,12 +422,14 @@ func attributes(p *Parser) state.Fn[*Parser] {
 
 	p.class.Attributes = make(map[data.Tag]*data.AttributeHandle)
 	for range n {
-		if attr, err := parseAttribute(p); err != nil {
+		if attr, err := parseAttribute(p, "class"); err != nil {
 			return state.Fail[*Parser](err)
-		} else {
-			p.class.Attributes[attr.AttributeTag] = attr
+		} else if attr != nil {
+			if err := addAttribute(p, p.class.Attributes, attr); err != nil {
+				return state.Fail[*Parser](err)
+			}
 		}
 	}
 
-	return classEnd
+	return state.Goto(p, classEnd)
 }
@@ -1,7 +1,7 @@
 package parser
 
 import (
-	"io"
+	"fmt"
 
 	"github.com/luishfonseca/dtu_pa/data"
 	"github.com/luishfonseca/dtu_pa/state"
@@ -9,40 +9,63 @@ import (
 
 func bytecode(code data.BytecodeHandle) state.Fn[*Parser] {
 	return func(p *Parser) state.Fn[*Parser] {
-		if _, err := p.input.Seek(code.Begin, io.SeekStart); err != nil {
+		bc, err := parseBytecode(p, code)
+		if err != nil {
 			return state.Fail[*Parser](err)
 		}
 
-		p.codes[code] = &data.Bytecode{}
+		p.codes[code] = bc
 
-		remaining := int(code.Length)
+		if err := p.send(p.codes[code]); err != nil {
+			return state.Fail[*Parser](err)
+		}
+		return state.Goto(p, waitReq)
+	}
+}
 
-		for remaining > 0 {
-			b, err := p.read(1)
-			if err != nil {
-				return state.Fail[*Parser](err)
-			}
+// parseBytecode decodes the instructions of code. On failure, the instructions
+// decoded so far are returned along with the error.
+func parseBytecode(p *Parser, code data.BytecodeHandle) (*data.Bytecode, error) {
+	label := p.labels[code.Begin]
 
-			op := data.Op{Code: data.OpCode(b[0])}
-			nArgs, err := op.Code.NArgs()
-			if err != nil {
-				return state.Fail[*Parser](err)
-			}
+	if err := p.seek(code.Begin); err != nil {
+		return nil, err
+	}
 
-			if nArgs > 0 {
-				if b, err := p.read(nArgs); err != nil {
-					return state.Fail[*Parser](err)
-				} else {
-					op.Arg = b
-				}
-			}
+	bc := &data.Bytecode{}
+
+	remaining := int(code.Length)
 
-			p.codes[code].Ops = append(p.codes[code].Ops, op)
+	for remaining > 0 {
+		p.at("%s pc %d", label, int(code.Length)-remaining)
 
-			remaining -= 1 + nArgs
+		b, err := p.read(1)
+		if err != nil {
+			return bc, err
 		}
 
-		p.dataCh <- p.codes[code]
-		return waitReq
+		op := data.Op{Code: data.OpCode(b[0])}
+		nArgs, err := op.Code.NArgs()
+		if err != nil {
+			return bc, err
+		}
+
+		if 1+nArgs > remaining {
+			return bc, fmt.Errorf("%s operands overrun the end of the code", op.Code)
+		}
+
+		if nArgs > 0 {
+			if b, err := p.read(nArgs); err != nil {
+				return bc, err
+			} else {
+				op.Arg = b
+			}
+		}
+
+		bc.Ops = append(bc.Ops, op)
+
+		remaining -= 1 + nArgs
 	}
+
+	return bc, nil
 }
@@ -0,0 +1,19 @@
+package parser
+
+import "fmt"
+
+// ParseError locates a parsing failure in the class file. Use errors.As to
+// retrieve it from the error returned by Parser.Run.
+type ParseError struct {
+	Offset  int64  // byte offset in the class file of the token being parsed
+	Section string // structure being parsed, e.g. "constant pool entry 17"
+	Err     error  // underlying cause
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s (offset %d): %v", e.Section, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
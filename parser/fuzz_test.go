@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// classBuilder assembles class files for the seed corpus.
+type classBuilder struct {
+	pool    [][]byte
+	indices map[string]uint16
+}
+
+func newClassBuilder() *classBuilder {
+	return &classBuilder{indices: make(map[string]uint16)}
+}
+
+func be(vs ...any) []byte {
+	var buf bytes.Buffer
+	for _, v := range vs {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	return buf.Bytes()
+}
+
+func (b *classBuilder) intern(key string, entry []byte) uint16 {
+	if i, ok := b.indices[key]; ok {
+		return i
+	}
+
+	b.pool = append(b.pool, entry)
+	b.indices[key] = uint16(len(b.pool))
+
+	return b.indices[key]
+}
+
+func (b *classBuilder) utf8(s string) uint16 {
+	return b.intern("u"+s, append(be(uint8(1), uint16(len(s))), s...))
+}
+
+func (b *classBuilder) integer(v int32) uint16 {
+	return b.intern(string(be(uint8(3), v)), be(uint8(3), v))
+}
+
+func (b *classBuilder) class(name string) uint16 {
+	return b.intern("c"+name, be(uint8(7), b.utf8(name)))
+}
+
+func (b *classBuilder) nameAndType(name, desc string) uint16 {
+	return b.intern("n"+name+desc, be(uint8(12), b.utf8(name), b.utf8(desc)))
+}
+
+func (b *classBuilder) fieldref(class, name, desc string) uint16 {
+	return b.intern("f"+class+name+desc, be(uint8(9), b.class(class), b.nameAndType(name, desc)))
+}
+
+func (b *classBuilder) methodref(class, name, desc string) uint16 {
+	return b.intern("m"+class+name+desc, be(uint8(10), b.class(class), b.nameAndType(name, desc)))
+}
+
+func (b *classBuilder) attribute(name string, body []byte) []byte {
+	return append(be(b.utf8(name), uint32(len(body))), body...)
+}
+
+func (b *classBuilder) code(maxStack, maxLocals uint16, code []byte, exceptions [][4]uint16, lines [][2]uint16) []byte {
+	body := append(be(maxStack, maxLocals, uint32(len(code))), code...)
+
+	body = append(body, be(uint16(len(exceptions)))...)
+	for _, e := range exceptions {
+		body = append(body, be(e)...)
+	}
+
+	if lines == nil {
+		body = append(body, be(uint16(0))...)
+	} else {
+		table := be(uint16(len(lines)))
+		for _, l := range lines {
+			table = append(table, be(l)...)
+		}
+		body = append(body, be(uint16(1))...)
+		body = append(body, b.attribute("LineNumberTable", table)...)
+	}
+
+	return b.attribute("Code", body)
+}
+
+func (b *classBuilder) member(flags uint16, name, desc string, attrs ...[]byte) []byte {
+	m := be(flags, b.utf8(name), b.utf8(desc), uint16(len(attrs)))
+	for _, a := range attrs {
+		m = append(m, a...)
+	}
+	return m
+}
+
+func (b *classBuilder) build(this string, fields, methods, attrs [][]byte) []byte {
+	thisIdx, superIdx := b.class(this), b.class("java/lang/Object")
+
+	body := be(uint16(0x21), thisIdx, superIdx, uint16(0))
+	for _, list := range [][][]byte{fields, methods, attrs} {
+		body = append(body, be(uint16(len(list)))...)
+		for _, item := range list {
+			body = append(body, item...)
+		}
+	}
+
+	out := be(uint32(0xCAFEBABE), uint16(0), uint16(65), uint16(len(b.pool)+1))
+	for _, entry := range b.pool {
+		out = append(out, entry...)
+	}
+
+	return append(out, body...)
+}
+
+func seedClasses() [][]byte {
+	minimal := newClassBuilder()
+
+	simple := newClassBuilder()
+	init := simple.methodref("java/lang/Object", "<init>", "()V")
+	disabled := simple.fieldref("jpamb/cases/Simple", "$assertionsDisabled", "Z")
+	assertionError := simple.class("java/lang/AssertionError")
+	assertionInit := simple.methodref("java/lang/AssertionError", "<init>", "()V")
+	big := simple.integer(100000)
+
+	fields := [][]byte{simple.member(0x1018, "$assertionsDisabled", "Z")}
+	methods := [][]byte{
+		simple.member(0x1, "<init>", "()V", simple.code(1, 1, be(uint8(0x2a), uint8(0xb7), init, uint8(0xb1)), nil, [][2]uint16{{0, 3}})),
+		simple.member(0x9, "divideByN", "(I)I", simple.code(2, 1, []byte{0x04, 0x1a, 0x6c, 0xac}, nil, [][2]uint16{{0, 7}})),
+		simple.member(0x9, "assertPositive", "(I)V", simple.code(2, 1, be(
+			uint8(0xb2), disabled, uint8(0x9a), int16(16), uint8(0x1a), uint8(0x9d), int16(12),
+			uint8(0xbb), assertionError, uint8(0x59), uint8(0xb7), assertionInit, uint8(0xbf), uint8(0xb1),
+		), nil, nil)),
+		simple.member(0x9, "catching", "()I", simple.code(2, 1, be(
+			uint8(0x12), uint8(big), uint8(0x03), uint8(0x6c), uint8(0xac), uint8(0x4b), uint8(0x03), uint8(0xac),
+		), [][4]uint16{{0, 4, 5, assertionError}, {0, 4, 5, 0}}, nil)),
+		simple.member(0x9, "arrays", "(I)I", simple.code(3, 2, []byte{
+			0x06, 0xbc, 10, 0x4c, 0x2b, 0x03, 0x1a, 0x4f, 0x2b, 0x03, 0x2e, 0x2b, 0xbe, 0x60, 0xac,
+		}, nil, nil)),
+		simple.member(0x9, "loop", "(I)I", simple.code(2, 2, be(
+			uint8(0x03), uint8(0x3c), uint8(0x1a), uint8(0x99), int16(13), uint8(0x1b), uint8(0x1a), uint8(0x60),
+			uint8(0x3c), uint8(0x84), uint8(0), int8(-1), uint8(0xa7), int16(-11), uint8(0x1b), uint8(0xac),
+		), nil, nil)),
+		simple.member(0x401, "abstract", "()V"),
+	}
+	attrs := [][]byte{simple.attribute("SourceFile", be(simple.utf8("Simple.java")))}
+
+	seeds := [][]byte{
+		minimal.build("Minimal", nil, nil, nil),
+		simple.build("jpamb/cases/Simple", fields, methods, attrs),
+	}
+
+	// Truncations of a valid class exercise every structure's failure path
+	full := seeds[1]
+	for _, n := range []int{0, 4, 10, 64, len(full) / 2, len(full) - 1} {
+		seeds = append(seeds, full[:n])
+	}
+
+	return seeds
+}
+
+// parse runs the whole pipeline over class, requesting the code of every
+// method, and checks that it ends with either a class or an error.
+func parse(t *testing.T, class []byte, opts ...Option) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dataCh := make(chan data.Data)
+	reqCh := make(chan data.Data)
+
+	p, err := NewReader(bytes.NewReader(class), dataCh, reqCh, opts...)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.Run(ctx)
+	}()
+
+	receive := func() (data.Data, bool) {
+		select {
+		case d, ok := <-dataCh:
+			return d, ok
+		case <-ctx.Done():
+			t.Fatal("deadlock waiting for data from the parser")
+			return nil, false
+		}
+	}
+
+	request := func(h data.Data) (data.Data, bool) {
+		select {
+		case reqCh <- h:
+		case <-ctx.Done():
+			t.Fatal("deadlock sending a request to the parser")
+		}
+		return receive()
+	}
+
+	d, gotClass := receive()
+	if gotClass {
+		c := d.Class()
+		_ = c.String()
+
+		for _, method := range c.Methods {
+			attr, ok := method.Attributes[data.ATTR_CODE]
+			if !ok || method.Unparsable {
+				continue
+			}
+
+			d, ok := request(attr)
+			if !ok {
+				break
+			}
+			code := d.AttributeCode()
+			_ = code.String()
+
+			if d, ok = request(&code.CodeHandle); !ok {
+				break
+			}
+			_ = d.Bytecode().String()
+		}
+	}
+
+	close(reqCh)
+
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		t.Fatal("parser did not stop after the request channel was closed")
+	}
+
+	if !gotClass && err == nil {
+		t.Fatal("parser produced neither a class nor an error")
+	}
+
+	if _, ok := <-dataCh; ok {
+		t.Fatal("parser sent data it was not asked for")
+	}
+}
+
+func TestSeeds(t *testing.T) {
+	seeds := seedClasses()
+
+	for _, opts := range [][]Option{nil, {Lenient()}} {
+		for _, seed := range seeds[:2] {
+			dataCh := make(chan data.Data)
+			reqCh := make(chan data.Data)
+
+			p, err := NewReader(bytes.NewReader(seed), dataCh, reqCh, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			close(reqCh)
+			go func() {
+				for range dataCh {
+				}
+			}()
+
+			if err := p.Run(context.Background()); err != nil {
+				t.Errorf("seed should parse: %v", err)
+			}
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range seedClasses() {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, class []byte) {
+		parse(t, class)
+	})
+}
+
+func FuzzParseLenient(f *testing.F) {
+	for _, seed := range seedClasses() {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, class []byte) {
+		parse(t, class, Lenient())
+	})
+}
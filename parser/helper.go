@@ -2,16 +2,80 @@ package parser
 
 import (
 	"fmt"
-	"io"
+	"strings"
 
 	"github.com/luishfonseca/dtu_pa/data"
 )
 
-func parseMember(p *Parser, m data.MemberType) (*data.MemberInfo, error) {
+// constant returns the constant pool slot at index, which is 1-based.
+func (p *Parser) constant(index uint16) (*data.Data, error) {
+	if index == 0 || int(index) > len(p.class.ConstantPool) {
+		return nil, fmt.Errorf("constant pool index %d out of range [1, %d]", index, len(p.class.ConstantPool))
+	}
+
+	return &p.class.ConstantPool[index-1], nil
+}
+
+// constantOf returns the constant pool slot at index, checking that it holds
+// a constant with the given tag.
+func (p *Parser) constantOf(index uint16, tag data.Tag) (data.Data, error) {
+	c, err := p.constant(index)
+	if err != nil {
+		return nil, err
+	}
+
+	if tagOf(*c) != tag {
+		return nil, fmt.Errorf("constant pool index %d: expected %s got %s", index, tag, tagOf(*c))
+	}
+
+	return *c, nil
+}
+
+// utf8 returns the ConstantUtf8 at index, which may be 0 if optional.
+func (p *Parser) utf8(index uint16, optional bool) (*data.ConstantUtf8, error) {
+	if index == 0 && optional {
+		return nil, nil
+	}
+
+	c, err := p.constantOf(index, data.CP_UTF8)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ConstantUtf8(), nil
+}
+
+// classConstant returns the ConstantClass at index, which may be 0 if optional.
+func (p *Parser) classConstant(index uint16, optional bool) (*data.ConstantClass, error) {
+	if index == 0 && optional {
+		return nil, nil
+	}
+
+	c, err := p.constantOf(index, data.CP_CLASS)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ConstantClass(), nil
+}
+
+// tagOf is the tag of d, or UNKNOWN for an empty constant pool slot.
+func tagOf(d data.Data) data.Tag {
+	if d == nil {
+		return data.UNKNOWN
+	}
+
+	return d.Tag()
+}
+
+func parseMember(p *Parser, m data.MemberType, i uint16) (*data.MemberInfo, error) {
 	info := &data.MemberInfo{
 		MemberType: m,
 	}
 
+	owner := fmt.Sprintf("%s %d", strings.ToLower(m.String()), i)
+	p.at("%s", owner)
+
 	if err := p.readDecode(&info.AccessFlags); err != nil {
 		return nil, err
 	}
@@ -21,13 +85,24 @@ func parseMember(p *Parser, m data.MemberType) (*data.MemberInfo, error) {
 		return nil, err
 	}
 
-	info.Name = *p.class.ConstantPool[cpIndex-1].ConstantUtf8()
+	if c, err := p.constantOf(cpIndex, data.CP_UTF8); err != nil {
+		return nil, err
+	} else {
+		info.Name = c.ConstantUtf8()
+	}
+
+	owner = fmt.Sprintf("%s %s", strings.ToLower(m.String()), info.Name.Value)
+	p.at("%s", owner)
 
 	if err := p.readDecode(&cpIndex); err != nil {
 		return nil, err
 	}
 
-	info.Descriptor = *p.class.ConstantPool[cpIndex-1].ConstantUtf8()
+	if c, err := p.constantOf(cpIndex, data.CP_UTF8); err != nil {
+		return nil, err
+	} else {
+		info.Descriptor = c.ConstantUtf8()
+	}
 
 	var n uint16
 	if err := p.readDecode(&n); err != nil {
@@ -36,23 +111,50 @@ func parseMember(p *Parser, m data.MemberType) (*data.MemberInfo, error) {
 
 	info.Attributes = make(map[data.Tag]*data.AttributeHandle)
 	for range n {
-		if attr, err := parseAttribute(p); err != nil {
+		if attr, err := parseAttribute(p, owner); err != nil {
 			return nil, err
-		} else {
-			info.Attributes[attr.AttributeTag] = attr
+		} else if attr != nil {
+			if err := addAttribute(p, info.Attributes, attr); err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	return info, nil
 }
 
-func parseAttribute(p *Parser) (*data.AttributeHandle, error) {
+// addAttribute adds attr to the attributes of a class or member, which may
+// only have one of each kind.
+func addAttribute(p *Parser, attrs map[data.Tag]*data.AttributeHandle, attr *data.AttributeHandle) error {
+	if _, ok := attrs[attr.AttributeTag]; ok {
+		if err := fmt.Errorf("duplicate attribute %s", attr.Name.Value); !p.tolerate(err) {
+			return err
+		}
+		return nil
+	}
+
+	attrs[attr.AttributeTag] = attr
+	return nil
+}
+
+// parseAttribute records a handle to the attribute at the current position,
+// labelled as belonging to owner, and skips over its contents. Unknown
+// attributes tolerated in lenient mode yield a nil handle.
+func parseAttribute(p *Parser, owner string) (*data.AttributeHandle, error) {
+	p.at("%s attribute", owner)
+
 	var cpIndex uint16
 	if err := p.readDecode(&cpIndex); err != nil {
 		return nil, err
 	}
 
-	name := p.class.ConstantPool[cpIndex-1].ConstantUtf8().Value
+	c, err := p.constantOf(cpIndex, data.CP_UTF8)
+	if err != nil {
+		return nil, err
+	}
+
+	name := c.ConstantUtf8().Value
+	p.at("%s attribute %s", owner, name)
 
 	var tag data.Tag
 	switch name {
@@ -71,7 +173,10 @@ func parseAttribute(p *Parser) (*data.AttributeHandle, error) {
 	case "StackMapTable":
 		tag = data.ATTR_STACK_MAP_TABLE
 	default:
-		return nil, fmt.Errorf("unknown attribute name: %s", name)
+		// In lenient mode the attribute is skipped using its length prefix
+		if err := fmt.Errorf("unknown attribute name: %s", name); !p.tolerate(err) {
+			return nil, err
+		}
 	}
 
 	var size uint32
@@ -79,18 +184,26 @@ func parseAttribute(p *Parser) (*data.AttributeHandle, error) {
 		return nil, err
 	}
 
-	begin, err := p.input.Seek(0, io.SeekCurrent) // Mark current position
-	if err != nil {
+	if err := p.limits.checkAttributeSize(size); err != nil {
 		return nil, err
 	}
 
-	_, err = p.input.Seek(int64(size), io.SeekCurrent) // Skip attribute content
+	begin, err := p.skip(int64(size)) // Mark current position and skip attribute content
 	if err != nil {
 		return nil, err
 	}
 
-	return &data.AttributeHandle{
+	if tag == data.UNKNOWN {
+		return nil, nil
+	}
+
+	handle := &data.AttributeHandle{
 		AttributeTag: tag,
+		Name:         c.ConstantUtf8(),
 		Begin:        begin,
-	}, nil
+		Length:       size,
+	}
+	p.labels[begin] = p.section
+
+	return handle, nil
 }
@@ -0,0 +1,51 @@
+package parser
+
+import "fmt"
+
+// Limits bounds the resources a single parse may consume. A zero value in any
+// field disables that particular limit.
+type Limits struct {
+	MaxConstantPool  uint16 // maximum constant_pool_count
+	MaxAttributeSize uint32 // maximum attribute_length of any attribute
+	MaxCodeLength    uint32 // maximum code_length of a Code attribute
+	MaxBytesRead     int64  // maximum number of bytes read from the input
+}
+
+// DefaultLimits are generous enough for any class javac produces while still
+// keeping hostile inputs in check.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxConstantPool:  0, // constant_pool_count is a u2, which bounds it already
+		MaxAttributeSize: 16 << 20,
+		MaxCodeLength:    0xFFFF, // See https://docs.oracle.com/javase/specs/jvms/se8/html/jvms-4.html#jvms-4.7.3
+		MaxBytesRead:     64 << 20,
+	}
+}
+
+func (l Limits) checkConstantPool(n uint16) error {
+	if l.MaxConstantPool != 0 && n > l.MaxConstantPool {
+		return fmt.Errorf("constant pool count %d exceeds limit of %d", n, l.MaxConstantPool)
+	}
+	return nil
+}
+
+func (l Limits) checkAttributeSize(n uint32) error {
+	if l.MaxAttributeSize != 0 && n > l.MaxAttributeSize {
+		return fmt.Errorf("attribute length %d exceeds limit of %d", n, l.MaxAttributeSize)
+	}
+	return nil
+}
+
+func (l Limits) checkCodeLength(n uint32) error {
+	if l.MaxCodeLength != 0 && n > l.MaxCodeLength {
+		return fmt.Errorf("code length %d exceeds limit of %d", n, l.MaxCodeLength)
+	}
+	return nil
+}
+
+func (l Limits) checkBytesRead(n int64) error {
+	if l.MaxBytesRead != 0 && n > l.MaxBytesRead {
+		return fmt.Errorf("read %d bytes, exceeding limit of %d", n, l.MaxBytesRead)
+	}
+	return nil
+}
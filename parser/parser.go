@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,39 +13,163 @@ import (
 )
 
 type Parser struct {
-	input      io.ReadSeekCloser
-	dataCh     chan<- data.Data
-	reqCh      <-chan data.Data
-	attributes map[data.AttributeHandle]data.Data
-	codes      map[data.BytecodeHandle]*data.Bytecode
-	class      *data.Class
-	err        error
+	ctx         context.Context
+	input       io.ReadSeeker
+	size        int64
+	nread       int64
+	offset      int64 // current position in input
+	mark        int64 // position of the last token read
+	section     string
+	labels      map[int64]string // sections by offset of deferred structures
+	limits      Limits
+	dataCh      chan<- data.Data
+	reqCh       <-chan data.Data
+	attributes  map[data.AttributeHandle]data.Data
+	codes       map[data.BytecodeHandle]*data.Bytecode
+	class       *data.Class
+	sent        bool // whether the class has been sent
+	poolChecked bool
+	lenient     bool
+	diags       []*ParseError
+	observer    state.Observer
+	site        state.Site
+	err         error
 }
 
-func New(file string, dataCh chan<- data.Data, reqCh <-chan data.Data) (*Parser, error) {
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithLimits replaces the DefaultLimits of the parser.
+func WithLimits(limits Limits) Option {
+	return func(p *Parser) {
+		p.limits = limits
+	}
+}
+
+// Lenient makes the parser record recoverable problems as diagnostics instead
+// of failing, skipping broken structures where their length is known. Methods
+// whose code cannot be parsed are marked as unparsable, and if the class
+// itself is damaged beyond repair, whatever was parsed up to that point is
+// still sent.
+func Lenient() Option {
+	return func(p *Parser) {
+		p.lenient = true
+	}
+}
+
+// WithObserver reports every state transition of the parser to obs.
+func WithObserver(obs state.Observer) Option {
+	return func(p *Parser) {
+		p.observer = obs
+	}
+}
+
+func New(file string, dataCh chan<- data.Data, reqCh <-chan data.Data, opts ...Option) (*Parser, error) {
 	input, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Parser{
+	return NewReader(input, dataCh, reqCh, opts...)
+}
+
+// NewReader creates a parser reading the class from input, which is closed by
+// Run if it is an io.Closer.
+func NewReader(input io.ReadSeeker, dataCh chan<- data.Data, reqCh <-chan data.Data, opts ...Option) (*Parser, error) {
+	size, err := input.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = input.Seek(0, io.SeekStart)
+	}
+
+	if err != nil {
+		if c, ok := input.(io.Closer); ok {
+			c.Close()
+		}
+		return nil, err
+	}
+
+	p := &Parser{
 		input:      input,
+		size:       size,
+		limits:     DefaultLimits(),
 		dataCh:     dataCh,
 		reqCh:      reqCh,
+		labels:     make(map[int64]string),
 		attributes: make(map[data.AttributeHandle]data.Data),
 		codes:      make(map[data.BytecodeHandle]*data.Bytecode),
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p, nil
 }
 
 func (p *Parser) read(n int) ([]byte, error) {
+	if err := p.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := p.limits.checkBytesRead(p.nread + int64(n)); err != nil {
+		return nil, err
+	}
+
+	p.mark = p.offset
+
 	token := make([]byte, n)
-	if _, err := io.ReadFull(p.input, token); err != nil {
+	nrd, err := io.ReadFull(p.input, token)
+	p.nread += int64(nrd)
+	p.offset += int64(nrd)
+	if err != nil {
 		return nil, err
 	}
 
 	return token, nil
 }
 
+// skip moves past n bytes of input that will only be read on request, making
+// sure they actually exist in the file.
+func (p *Parser) skip(n int64) (begin int64, err error) {
+	begin = p.offset
+	p.mark = begin
+
+	if begin+n > p.size {
+		return 0, fmt.Errorf("%d bytes extend past end of file (%d bytes)", n, p.size)
+	}
+
+	if err = p.seek(begin + n); err != nil {
+		return 0, err
+	}
+
+	return begin, nil
+}
+
+func (p *Parser) seek(offset int64) error {
+	if _, err := p.input.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	p.offset = offset
+	p.mark = offset
+
+	return nil
+}
+
+// at names the structure about to be parsed, for error reporting.
+func (p *Parser) at(format string, args ...any) {
+	p.section = fmt.Sprintf(format, args...)
+}
+
+func (p *Parser) send(d data.Data) error {
+	select {
+	case p.dataCh <- d:
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
 func (p *Parser) readDecode(v any) error {
 	n := binary.Size(v)
 
@@ -61,16 +187,76 @@ func (p *Parser) readDecode(v any) error {
 	return nil
 }
 
+// locate wraps err in a ParseError pointing at the current position, unless
+// it already is one.
+func (p *Parser) locate(err error) *ParseError {
+	if perr := (*ParseError)(nil); errors.As(err, &perr) {
+		return perr
+	}
+
+	return &ParseError{Offset: p.mark, Section: p.section, Err: err}
+}
+
+// tolerate records err as a diagnostic in lenient mode, reporting whether
+// parsing may carry on.
+func (p *Parser) tolerate(err error) bool {
+	if !p.lenient || p.ctx.Err() != nil {
+		return false
+	}
+
+	p.diags = append(p.diags, p.locate(err))
+
+	return true
+}
+
+// Diagnostics returns the problems tolerated in lenient mode. It must only be
+// called after Run has returned.
+func (p *Parser) Diagnostics() []*ParseError {
+	return p.diags
+}
+
+// Observer implements the optional observability of state machines.
+func (p *Parser) Observer() state.Observer {
+	return p.observer
+}
+
+// Site is where the last state function moved on, for the Observer.
+func (p *Parser) Site() *state.Site {
+	return &p.site
+}
+
+// Offset is the current position of the parser in the class file.
+func (p *Parser) Offset() int64 {
+	return p.offset
+}
+
 func (p *Parser) Fail(err error) {
-	p.err = err
+	p.err = p.locate(err)
 }
 
-func (p *Parser) Run() error {
+// Run parses the class and then serves requests until reqCh is closed, the
+// input turns out to be invalid, or ctx is done.
+func (p *Parser) Run(ctx context.Context) error {
 	defer close(p.dataCh)
+	if c, ok := p.input.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	p.ctx = ctx
 
 	p.class = &data.Class{}
 	state.Run(p, classStart)
 
+	if p.err != nil && !p.sent && p.tolerate(p.err) {
+		p.err = nil
+
+		// Salvage what was parsed before the failure
+		if !p.poolChecked {
+			checkConstantPool(p)
+		}
+		state.Run(p, preload)
+	}
+
 	if p.err != nil {
 		return p.err
 	}
@@ -79,43 +265,114 @@ func (p *Parser) Run() error {
 }
 
 func classStart(p *Parser) state.Fn[*Parser] {
-	return magic
+	return state.Goto(p, magic)
 }
 
 func classEnd(p *Parser) state.Fn[*Parser] {
+	p.at("end of class")
+
 	if _, err := p.read(1); err != io.EOF {
-		return state.Fail[*Parser](fmt.Errorf("expected EOF, got more data"))
+		if err := fmt.Errorf("expected EOF, got more data"); !p.tolerate(err) {
+			return state.Fail[*Parser](err)
+		}
+	}
+
+	if p.lenient {
+		return state.Goto(p, preload)
+	}
+
+	return state.Goto(p, sendClass)
+}
+
+// preload parses the code of every method upfront, so that those that cannot
+// be parsed are marked as such before the class is sent.
+func preload(p *Parser) state.Fn[*Parser] {
+	for i, method := range p.class.Methods {
+		attr, ok := method.Attributes[data.ATTR_CODE]
+		if !ok {
+			continue
+		}
+
+		d, err := parseAttributeContent(p, *attr)
+		if err != nil {
+			if !p.tolerate(err) {
+				return state.Fail[*Parser](err)
+			}
+
+			p.class.Methods[i].Unparsable = true
+			continue
+		}
+
+		p.attributes[*attr] = d
+		code := d.AttributeCode()
+
+		// Whatever could be decoded is kept, so it can still be inspected
+		bc, err := parseBytecode(p, code.CodeHandle)
+		if err != nil {
+			if !p.tolerate(err) {
+				return state.Fail[*Parser](err)
+			}
+
+			p.class.Methods[i].Unparsable = true
+		}
+
+		if bc != nil {
+			p.codes[code.CodeHandle] = bc
+		}
 	}
 
-	p.dataCh <- p.class
+	return state.Goto(p, sendClass)
+}
+
+func sendClass(p *Parser) state.Fn[*Parser] {
+	p.sent = true
+	if err := p.send(p.class); err != nil {
+		return state.Fail[*Parser](err)
+	}
 
-	return waitReq
+	return state.Goto(p, waitReq)
 }
 
 func waitReq(p *Parser) state.Fn[*Parser] {
-	req, ok := <-p.reqCh
-	if !ok {
-		return done
+	p.at("request")
+
+	var req data.Data
+	select {
+	case r, ok := <-p.reqCh:
+		if !ok {
+			return state.Goto(p, done)
+		}
+		req = r
+	case <-p.ctx.Done():
+		return state.Fail[*Parser](p.ctx.Err())
+	}
+
+	if req == nil {
+		return state.Fail[*Parser](fmt.Errorf("unexpected nil request"))
 	}
 
 	switch req.Tag() {
 	case data.ATTRIBUTE_HANDLE:
 		if attr, ok := p.attributes[*req.AttributeHandle()]; ok {
-			p.dataCh <- attr
+			if err := p.send(attr); err != nil {
+				return state.Fail[*Parser](err)
+			}
 		} else {
-			return attribute(*req.AttributeHandle())
+			return state.Goto(p, attribute(*req.AttributeHandle()))
 		}
 	case data.BYTECODE_HANDLE:
 		if bc, ok := p.codes[*req.BytecodeHandle()]; ok {
-			p.dataCh <- bc
+			if err := p.send(bc); err != nil {
+				return state.Fail[*Parser](err)
+			}
 		} else {
-			return bytecode(*req.BytecodeHandle())
+			return state.Goto(p, bytecode(*req.BytecodeHandle()))
 		}
 	default:
 		return state.Fail[*Parser](fmt.Errorf("unexpected request tag: %s", req.Tag()))
 	}
 
-	return waitReq
+	return state.Goto(p, waitReq)
 }
 
 func done(p *Parser) state.Fn[*Parser] {
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/luishfonseca/dtu_pa/data"
+)
+
+// Session runs a Parser in its own goroutine and exchanges data with it.
+type Session struct {
+	parser *Parser
+	cancel context.CancelFunc
+	dataCh chan data.Data
+	reqCh  chan data.Data
+	errCh  chan error
+	err    error
+	closed bool
+}
+
+// Open starts parsing file. The session must be closed once done with it.
+func Open(ctx context.Context, file string, opts ...Option) (*Session, error) {
+	s := newSession()
+
+	p, err := New(file, s.dataCh, s.reqCh, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating parser: %w", err)
+	}
+
+	return s.start(ctx, p), nil
+}
+
+// OpenReader starts parsing the class read from input.
+func OpenReader(ctx context.Context, input io.ReadSeeker, opts ...Option) (*Session, error) {
+	s := newSession()
+
+	p, err := NewReader(input, s.dataCh, s.reqCh, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating parser: %w", err)
+	}
+
+	return s.start(ctx, p), nil
+}
+
+func newSession() *Session {
+	return &Session{
+		dataCh: make(chan data.Data),
+		reqCh:  make(chan data.Data),
+		errCh:  make(chan error, 1),
+	}
+}
+
+func (s *Session) start(ctx context.Context, p *Parser) *Session {
+	s.parser = p
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	go func() {
+		s.errCh <- p.Run(ctx)
+	}()
+
+	return s
+}
+
+// Class waits for the parsed class, which is the first data sent by the
+// parser.
+func (s *Session) Class() (*data.Class, error) {
+	d, err := s.receive()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Class(), nil
+}
+
+// receive waits for the next piece of data sent by the parser.
+func (s *Session) receive() (data.Data, error) {
+	d, ok := <-s.dataCh
+	if !ok {
+		return nil, s.failure()
+	}
+
+	return d, nil
+}
+
+// Request asks the parser to resolve a handle. It is a data.Resolver.
+func (s *Session) Request(handle data.Data) (data.Data, error) {
+	if s.closed {
+		return nil, s.failure()
+	}
+
+	select {
+	case s.reqCh <- handle:
+	case err := <-s.errCh:
+		s.errCh <- err // Parser stopped before taking the request
+		return nil, s.failure()
+	}
+
+	return s.receive()
+}
+
+// failure explains why the parser stopped sending data. The returned error
+// wraps the parser's, so a *ParseError can be retrieved with errors.As.
+func (s *Session) failure() error {
+	if err := s.wait(); err != nil {
+		return fmt.Errorf("parser: %w", err)
+	}
+
+	return fmt.Errorf("no data received from parser")
+}
+
+// wait blocks until the parser has stopped and returns its error.
+func (s *Session) wait() error {
+	if !s.closed {
+		s.err = <-s.errCh
+		s.closed = true
+	}
+
+	return s.err
+}
+
+// Close stops the parser and returns the diagnostics it gathered.
+func (s *Session) Close() []*ParseError {
+	close(s.reqCh)
+	s.cancel()
+	s.wait()
+
+	return s.parser.Diagnostics()
+}
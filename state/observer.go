@@ -0,0 +1,73 @@
+package state
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// Observer is notified of the progress of a state machine. It is called from
+// the goroutine running the machine, after each state function returns.
+type Observer interface {
+	Transition(s any, t Transition)
+	Failure(s any, f Failure)
+}
+
+// Transition from one state to the next, which is the zero State when the
+// machine stops.
+type Transition struct {
+	From    State
+	To      State
+	Site    Site          // where From returned To, zero unless it used Goto
+	Elapsed time.Duration // time spent in From
+}
+
+// Site is a source location in a state function.
+type Site struct {
+	File string
+	Line int
+}
+
+// Failure records where a machine failed, as the source location of the call
+// to Fail.
+type Failure struct {
+	Err  error
+	File string
+	Line int
+}
+
+// offsetter machines have a notion of position in their input, which the
+// Tracer includes in its output.
+type offsetter interface{ Offset() int64 }
+
+// Tracer is an Observer printing every transition and failure to W, at the
+// Site of the transition if known, or else where From is declared.
+type Tracer struct {
+	W io.Writer
+}
+
+func (t Tracer) Transition(s any, tr Transition) {
+	to := tr.To.Name
+	if to == "" {
+		to = "stop"
+	}
+
+	site := tr.Site
+	if site.File == "" {
+		site = Site{File: tr.From.File, Line: tr.From.Line}
+	}
+
+	fmt.Fprintf(t.W, "trace:%s %s -> %s (%s:%d, %v)\n", offset(s), tr.From.Name, to, filepath.Base(site.File), site.Line, tr.Elapsed)
+}
+
+func (t Tracer) Failure(s any, f Failure) {
+	fmt.Fprintf(t.W, "trace:%s failed at %s:%d: %v\n", offset(s), filepath.Base(f.File), f.Line, f.Err)
+}
+
+func offset(s any) string {
+	if o, ok := s.(offsetter); ok {
+		return fmt.Sprintf(" @%d", o.Offset())
+	}
+	return ""
+}
@@ -1,25 +1,113 @@
 package state
 
 import (
-	"fmt"
-	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
+	"time"
 )
 
 type fallible interface{ Fail(error) }
 
 type Fn[T fallible] func(T) Fn[T]
 
+// observable machines report their progress to the Observer they return, if
+// not nil.
+type observable interface{ Observer() Observer }
+
+// locating machines keep the Site where their state functions move on, which
+// Goto records.
+type locating interface{ Site() *Site }
+
 func Run[T fallible](s T, start Fn[T]) {
+	obs := observerOf(s)
+	site := siteOf(s)
+
 	for state := start; state != nil; {
-		state = state(s)
+		if obs == nil {
+			state = state(s)
+			continue
+		}
+
+		if site != nil {
+			*site = Site{}
+		}
+
+		begin := time.Now()
+		next := state(s)
+
+		t := Transition{
+			From:    describe(state),
+			To:      describe(next),
+			Elapsed: time.Since(begin),
+		}
+		if site != nil {
+			t.Site = *site
+		}
+		obs.Transition(s, t)
+
+		state = next
 	}
 }
 
+// Goto returns next, recording where it is called from as the Site of the
+// transition to it when s is observed and keeps one.
+func Goto[T fallible](s T, next Fn[T]) Fn[T] {
+	if site := siteOf(s); site != nil && observerOf(s) != nil {
+		_, site.File, site.Line, _ = runtime.Caller(1)
+	}
+	return next
+}
+
 func Fail[T fallible](err error) Fn[T] {
 	_, file, line, _ := runtime.Caller(1)
 	return func(s T) Fn[T] {
-		s.Fail(fmt.Errorf("%w (at %s:%d)", err, filepath.Base(file), line))
+		s.Fail(err)
+
+		if obs := observerOf(s); obs != nil {
+			obs.Failure(s, Failure{Err: err, File: file, Line: line})
+		}
+
 		return nil
 	}
 }
+
+func observerOf(s any) Observer {
+	if o, ok := s.(observable); ok {
+		return o.Observer()
+	}
+	return nil
+}
+
+func siteOf(s any) *Site {
+	if l, ok := s.(locating); ok {
+		return l.Site()
+	}
+	return nil
+}
+
+// State identifies a state function.
+type State struct {
+	Name string // qualified function name, e.g. "parser.magic"
+	File string // source file where the function is defined
+	Line int
+}
+
+// describe identifies fn, which is the zero State if fn is nil.
+func describe[T fallible](fn Fn[T]) State {
+	if fn == nil {
+		return State{}
+	}
+
+	pc := reflect.ValueOf(fn).Pointer()
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return State{Name: "?"}
+	}
+
+	name := f.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	file, line := f.FileLine(f.Entry())
+
+	return State{Name: name, File: file, Line: line}
+}
@@ -0,0 +1,104 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// countdown is a machine counting n down to 0, then failing if fail is set.
+type countdown struct {
+	n        int
+	fail     bool
+	err      error
+	observer Observer
+	site     Site
+}
+
+func (c *countdown) Fail(err error)     { c.err = err }
+func (c *countdown) Observer() Observer { return c.observer }
+func (c *countdown) Site() *Site        { return &c.site }
+func (c *countdown) Offset() int64      { return int64(c.n) }
+
+var tickLine, failLine int
+
+func tick(c *countdown) Fn[*countdown] {
+	c.n--
+	if c.n > 0 {
+		_, _, tickLine, _ = runtime.Caller(0)
+		return Goto(c, tick) // the line after tickLine
+	}
+	return Goto(c, stop)
+}
+
+func stop(c *countdown) Fn[*countdown] {
+	if c.fail {
+		_, _, failLine, _ = runtime.Caller(0)
+		return Fail[*countdown](errors.New("boom")) // the line after failLine
+	}
+	return nil
+}
+
+// recorder is an Observer keeping what it is told.
+type recorder struct {
+	transitions []Transition
+	failures    []Failure
+}
+
+func (r *recorder) Transition(s any, t Transition) { r.transitions = append(r.transitions, t) }
+func (r *recorder) Failure(s any, f Failure)       { r.failures = append(r.failures, f) }
+
+func TestObserver(t *testing.T) {
+	rec := &recorder{}
+	c := &countdown{n: 3, fail: true, observer: rec}
+	Run(c, tick)
+
+	var got []string
+	for _, tr := range rec.transitions {
+		got = append(got, tr.From.Name+" -> "+tr.To.Name)
+	}
+	want := "state.tick -> state.tick, state.tick -> state.tick, state.tick -> state.stop, " +
+		"state.stop -> state.Fail[...].func1, state.Fail[...].func1 -> "
+	if strings.Join(got, ", ") != want {
+		t.Errorf("transitions %s, want %s", strings.Join(got, ", "), want)
+	}
+
+	if tr := rec.transitions[0]; filepath.Base(tr.Site.File) != "state_test.go" || tr.Site.Line != tickLine+1 {
+		t.Errorf("tick moved on at %s:%d, want line %d", tr.Site.File, tr.Site.Line, tickLine+1)
+	}
+	if tr := rec.transitions[0]; filepath.Base(tr.From.File) != "state_test.go" || tr.From.Line >= tickLine {
+		t.Errorf("tick declared at %s:%d, want before line %d", tr.From.File, tr.From.Line, tickLine)
+	}
+	if tr := rec.transitions[3]; tr.Site != (Site{}) {
+		t.Errorf("stop moved on without Goto, but at %+v", tr.Site)
+	}
+
+	if len(rec.failures) != 1 || rec.failures[0].Line != failLine+1 || c.err == nil || c.err.Error() != "boom" {
+		t.Errorf("failures %+v and error %v, want boom at line %d", rec.failures, c.err, failLine+1)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	var out strings.Builder
+	Run(&countdown{n: 2, fail: true, observer: Tracer{W: &out}}, tick)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("traced %d lines, want 5:\n%s", len(lines), out.String())
+	}
+
+	for i, want := range []string{
+		fmt.Sprintf("trace: @1 state.tick -> state.tick (state_test.go:%d, ", tickLine+1),
+		"trace: @0 state.tick -> state.stop (state_test.go:",
+		"trace: @0 state.stop -> state.Fail[...].func1 (state_test.go:",
+		fmt.Sprintf("trace: @0 failed at state_test.go:%d: boom", failLine+1),
+		"trace: @0 state.Fail[...].func1 -> stop (state.go:",
+	} {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Errorf("line %d is %q, want it to start with %q", i, lines[i], want)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+// Magic starts a trace in the binary format, followed by its version.
+//
+// The rest is the method and inputs, then each event preceded by a 1 byte,
+// then a 0 byte and the outcome. Numbers are varints, signed ones zig-zag
+// encoded, and the steps are the difference from the step before. Strings are
+// the uvarint 1 + i for the ith string already seen, or 0 for a new one,
+// followed by its length and bytes, so that the names of methods and the
+// instructions are written once. An event is its step, depth, method, pc,
+// line, instruction, the stack and locals before and after, each their length
+// and values, its writes, their count and then each its reference, class,
+// field name and descriptor, which are empty for elements, index and value,
+// and its error. A value is its kind, a byte, followed by the int or address
+// unless it is void.
+const Magic = "DPATRACE"
+
+const binaryVersion = 1
+
+// WriteBinary writes t in the binary format.
+func WriteBinary(w io.Writer, t *Trace) error {
+	e := &encoder{out: bufio.NewWriter(w), strings: make(map[string]uint64)}
+
+	e.out.WriteString(Magic)
+	e.out.WriteByte(binaryVersion)
+	e.string(t.Method)
+	e.string(t.Inputs)
+
+	step := 0
+	for _, ev := range t.Events {
+		e.out.WriteByte(1)
+		e.int(int64(ev.Step - step))
+		step = ev.Step
+		e.uint(uint64(ev.Depth))
+		e.string(ev.Method)
+		e.uint(uint64(ev.PC))
+		e.int(int64(ev.Line))
+		e.string(ev.Instruction)
+		e.state(ev.Before)
+		e.state(ev.After)
+		e.uint(uint64(len(ev.Writes)))
+		for _, w := range ev.Writes {
+			e.value(w.Ref)
+			e.string(w.Field.Class)
+			e.string(w.Field.Name)
+			e.string(w.Field.Descriptor)
+			e.int(int64(w.Index))
+			e.value(w.Value)
+		}
+		e.string(ev.Error)
+	}
+
+	e.out.WriteByte(0)
+	e.string(t.Outcome)
+	return e.out.Flush()
+}
+
+type encoder struct {
+	out     *bufio.Writer
+	buf     [binary.MaxVarintLen64]byte
+	strings map[string]uint64
+}
+
+func (e *encoder) uint(v uint64) {
+	e.out.Write(binary.AppendUvarint(e.buf[:0], v))
+}
+
+func (e *encoder) int(v int64) {
+	e.out.Write(binary.AppendVarint(e.buf[:0], v))
+}
+
+func (e *encoder) string(s string) {
+	if i, ok := e.strings[s]; ok {
+		e.uint(i)
+		return
+	}
+	e.strings[s] = uint64(len(e.strings) + 1)
+	e.uint(0)
+	e.uint(uint64(len(s)))
+	e.out.WriteString(s)
+}
+
+func (e *encoder) value(v interpreter.Value) {
+	e.out.WriteByte(byte(v.Kind))
+	if v.Kind != interpreter.VOID {
+		e.int(int64(v.V))
+	}
+}
+
+func (e *encoder) state(s State) {
+	e.uint(uint64(len(s.Stack)))
+	for _, v := range s.Stack {
+		e.value(v)
+	}
+	e.uint(uint64(len(s.Locals)))
+	for _, v := range s.Locals {
+		e.value(v)
+	}
+}
+
+// ReadBinary reads a trace WriteBinary wrote.
+func ReadBinary(r io.Reader) (*Trace, error) {
+	d := &decoder{in: bufio.NewReader(r)}
+
+	magic := make([]byte, len(Magic)+1)
+	if _, err := io.ReadFull(d.in, magic); err != nil || string(magic[:len(Magic)]) != Magic {
+		return nil, fmt.Errorf("not a binary trace")
+	}
+	if magic[len(Magic)] != binaryVersion {
+		return nil, fmt.Errorf("binary trace of version %d, expected %d", magic[len(Magic)], binaryVersion)
+	}
+
+	t := &Trace{Method: d.string(), Inputs: d.string()}
+
+	step := 0
+	for d.err == nil && d.byte() == 1 {
+		ev := Event{}
+		step += int(d.int())
+		ev.Step = step
+		ev.Depth = int(d.uint())
+		ev.Method = d.string()
+		ev.PC = int(d.uint())
+		ev.Line = int(d.int())
+		ev.Instruction = d.string()
+		ev.Before = d.state()
+		ev.After = d.state()
+		for n := d.uint(); n > 0 && d.err == nil; n-- {
+			var w interpreter.Write
+			w.Ref = d.value()
+			w.Field = interpreter.Ref{Class: d.string(), Name: d.string(), Descriptor: d.string()}
+			w.Index = int32(d.int())
+			w.Value = d.value()
+			ev.Writes = append(ev.Writes, w)
+		}
+		ev.Error = d.string()
+		t.Events = append(t.Events, ev)
+	}
+	t.Outcome = d.string()
+
+	if d.err != nil {
+		if errors.Is(d.err, io.EOF) {
+			d.err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("binary trace: %w", d.err)
+	}
+	return t, nil
+}
+
+// decoder reads the binary format, keeping the first error and reading
+// zeros from then on.
+type decoder struct {
+	in      *bufio.Reader
+	strings []string
+	err     error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.in.ReadByte()
+	d.err = err
+	return b
+}
+
+func (d *decoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.in)
+	d.err = err
+	return v
+}
+
+func (d *decoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.in)
+	d.err = err
+	return v
+}
+
+func (d *decoder) string() string {
+	i := d.uint()
+	if d.err != nil {
+		return ""
+	}
+	if i > 0 {
+		if i > uint64(len(d.strings)) {
+			d.err = fmt.Errorf("string %d not seen yet", i)
+			return ""
+		}
+		return d.strings[i-1]
+	}
+
+	n := d.uint()
+	if d.err != nil {
+		return ""
+	}
+	var s strings.Builder
+	if _, err := io.CopyN(&s, d.in, int64(n)); err != nil {
+		d.err = err
+		return ""
+	}
+	d.strings = append(d.strings, s.String())
+	return s.String()
+}
+
+func (d *decoder) value() interpreter.Value {
+	v := interpreter.Value{Kind: interpreter.Kind(d.byte())}
+	switch v.Kind {
+	case interpreter.VOID:
+	case interpreter.INT, interpreter.REFERENCE:
+		v.V = int32(d.int())
+	default:
+		d.err = fmt.Errorf("unknown kind %d", v.Kind)
+	}
+	return v
+}
+
+func (d *decoder) state() State {
+	s := State{Stack: make([]interpreter.Value, min(d.uint(), maxSlots))}
+	for i := range s.Stack {
+		s.Stack[i] = d.value()
+	}
+	s.Locals = make([]interpreter.Value, min(d.uint(), maxSlots))
+	for i := range s.Locals {
+		s.Locals[i] = d.value()
+	}
+	return s
+}
+
+// maxSlots bounds the stack and locals, as max_stack and max_locals are u2.
+const maxSlots = 1 << 16
+
+// Read a trace in either format, telling them apart by Magic.
+func Read(r io.Reader) (*Trace, error) {
+	in := bufio.NewReader(r)
+	if magic, _ := in.Peek(len(Magic)); string(magic) == Magic {
+		return ReadBinary(in)
+	}
+	return ReadJSONL(in)
+}
@@ -0,0 +1,259 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+// A trace as JSON Lines is a HeaderJSON, an EventJSON per instruction run,
+// and an EndJSON, each on a line of its own. Its schema is versioned by the
+// Schema field of the header, as that of inspect --format json is.
+//
+// Values are strings as the interpreter prints them: ints such as "-1",
+// references such as "@3", "null", and "void" for locals not yet stored.
+// Optional values are null, and lists that are empty are [] rather than left
+// out.
+const JSONSchema = 1
+
+type HeaderJSON struct {
+	Schema int    `json:"schema"`
+	Method string `json:"method"`
+	Inputs string `json:"inputs"`
+}
+
+type EventJSON struct {
+	Step        int         `json:"step"`
+	Depth       int         `json:"depth"`
+	Method      string      `json:"method"`
+	PC          int         `json:"pc"`
+	Line        *int        `json:"line"`
+	Instruction string      `json:"instruction"`
+	Before      StateJSON   `json:"before"`
+	After       StateJSON   `json:"after"`
+	Writes      []WriteJSON `json:"writes"`
+	Error       *string     `json:"error"`
+}
+
+type StateJSON struct {
+	Stack  []string `json:"stack"`
+	Locals []string `json:"locals"`
+}
+
+// WriteJSON is a write to an element of an array, with Index, or to a field,
+// of an object at Ref or static if Ref is null.
+type WriteJSON struct {
+	Ref   string  `json:"ref"`
+	Field *string `json:"field"` // e.g. jpamb/cases/Point.x:I
+	Index *int32  `json:"index"`
+	Value string  `json:"value"`
+}
+
+type EndJSON struct {
+	Outcome string `json:"outcome"`
+}
+
+// WriteJSONL writes t as JSON Lines.
+func WriteJSONL(w io.Writer, t *Trace) error {
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(HeaderJSON{Schema: JSONSchema, Method: t.Method, Inputs: t.Inputs}); err != nil {
+		return err
+	}
+
+	for _, e := range t.Events {
+		ej := EventJSON{
+			Step:        e.Step,
+			Depth:       e.Depth,
+			Method:      e.Method,
+			PC:          e.PC,
+			Instruction: e.Instruction,
+			Before:      stateJSON(e.Before),
+			After:       stateJSON(e.After),
+			Writes:      make([]WriteJSON, len(e.Writes)),
+		}
+		if e.Line >= 0 {
+			ej.Line = &e.Line
+		}
+		if e.Error != "" {
+			ej.Error = &e.Error
+		}
+		for i, w := range e.Writes {
+			ej.Writes[i] = WriteJSON{Ref: w.Ref.String(), Value: w.Value.String()}
+			if w.Field == (interpreter.Ref{}) {
+				ej.Writes[i].Index = &w.Index
+			} else {
+				field := w.Field.String()
+				ej.Writes[i].Field = &field
+			}
+		}
+
+		if err := enc.Encode(ej); err != nil {
+			return err
+		}
+	}
+
+	if err := enc.Encode(EndJSON{Outcome: t.Outcome}); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+func stateJSON(s State) StateJSON {
+	js := StateJSON{Stack: make([]string, len(s.Stack)), Locals: make([]string, len(s.Locals))}
+	for i, v := range s.Stack {
+		js.Stack[i] = v.String()
+	}
+	for i, v := range s.Locals {
+		js.Locals[i] = v.String()
+	}
+	return js
+}
+
+// ReadJSONL reads a trace WriteJSONL wrote.
+func ReadJSONL(r io.Reader) (*Trace, error) {
+	lines := bufio.NewScanner(r)
+	lines.Buffer(nil, 1<<26)
+
+	if !lines.Scan() {
+		return nil, fmt.Errorf("empty trace: %w", lines.Err())
+	}
+	var h HeaderJSON
+	if err := json.Unmarshal(lines.Bytes(), &h); err != nil {
+		return nil, fmt.Errorf("line 1: %w", err)
+	}
+	if h.Schema != JSONSchema {
+		return nil, fmt.Errorf("trace of schema %d, expected %d", h.Schema, JSONSchema)
+	}
+
+	t := &Trace{Method: h.Method, Inputs: h.Inputs}
+	for n := 2; lines.Scan(); n++ {
+		var end struct{ Outcome *string }
+		if err := json.Unmarshal(lines.Bytes(), &end); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if end.Outcome != nil {
+			t.Outcome = *end.Outcome
+			return t, nil
+		}
+
+		var ej EventJSON
+		if err := json.Unmarshal(lines.Bytes(), &ej); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		e, err := event(ej)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		t.Events = append(t.Events, e)
+	}
+
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("trace has no end")
+}
+
+func event(ej EventJSON) (Event, error) {
+	e := Event{
+		Step:        ej.Step,
+		Depth:       ej.Depth,
+		Method:      ej.Method,
+		PC:          ej.PC,
+		Line:        -1,
+		Instruction: ej.Instruction,
+	}
+	if ej.Line != nil {
+		e.Line = *ej.Line
+	}
+	if ej.Error != nil {
+		e.Error = *ej.Error
+	}
+
+	var err error
+	if e.Before, err = parseState(ej.Before); err != nil {
+		return e, err
+	}
+	if e.After, err = parseState(ej.After); err != nil {
+		return e, err
+	}
+
+	for _, wj := range ej.Writes {
+		var w interpreter.Write
+		if w.Ref, err = parseValue(wj.Ref); err != nil {
+			return e, err
+		}
+		if w.Value, err = parseValue(wj.Value); err != nil {
+			return e, err
+		}
+		switch {
+		case wj.Field != nil:
+			if w.Field, err = parseRef(*wj.Field); err != nil {
+				return e, err
+			}
+		case wj.Index != nil:
+			w.Index = *wj.Index
+		default:
+			return e, fmt.Errorf("write to neither a field nor an element")
+		}
+		e.Writes = append(e.Writes, w)
+	}
+
+	return e, nil
+}
+
+func parseState(sj StateJSON) (State, error) {
+	s := State{Stack: make([]interpreter.Value, len(sj.Stack)), Locals: make([]interpreter.Value, len(sj.Locals))}
+	for i, v := range sj.Stack {
+		var err error
+		if s.Stack[i], err = parseValue(v); err != nil {
+			return s, err
+		}
+	}
+	for i, v := range sj.Locals {
+		var err error
+		if s.Locals[i], err = parseValue(v); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// parseValue is the inverse of interpreter.Value.String.
+func parseValue(s string) (interpreter.Value, error) {
+	switch {
+	case s == "void":
+		return interpreter.Value{}, nil
+	case s == "null":
+		return interpreter.Null, nil
+	case strings.HasPrefix(s, "@"):
+		v, err := strconv.ParseInt(s[1:], 10, 32)
+		if err != nil || v <= 0 {
+			return interpreter.Value{}, fmt.Errorf("invalid reference %q", s)
+		}
+		return interpreter.Value{Kind: interpreter.REFERENCE, V: int32(v)}, nil
+	default:
+		v, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return interpreter.Value{}, fmt.Errorf("invalid value %q", s)
+		}
+		return interpreter.Int(int32(v)), nil
+	}
+}
+
+// parseRef is the inverse of interpreter.Ref.String.
+func parseRef(s string) (interpreter.Ref, error) {
+	member, desc, ok := strings.Cut(s, ":")
+	dot := strings.LastIndexByte(member, '.')
+	if !ok || dot < 0 {
+		return interpreter.Ref{}, fmt.Errorf("invalid field %q", s)
+	}
+	return interpreter.Ref{Class: member[:dot], Name: member[dot+1:], Descriptor: desc}, nil
+}
@@ -0,0 +1,155 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+// Prompt is written before each command of Replay is read.
+const Prompt = "(replay) "
+
+// String is the event for people, over lines, e.g.
+//
+//	step 3 depth 1 jpamb/cases/Simple.divideByN:(I)I line 4 pc 2: idiv
+//	  stack  [1, 0] -> []
+//	  locals [0] -> [0]
+func (e Event) String() string {
+	var str strings.Builder
+	fmt.Fprintf(&str, "step %d depth %d %s", e.Step, e.Depth, e.Method)
+	if e.Line >= 0 {
+		fmt.Fprintf(&str, " line %d", e.Line)
+	}
+	fmt.Fprintf(&str, " pc %d: %s\n", e.PC, e.Instruction)
+	fmt.Fprintf(&str, "  stack  %s -> %s\n", values(e.Before.Stack), values(e.After.Stack))
+	fmt.Fprintf(&str, "  locals %s -> %s\n", values(e.Before.Locals), values(e.After.Locals))
+	for _, w := range e.Writes {
+		fmt.Fprintf(&str, "  write  %s\n", w)
+	}
+	if e.Error != "" {
+		fmt.Fprintf(&str, "  error  %s\n", e.Error)
+	}
+	return str.String()
+}
+
+func values(vs []interpreter.Value) string {
+	strs := make([]string, len(vs))
+	for i, v := range vs {
+		strs[i] = v.String()
+	}
+	return "[" + strings.Join(strs, ", ") + "]"
+}
+
+// Summary of t, on a line.
+func (t *Trace) Summary() string {
+	outcome := t.Outcome
+	if outcome == "" {
+		outcome = "unknown"
+	}
+	return fmt.Sprintf("%s on %s: %d steps, outcome %s", t.Method, t.Inputs, len(t.Events), outcome)
+}
+
+// Print every event of t to w, after its summary.
+func Print(w io.Writer, t *Trace) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, t.Summary())
+	for _, e := range t.Events {
+		fmt.Fprint(out, e)
+	}
+	return out.Flush()
+}
+
+const replayHelp = `next [n]     go forward n events, 1 by default
+prev [n]     go back n events, 1 by default
+goto <step>  go to the event of step
+first        go to the first event
+last         go to the last event
+find <text>  go forward to the next event whose instruction contains text
+help         print this help
+quit         leave the viewer
+An empty line repeats the previous command.
+`
+
+// Replay t, printing its events to w one at a time, as asked by the commands
+// read from r, until r is exhausted or quit is given.
+func Replay(t *Trace, r io.Reader, w io.Writer) error {
+	fmt.Fprintln(w, t.Summary())
+	if len(t.Events) == 0 {
+		return nil
+	}
+
+	at, last := 0, ""
+	fmt.Fprint(w, t.Events[at])
+
+	lines := bufio.NewScanner(r)
+	for {
+		fmt.Fprint(w, Prompt)
+		if !lines.Scan() {
+			fmt.Fprintln(w)
+			return lines.Err()
+		}
+
+		line := strings.TrimSpace(lines.Text())
+		if line == "" {
+			line = last
+		}
+		last = line
+
+		cmd, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		n := 1
+		if arg != "" && cmd != "find" {
+			var err error
+			if n, err = strconv.Atoi(arg); err != nil {
+				fmt.Fprintf(w, "error: expected a number, got %s\n", arg)
+				continue
+			}
+		}
+
+		to := at
+		switch cmd {
+		case "next", "n", "":
+			to = at + n
+		case "prev", "p":
+			to = at - n
+		case "goto", "g":
+			to = n - t.Events[0].Step
+		case "first":
+			to = 0
+		case "last":
+			to = len(t.Events) - 1
+		case "find", "f":
+			to = -1
+			for i := at + 1; i < len(t.Events); i++ {
+				if strings.Contains(t.Events[i].Instruction, arg) {
+					to = i
+					break
+				}
+			}
+			if to < 0 {
+				fmt.Fprintf(w, "error: no later instruction contains %s\n", arg)
+				continue
+			}
+		case "help", "h":
+			fmt.Fprint(w, replayHelp)
+			continue
+		case "quit", "q":
+			return nil
+		default:
+			fmt.Fprintf(w, "error: unknown command %s, see help\n", cmd)
+			continue
+		}
+
+		if to < 0 || to >= len(t.Events) {
+			fmt.Fprintf(w, "error: no step %d, the trace has steps %d to %d\n",
+				t.Events[0].Step+to, t.Events[0].Step, t.Events[len(t.Events)-1].Step)
+			continue
+		}
+		at = to
+		fmt.Fprint(w, t.Events[at])
+	}
+}
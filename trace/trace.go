@@ -0,0 +1,92 @@
+// Package trace records every instruction a run of the interpreter takes, with
+// the operand stack and locals before and after it and the writes it makes,
+// and stores traces as JSON Lines or in a compact binary format.
+//
+// Traces hold nothing that varies between runs of the same method on the same
+// inputs, such as times, so they can be diffed across versions of the tool.
+package trace
+
+import (
+	"github.com/luishfonseca/dtu_pa/assembler"
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+// Trace of a run of Method on Inputs.
+type Trace struct {
+	Method  string // e.g. jpamb/cases/Simple.divideByN:(I)I
+	Inputs  string // e.g. (0)
+	Events  []Event
+	Outcome string // as interpret prints it, e.g. divide by zero; empty if unknown
+}
+
+// Event is the run of an instruction.
+type Event struct {
+	Step        int    // from 1
+	Depth       int    // frames on the call stack, 1 in the method traced
+	Method      string // e.g. jpamb/cases/Simple.divideByN:(I)I
+	PC          int
+	Line        int    // source line, -1 if unknown
+	Instruction string // with its operands resolved, e.g. invokestatic pkg/C/m(I)I
+	Before      State
+	After       State // of the same frame, even if it has returned
+	Writes      []interpreter.Write
+	Error       string // returned by Step, e.g. an uncaught exception
+}
+
+// State of a frame.
+type State struct {
+	Stack  []interpreter.Value // the top last
+	Locals []interpreter.Value
+}
+
+// Recorder is an interpreter.Observer recording the instructions run in Trace,
+// whose Method is set to that of the bottom frame.
+type Recorder struct {
+	Trace Trace
+}
+
+func (r *Recorder) Before(in *interpreter.Interpreter, f *interpreter.Frame, op data.Op) {
+	instr, err := assembler.Instruction(f.Method.Class, f.PC, op)
+	if err != nil {
+		instr = op.Code.String()
+	}
+
+	if r.Trace.Method == "" {
+		r.Trace.Method = name(in.Frames[0].Method)
+	}
+
+	r.Trace.Events = append(r.Trace.Events, Event{
+		Step:        in.Steps,
+		Depth:       len(in.Frames),
+		Method:      name(f.Method),
+		PC:          f.PC,
+		Line:        f.Method.Line(f.PC),
+		Instruction: instr,
+		Before:      state(f),
+	})
+}
+
+func (r *Recorder) Write(w interpreter.Write) {
+	e := &r.Trace.Events[len(r.Trace.Events)-1]
+	e.Writes = append(e.Writes, w)
+}
+
+func (r *Recorder) After(in *interpreter.Interpreter, f *interpreter.Frame, op data.Op, err error) {
+	e := &r.Trace.Events[len(r.Trace.Events)-1]
+	e.After = state(f)
+	if err != nil {
+		e.Error = err.Error()
+	}
+}
+
+func name(m *interpreter.Method) string {
+	return interpreter.Ref{Class: m.ClassName, Name: m.Info.Name.Value, Descriptor: m.Info.Descriptor.Value}.String()
+}
+
+func state(f *interpreter.Frame) State {
+	return State{
+		Stack:  append([]interpreter.Value{}, f.Stack...),
+		Locals: append([]interpreter.Value{}, f.Locals...),
+	}
+}
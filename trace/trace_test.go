@@ -0,0 +1,158 @@
+package trace
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/internal/testutil"
+	"github.com/luishfonseca/dtu_pa/interpreter"
+)
+
+const source = `.class public super jpamb/cases/Counter
+.super java/lang/Object
+.source Counter.java
+.field static count I
+
+.method public static fill([II)I
+  .limit stack 3
+  .limit locals 2
+  .line 5
+  aload_0
+  iconst_0
+  iload_1
+  iastore
+  .line 6
+  iload_1
+  invokestatic jpamb/cases/Counter/bump(I)V
+  .line 7
+  getstatic jpamb/cases/Counter/count I
+  ireturn
+.end method
+
+.method public static bump(I)V
+  .limit stack 1
+  .limit locals 1
+  .line 10
+  iload_0
+  putstatic jpamb/cases/Counter/count I
+  return
+.end method
+`
+
+func record(t *testing.T, inputs string) *Trace {
+	t.Helper()
+
+	cp, classes := testutil.Classpath(t, source)
+	c := classes[0]
+
+	m, err := c.Method(data.Selector{Name: "fill"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := interpreter.ParseInputs(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &Recorder{Trace: Trace{Inputs: inputs}}
+	in := interpreter.New(interpreter.WithClasspath(cp), interpreter.WithObserver(rec))
+	args, err := in.Args(m, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Start(m, args); err != nil {
+		t.Fatal(err)
+	}
+
+	term, err := in.Execute(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Trace.Outcome = term.Outcome.String()
+	return &rec.Trace
+}
+
+func TestRecorder(t *testing.T) {
+	tr := record(t, "([I:0,0], 7)")
+
+	if tr.Method != "jpamb/cases/Counter.fill:([II)I" || tr.Outcome != "ok" || len(tr.Events) != 11 {
+		t.Fatalf("got %s", tr.Summary())
+	}
+
+	want := "step 4 depth 1 jpamb/cases/Counter.fill:([II)I line 5 pc 3: iastore\n" +
+		"  stack  [@1, 0, 7] -> []\n" +
+		"  locals [@1, 7] -> [@1, 7]\n" +
+		"  write  @1[0] = 7\n"
+	if got := tr.Events[3].String(); got != want {
+		t.Errorf("iastore:\n%s\nwant\n%s", got, want)
+	}
+
+	if e := tr.Events[7]; e.Depth != 2 || e.Line != 10 || len(e.Writes) != 1 ||
+		e.Writes[0].String() != "jpamb/cases/Counter.count:I = 7" {
+		t.Errorf("putstatic:\n%s", e)
+	}
+
+	var e *Event
+	out := record(t, "(null, 1)")
+	if e = &out.Events[len(out.Events)-1]; out.Outcome != "null pointer" || e.Error == "" {
+		t.Errorf("storing into null ended with %s:\n%s", out.Summary(), e)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	tr := record(t, "([I:0,0], 7)")
+
+	for name, write := range map[string]func(*bytes.Buffer, *Trace) error{
+		"jsonl":  func(b *bytes.Buffer, t *Trace) error { return WriteJSONL(b, t) },
+		"binary": func(b *bytes.Buffer, t *Trace) error { return WriteBinary(b, t) },
+	} {
+		var a, b bytes.Buffer
+		if err := write(&a, tr); err != nil {
+			t.Fatal(err)
+		}
+		if err := write(&b, record(t, "([I:0,0], 7)")); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a.Bytes(), b.Bytes()) {
+			t.Errorf("%s: traces of the same run differ", name)
+		}
+
+		got, err := Read(bytes.NewReader(a.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, tr) {
+			t.Errorf("%s: read back\n%+v\nwant\n%+v", name, got, tr)
+		}
+
+		if _, err := Read(bytes.NewReader(a.Bytes()[:a.Len()-2])); err == nil {
+			t.Errorf("%s: read a truncated trace", name)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	tr := record(t, "([I:0,0], 7)")
+
+	var out strings.Builder
+	if err := Replay(tr, strings.NewReader("next 3\n\nprev\ngoto 11\nnext\nfind putstatic\nquit\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"jpamb/cases/Counter.fill:([II)I on ([I:0,0], 7): 11 steps, outcome ok\n",
+		"(replay) step 4 depth 1",
+		"(replay) step 7 depth 2",
+		"(replay) step 6 depth 1",
+		"(replay) step 11 depth 1",
+		"(replay) error: no step 12, the trace has steps 1 to 11\n",
+		"(replay) error: no later instruction contains putstatic\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
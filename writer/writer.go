@@ -0,0 +1,607 @@
+// Package writer serialises a data.Class back into a class file.
+//
+// Constants are referred to by identity: every reference held by the class
+// must point to a constant of its ConstantPool, whose position gives the index
+// written. Attributes are written in the order of their handles' Begin, which
+// for a parsed class is the order they were found in.
+//
+// With Compact, constants nothing refers to are left out, and the remaining
+// ones renumbered in their original order.
+package writer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/util"
+)
+
+type writer struct {
+	class   *data.Class
+	resolve data.Resolver
+	pool    []data.Data // constants to write, in order
+	indices map[data.Data]uint16
+	used    map[data.Data]bool // constants referred to, when compacting
+	compact bool
+}
+
+type Option func(*writer)
+
+// Compact leaves out constants the class does not refer to.
+func Compact() Option {
+	return func(w *writer) {
+		w.compact = true
+	}
+}
+
+// Write serialises class to w, using resolve to obtain the contents of its
+// attribute and bytecode handles.
+func Write(w io.Writer, class *data.Class, resolve data.Resolver, opts ...Option) error {
+	b, err := Bytes(class, resolve, opts...)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// Bytes serialises class into a new slice.
+func Bytes(class *data.Class, resolve data.Resolver, opts ...Option) ([]byte, error) {
+	wr := &writer{
+		class:   class,
+		resolve: resolve,
+	}
+
+	for _, opt := range opts {
+		opt(wr)
+	}
+
+	wr.number(class.ConstantPool)
+
+	if wr.compact {
+		// A first pass without the constant pool finds what the rest of the
+		// class refers to
+		wr.used = make(map[data.Data]bool)
+		wr.pool = nil
+		if err := wr.writeClass(&buffer{}); err != nil {
+			return nil, err
+		}
+
+		wr.number(wr.reachable())
+		wr.used = nil
+	}
+
+	out := &buffer{}
+	if err := wr.writeClass(out); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), out.err
+}
+
+// buffer accumulates encoded values, remembering the first error.
+type buffer struct {
+	bytes.Buffer
+	err error
+}
+
+func (b *buffer) put(vs ...any) {
+	for _, v := range vs {
+		if b.err != nil {
+			return
+		}
+
+		enc, err := util.Encode(v)
+		if err != nil {
+			b.err = err
+			return
+		}
+
+		b.Write(enc)
+	}
+}
+
+// number the constants of pool by their position.
+func (w *writer) number(pool []data.Data) {
+	w.pool = pool
+	w.indices = make(map[data.Data]uint16, len(pool))
+
+	for i, c := range pool {
+		if _, ok := w.indices[c]; c != nil && !ok {
+			w.indices[c] = uint16(i + 1)
+		}
+	}
+}
+
+// reachable lists the constants of the class that are used, or referred to by
+// a used constant, keeping the empty slot after longs and doubles.
+func (w *writer) reachable() []data.Data {
+	work := slices.Collect(maps.Keys(w.used))
+	for len(work) > 0 {
+		c := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for _, r := range data.References(c) {
+			if r != nil && *r != nil && !w.used[*r] {
+				w.used[*r] = true
+				work = append(work, *r)
+			}
+		}
+	}
+
+	var pool []data.Data
+	for _, c := range w.class.ConstantPool {
+		if c != nil && w.used[c] {
+			pool = append(pool, c)
+			if data.IsWide(c) {
+				pool = append(pool, nil)
+			}
+		}
+	}
+
+	return pool
+}
+
+// index of the constant c in the constant pool.
+func (w *writer) index(c data.Data) (uint16, error) {
+	if i, ok := w.indices[c]; ok {
+		if w.used != nil {
+			w.used[c] = true
+		}
+		return i, nil
+	}
+
+	return 0, fmt.Errorf("%v is not in the constant pool", c)
+}
+
+// optional is the index of c, or 0 if c is nil.
+func optional[T interface {
+	*E
+	data.Data
+}, E any](w *writer, c T) (uint16, error) {
+	if c == nil {
+		return 0, nil
+	}
+
+	return w.index(c)
+}
+
+func (w *writer) writeClass(out *buffer) error {
+	var major, minor uint16
+	if _, err := fmt.Sscanf(w.class.Version, "%d.%d", &major, &minor); err != nil {
+		return fmt.Errorf("invalid version %q: %w", w.class.Version, err)
+	}
+
+	out.put(uint32(0xCAFEBABE), minor, major)
+
+	if err := w.writeConstantPool(out); err != nil {
+		return err
+	}
+
+	this, err := w.index(w.class.ThisClass)
+	if err != nil {
+		return fmt.Errorf("this class: %w", err)
+	}
+
+	super, err := optional(w, w.class.SuperClass)
+	if err != nil {
+		return fmt.Errorf("super class: %w", err)
+	}
+
+	out.put(uint16(w.class.AccessFlags), this, super, uint16(len(w.class.Interfaces)))
+	for i, iface := range w.class.Interfaces {
+		idx, err := w.index(iface)
+		if err != nil {
+			return fmt.Errorf("interface %d: %w", i, err)
+		}
+		out.put(idx)
+	}
+
+	for _, members := range [][]data.MemberInfo{w.class.Fields, w.class.Methods} {
+		out.put(uint16(len(members)))
+		for _, m := range members {
+			if err := w.writeMember(out, m); err != nil {
+				return fmt.Errorf("%s %s: %w", m.MemberType, m.Name.Value, err)
+			}
+		}
+	}
+
+	if err := w.writeAttributes(out, data.SortedHandles(w.class.Attributes)); err != nil {
+		return fmt.Errorf("class attributes: %w", err)
+	}
+
+	return nil
+}
+
+func (w *writer) writeConstantPool(out *buffer) error {
+	pool := w.pool
+	if len(pool) >= 0xFFFF {
+		return fmt.Errorf("constant pool of %d entries is too large", len(pool))
+	}
+	out.put(uint16(len(pool) + 1))
+
+	for i, c := range pool {
+		if c == nil && i > 0 && data.IsWide(pool[i-1]) {
+			continue
+		}
+
+		if err := w.writeConstant(out, c); err != nil {
+			return fmt.Errorf("constant pool entry %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *writer) writeConstant(out *buffer, c data.Data) error {
+	if c == nil {
+		return fmt.Errorf("empty constant pool slot")
+	}
+
+	refs := func(tag uint8, refs ...*data.Data) error {
+		out.put(tag)
+		for _, ref := range refs {
+			i, err := w.index(*ref)
+			if err != nil {
+				return err
+			}
+			out.put(i)
+		}
+		return nil
+	}
+
+	switch c.Tag() {
+	case data.CP_UTF8:
+		v := c.ConstantUtf8().Value
+		if len(v) > 0xFFFF {
+			return fmt.Errorf("utf8 constant of %d bytes is too long", len(v))
+		}
+		out.put(uint8(1), uint16(len(v)))
+		out.WriteString(v)
+	case data.CP_INTEGER:
+		out.put(uint8(3), c.ConstantInteger().Value)
+	case data.CP_FLOAT:
+		out.put(uint8(4), c.ConstantFloat().Value)
+	case data.CP_LONG:
+		out.put(uint8(5), c.ConstantLong().Value)
+	case data.CP_DOUBLE:
+		out.put(uint8(6), c.ConstantDouble().Value)
+	case data.CP_CLASS:
+		return refs(7, c.ConstantClass().Name)
+	case data.CP_STRING:
+		return refs(8, c.ConstantString().Value)
+	case data.CP_FIELDREF:
+		return refs(9, c.ConstantFieldref().Clazz, c.ConstantFieldref().NameAndType)
+	case data.CP_METHODREF:
+		return refs(10, c.ConstantMethodref().Clazz, c.ConstantMethodref().NameAndType)
+	case data.CP_INTERFACE_METHODREF:
+		return refs(11, c.ConstantInterfaceMethodref().Clazz, c.ConstantInterfaceMethodref().NameAndType)
+	case data.CP_NAME_AND_TYPE:
+		return refs(12, c.ConstantNameAndType().Name, c.ConstantNameAndType().Descriptor)
+	default:
+		return fmt.Errorf("unsupported constant %s", c.Tag())
+	}
+
+	return nil
+}
+
+func (w *writer) writeMember(out *buffer, m data.MemberInfo) error {
+	name, err := w.index(m.Name)
+	if err != nil {
+		return err
+	}
+
+	descriptor, err := w.index(m.Descriptor)
+	if err != nil {
+		return err
+	}
+
+	out.put(uint16(m.AccessFlags), name, descriptor)
+
+	return w.writeAttributes(out, data.SortedHandles(m.Attributes))
+}
+
+func (w *writer) writeAttributes(out *buffer, handles []*data.AttributeHandle) error {
+	out.put(uint16(len(handles)))
+
+	for _, h := range handles {
+		if err := w.writeAttribute(out, *h); err != nil {
+			return fmt.Errorf("%s: %w", h.AttributeTag, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *writer) writeAttribute(out *buffer, h data.AttributeHandle) error {
+	if h.Name == nil {
+		return fmt.Errorf("attribute has no name")
+	}
+
+	name, err := w.index(h.Name)
+	if err != nil {
+		return err
+	}
+
+	d, err := w.resolve(&h)
+	if err != nil {
+		return err
+	}
+
+	body := &buffer{}
+	switch d.Tag() {
+	case data.ATTR_CODE:
+		err = w.writeCode(body, d.AttributeCode())
+	case data.ATTR_SOURCE_FILE:
+		err = w.writeSourceFile(body, d.AttributeSourceFile())
+	case data.ATTR_RUNTIME_VISIBLE_ANNOTATIONS:
+		err = w.writeRuntimeVisibleAnnotations(body, d.AttributeRuntimeVisibleAnnotations())
+	case data.ATTR_INNER_CLASSES:
+		err = w.writeInnerClasses(body, d.AttributeInnerClasses())
+	case data.ATTR_LINE_NUMBER_TABLE:
+		w.writeLineNumberTable(body, d.AttributeLineNumberTable())
+	case data.ATTR_LOCAL_VARIABLE_TABLE:
+		err = w.writeLocalVariableTable(body, d.AttributeLocalVariableTable())
+	case data.ATTR_STACK_MAP_TABLE:
+		err = w.writeStackMapTable(body, d.AttributeStackMapTable())
+	default:
+		err = fmt.Errorf("unsupported attribute %s", d.Tag())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if body.err != nil {
+		return body.err
+	}
+
+	out.put(name, uint32(body.Len()))
+	out.Write(body.Bytes())
+
+	return nil
+}
+
+func (w *writer) writeCode(out *buffer, code *data.AttributeCode) error {
+	d, err := w.resolve(&code.CodeHandle)
+	if err != nil {
+		return err
+	}
+
+	bc := &buffer{}
+	for pc, op := range d.Bytecode().Ops {
+		bc.put(uint8(op.Code))
+
+		// Operands refer to the original constant pool, renumbered when compacting
+		i, ok := op.ConstantIndex()
+		if !ok || !w.compact {
+			bc.Write(op.Arg)
+			continue
+		}
+
+		if i == 0 || int(i) > len(w.class.ConstantPool) {
+			return fmt.Errorf("op %d: constant index %d out of range", pc, i)
+		}
+
+		if i, err = w.index(w.class.ConstantPool[i-1]); err != nil {
+			return fmt.Errorf("op %d: %w", pc, err)
+		}
+
+		if op.Code == data.OP_LDC {
+			if i > 0xFF {
+				return fmt.Errorf("op %d: constant index %d does not fit ldc", pc, i)
+			}
+			bc.put(uint8(i))
+		} else {
+			bc.put(i)
+		}
+	}
+
+	out.put(code.MaxStack, code.MaxLocals, uint32(bc.Len()))
+	out.Write(bc.Bytes())
+
+	out.put(uint16(len(code.ExceptionTable)))
+	for _, e := range code.ExceptionTable {
+		catchType, err := optional(w, e.CatchType)
+		if err != nil {
+			return err
+		}
+		out.put(e.StartPC, e.EndPC, e.HandlerPC, catchType)
+	}
+
+	handles := make([]*data.AttributeHandle, len(code.Attributes))
+	for i := range code.Attributes {
+		handles[i] = &code.Attributes[i]
+	}
+	return w.writeAttributes(out, handles)
+}
+
+func (w *writer) writeSourceFile(out *buffer, attr *data.AttributeSourceFile) error {
+	i, err := w.index(attr.SourceFile)
+	if err != nil {
+		return err
+	}
+
+	out.put(i)
+	return nil
+}
+
+func (w *writer) writeLineNumberTable(out *buffer, attr *data.AttributeLineNumberTable) {
+	out.put(uint16(len(attr.LineNumbers)))
+	for _, ln := range attr.LineNumbers {
+		out.put(ln)
+	}
+}
+
+func (w *writer) writeLocalVariableTable(out *buffer, attr *data.AttributeLocalVariableTable) error {
+	out.put(uint16(len(attr.LocalVariables)))
+	for _, lv := range attr.LocalVariables {
+		name, err := w.index(lv.Name)
+		if err != nil {
+			return err
+		}
+
+		descriptor, err := w.index(lv.Descriptor)
+		if err != nil {
+			return err
+		}
+
+		out.put(lv.StartPC, lv.Length, name, descriptor, lv.Index)
+	}
+
+	return nil
+}
+
+func (w *writer) writeInnerClasses(out *buffer, attr *data.AttributeInnerClasses) error {
+	out.put(uint16(len(attr.Classes)))
+	for _, c := range attr.Classes {
+		inner, err := w.index(c.InnerClass)
+		if err != nil {
+			return err
+		}
+
+		outer, err := optional(w, c.OuterClass)
+		if err != nil {
+			return err
+		}
+
+		name, err := optional(w, c.InnerName)
+		if err != nil {
+			return err
+		}
+
+		out.put(inner, outer, name, uint16(c.AccessFlags))
+	}
+
+	return nil
+}
+
+func (w *writer) writeStackMapTable(out *buffer, attr *data.AttributeStackMapTable) error {
+	out.put(uint16(len(attr.Frames)))
+	for _, f := range attr.Frames {
+		out.put(f.FrameType)
+
+		switch t := f.FrameType; {
+		case t <= 63: // same_frame
+		case t <= 127: // same_locals_1_stack_item_frame
+			if err := w.writeVerificationTypes(out, f.Stack); err != nil {
+				return err
+			}
+		case t < 247:
+			return fmt.Errorf("reserved stack map frame type %d", t)
+		case t == 247: // same_locals_1_stack_item_frame_extended
+			out.put(f.OffsetDelta)
+			if err := w.writeVerificationTypes(out, f.Stack); err != nil {
+				return err
+			}
+		case t <= 254: // chop_frame, same_frame_extended and append_frame
+			out.put(f.OffsetDelta)
+			if err := w.writeVerificationTypes(out, f.Locals); err != nil {
+				return err
+			}
+		default: // full_frame
+			out.put(f.OffsetDelta, uint16(len(f.Locals)))
+			if err := w.writeVerificationTypes(out, f.Locals); err != nil {
+				return err
+			}
+
+			out.put(uint16(len(f.Stack)))
+			if err := w.writeVerificationTypes(out, f.Stack); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *writer) writeVerificationTypes(out *buffer, types []data.VerificationType) error {
+	for _, vt := range types {
+		out.put(vt.Tag)
+
+		switch vt.Tag {
+		case data.VT_OBJECT:
+			i, err := w.index(vt.Class)
+			if err != nil {
+				return err
+			}
+			out.put(i)
+		case data.VT_UNINITIALIZED:
+			out.put(vt.Offset)
+		}
+	}
+
+	return nil
+}
+
+func (w *writer) writeRuntimeVisibleAnnotations(out *buffer, attr *data.AttributeRuntimeVisibleAnnotations) error {
+	out.put(uint16(len(attr.Annotations)))
+	for _, a := range attr.Annotations {
+		if err := w.writeAnnotation(out, a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *writer) writeAnnotation(out *buffer, a data.Annotation) error {
+	typ, err := w.index(a.Type)
+	if err != nil {
+		return err
+	}
+
+	out.put(typ, uint16(len(a.Elements)))
+	for _, e := range a.Elements {
+		name, err := w.index(e.Name)
+		if err != nil {
+			return err
+		}
+
+		out.put(name)
+		if err := w.writeElementValue(out, e.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *writer) writeElementValue(out *buffer, e data.ElementValue) error {
+	out.put(e.Tag)
+
+	var refs []data.Data
+	switch e.Tag {
+	case 'e':
+		refs = []data.Data{e.EnumType, e.EnumName}
+	case 'c':
+		refs = []data.Data{e.Class}
+	case '@':
+		return w.writeAnnotation(out, *e.Annotation)
+	case '[':
+		out.put(uint16(len(e.Values)))
+		for _, v := range e.Values {
+			if err := w.writeElementValue(out, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		refs = []data.Data{e.Const}
+	}
+
+	for _, ref := range refs {
+		i, err := w.index(ref)
+		if err != nil {
+			return err
+		}
+		out.put(i)
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luishfonseca/dtu_pa/data"
+	"github.com/luishfonseca/dtu_pa/parser"
+)
+
+// seed builds a class in memory, along with the contents of its handles.
+type seed struct {
+	class *data.Class
+	attrs map[int64]data.Data
+	codes map[int64]*data.Bytecode
+	next  int64
+}
+
+func newSeed() *seed {
+	return &seed{
+		class: &data.Class{
+			Version:      "65.0",
+			ConstantPool: make([]data.Data, 0, 256), // Slots are referenced by address
+		},
+		attrs: make(map[int64]data.Data),
+		codes: make(map[int64]*data.Bytecode),
+	}
+}
+
+func (s *seed) add(c data.Data) *data.Data {
+	s.class.ConstantPool = append(s.class.ConstantPool, c)
+	return &s.class.ConstantPool[len(s.class.ConstantPool)-1]
+}
+
+// slot returns the constant pool slot holding c.
+func (s *seed) slot(c data.Data) *data.Data {
+	for i := range s.class.ConstantPool {
+		if s.class.ConstantPool[i] == c {
+			return &s.class.ConstantPool[i]
+		}
+	}
+	panic("constant not in pool")
+}
+
+func (s *seed) utf8(v string) *data.ConstantUtf8 {
+	return (*s.add(&data.ConstantUtf8{Value: v})).ConstantUtf8()
+}
+
+func (s *seed) class_(name string) *data.ConstantClass {
+	return (*s.add(&data.ConstantClass{Name: s.add(&data.ConstantUtf8{Value: name})})).ConstantClass()
+}
+
+func (s *seed) attribute(d data.Data, name string) *data.AttributeHandle {
+	s.next++
+	h := &data.AttributeHandle{AttributeTag: d.Tag(), Name: s.utf8(name), Begin: s.next}
+	s.attrs[h.Begin] = d
+	return h
+}
+
+func (s *seed) code(maxStack, maxLocals uint16, ops []data.Op, exceptions []data.ExceptionTableEntry, attrs ...*data.AttributeHandle) *data.AttributeHandle {
+	s.next++
+	code := &data.AttributeCode{
+		MaxStack:       maxStack,
+		MaxLocals:      maxLocals,
+		CodeHandle:     data.BytecodeHandle{Begin: s.next},
+		ExceptionTable: exceptions,
+	}
+	for _, a := range attrs {
+		code.Attributes = append(code.Attributes, *a)
+	}
+	s.codes[s.next] = &data.Bytecode{Ops: ops}
+
+	return s.attribute(code, "Code")
+}
+
+func (s *seed) member(m data.MemberType, flags data.AccessFlags, name, desc string, attrs ...*data.AttributeHandle) data.MemberInfo {
+	info := data.MemberInfo{
+		MemberType:  m,
+		AccessFlags: flags,
+		Name:        s.utf8(name),
+		Descriptor:  s.utf8(desc),
+		Attributes:  make(map[data.Tag]*data.AttributeHandle),
+	}
+	for _, a := range attrs {
+		info.Attributes[a.AttributeTag] = a
+	}
+	return info
+}
+
+func (s *seed) resolve(h data.Data) (data.Data, error) {
+	switch h.Tag() {
+	case data.ATTRIBUTE_HANDLE:
+		return s.attrs[h.AttributeHandle().Begin], nil
+	case data.BYTECODE_HANDLE:
+		return s.codes[h.BytecodeHandle().Begin], nil
+	default:
+		return nil, errors.New("not a handle")
+	}
+}
+
+func seeds() []*seed {
+	minimal := newSeed()
+	minimal.class.ThisClass = minimal.class_("Minimal")
+
+	s := newSeed()
+	s.utf8("unused") // Left out when compacting, moving every index after it
+	s.add(&data.ConstantLong{Value: -1})
+	s.add(nil)
+	s.class.AccessFlags = 0x21
+	s.class.ThisClass = s.class_("jpamb/cases/Simple")
+	s.class.SuperClass = s.class_("java/lang/Object")
+	object := s.class.SuperClass
+	s.class.Interfaces = []*data.ConstantClass{s.class_("java/lang/Runnable"), s.class_("java/io/Serializable")}
+	assertionError := s.class_("java/lang/AssertionError")
+	nat := s.add(&data.ConstantNameAndType{Name: s.add(&data.ConstantUtf8{Value: "<init>"}), Descriptor: s.add(&data.ConstantUtf8{Value: "()V"})})
+	s.add(&data.ConstantMethodref{Clazz: s.slot(object), NameAndType: nat})
+	s.add(&data.ConstantFieldref{Clazz: s.slot(s.class.ThisClass), NameAndType: nat})
+	answer := s.add(&data.ConstantInteger{Value: 42})
+
+	pool := data.NewPoolBuilder(s.class)
+	hello, err := pool.Index(pool.String("hello"))
+	if err != nil {
+		panic(err)
+	}
+	pi, err := pool.Index(pool.Double(3.14))
+	if err != nil {
+		panic(err)
+	}
+	pool.Float(-0.5)
+	pool.InterfaceMethodref("java/util/List", "size", "()I")
+
+	caseType := s.utf8("Ljpamb/utils/Case;")
+	annotations := &data.AttributeRuntimeVisibleAnnotations{Annotations: []data.Annotation{{
+		Type: caseType,
+		Elements: []data.ElementValuePair{
+			{Name: s.utf8("value"), Value: data.ElementValue{Tag: 's', Const: s.utf8("(0) -> divide by zero")}},
+			{Name: s.utf8("n"), Value: data.ElementValue{Tag: 'I', Const: *answer}},
+			{Name: s.utf8("e"), Value: data.ElementValue{Tag: 'e', EnumType: s.utf8("Ljpamb/Kind;"), EnumName: s.utf8("OK")}},
+			{Name: s.utf8("c"), Value: data.ElementValue{Tag: 'c', Class: s.utf8("Ljava/lang/Object;")}},
+			{Name: s.utf8("a"), Value: data.ElementValue{Tag: '[', Values: []data.ElementValue{
+				{Tag: '@', Annotation: &data.Annotation{Type: caseType}},
+				{Tag: 'Z', Const: *answer},
+			}}},
+		},
+	}}}
+
+	s.class.Fields = []data.MemberInfo{s.member(data.FIELD, 0x1018, "$assertionsDisabled", "Z")}
+	s.class.Methods = []data.MemberInfo{
+		s.member(data.METHOD, 0x1, "<init>", "()V", s.code(1, 1, []data.Op{
+			{Code: data.OP_ALOAD_0}, {Code: data.OP_INVOKESPECIAL, Arg: []byte{0, 8}}, {Code: data.OP_RETURN},
+		}, nil, s.attribute(&data.AttributeLineNumberTable{LineNumbers: []data.LineNumber{{StartPC: 0, LineNumber: 3}}}, "LineNumberTable"))),
+		s.member(data.METHOD, 0x9, "divideByN", "(I)I", s.code(2, 1, []data.Op{
+			{Code: data.OP_ICONST_1}, {Code: data.OP_ILOAD_0}, {Code: data.OP_IDIV}, {Code: data.OP_IRETURN},
+			{Code: data.OP_ASTORE_1}, {Code: data.OP_ICONST_0}, {Code: data.OP_IRETURN},
+		}, []data.ExceptionTableEntry{{StartPC: 0, EndPC: 4, HandlerPC: 4, CatchType: assertionError}, {StartPC: 0, EndPC: 4, HandlerPC: 4}},
+			s.attribute(&data.AttributeLocalVariableTable{LocalVariables: []data.LocalVariable{
+				{StartPC: 0, Length: 7, Name: s.utf8("n"), Descriptor: s.utf8("I"), Index: 0},
+			}}, "LocalVariableTable"),
+			s.attribute(&data.AttributeStackMapTable{Frames: []data.StackMapFrame{
+				{FrameType: 68, OffsetDelta: 4, Stack: []data.VerificationType{{Tag: data.VT_OBJECT, Class: assertionError}}},
+				{FrameType: 252, OffsetDelta: 1, Locals: []data.VerificationType{{Tag: data.VT_INTEGER}}},
+				{FrameType: 255, OffsetDelta: 1, Locals: []data.VerificationType{{Tag: data.VT_UNINITIALIZED, Offset: 2}}, Stack: []data.VerificationType{{Tag: data.VT_NULL}}},
+				{FrameType: 250, OffsetDelta: 1},
+			}}, "StackMapTable"),
+		), s.attribute(annotations, "RuntimeVisibleAnnotations")),
+		s.member(data.METHOD, 0x9, "constants", "()V", s.code(2, 0, []data.Op{
+			{Code: data.OP_LDC, Arg: []byte{byte(hello)}}, {Code: data.OP_INVOKESTATIC, Arg: []byte{byte(pi >> 8), byte(pi)}}, {Code: data.OP_RETURN},
+		}, nil)),
+		s.member(data.METHOD, 0x401, "abstract", "()V"),
+	}
+	s.class.Attributes = map[data.Tag]*data.AttributeHandle{
+		data.ATTR_SOURCE_FILE: s.attribute(&data.AttributeSourceFile{SourceFile: s.utf8("Simple.java")}, "SourceFile"),
+		data.ATTR_INNER_CLASSES: s.attribute(&data.AttributeInnerClasses{Classes: []data.InnerClass{
+			{InnerClass: s.class_("jpamb/cases/Simple$Inner"), OuterClass: s.class.ThisClass, InnerName: s.utf8("Inner"), AccessFlags: 0x8},
+			{InnerClass: s.class_("jpamb/cases/Simple$1")},
+		}}, "InnerClasses"),
+	}
+
+	return []*seed{minimal, s}
+}
+
+// roundTrip parses class, writes it back, and checks the output is identical.
+// Unless valid is set, inputs the parser rejects are ignored.
+func roundTrip(t *testing.T, class []byte, valid bool) {
+	s, err := parser.OpenReader(context.Background(), bytes.NewReader(class))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c, err := s.Class()
+	if err != nil {
+		if valid {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	out, err := Bytes(c, s.Request)
+	if perr := (*parser.ParseError)(nil); errors.As(err, &perr) && !valid {
+		return // Broken attribute contents
+	} else if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if !bytes.Equal(class, out) {
+		t.Fatalf("round trip changed the class:\nin:  %x\nout: %x", class, out)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, s := range seeds() {
+		b, err := Bytes(s.class, s.resolve)
+		if err != nil {
+			t.Fatalf("write %s: %v", s.class.ThisClass, err)
+		}
+
+		roundTrip(t, b, true)
+	}
+}
+
+// TestCompact checks that compacting drops unused constants, renumbering the
+// operands of the code, and that compacting again changes nothing.
+func TestCompact(t *testing.T) {
+	s := seeds()[1]
+
+	full, err := Bytes(s.class, s.resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compact, err := Bytes(s.class, s.resolve, Compact())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(compact) >= len(full) {
+		t.Fatalf("compacting did not shrink the class: %d >= %d bytes", len(compact), len(full))
+	}
+
+	sess, err := parser.OpenReader(context.Background(), bytes.NewReader(compact))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	c, err := sess.Class()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range c.ConstantPool {
+		if k != nil && k.Tag() == data.CP_UTF8 && k.ConstantUtf8().Value == "unused" {
+			t.Fatal("unused constant was kept")
+		}
+	}
+
+	for _, m := range c.Methods {
+		if m.Name.Value != "constants" {
+			continue
+		}
+
+		code, err := sess.Request(m.Attributes[data.ATTR_CODE])
+		if err != nil {
+			t.Fatal(err)
+		}
+		bc, err := sess.Request(&code.AttributeCode().CodeHandle)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		i, _ := bc.Bytecode().Ops[0].ConstantIndex()
+		if str := c.ConstantPool[i-1]; str.Tag() != data.CP_STRING || (*str.ConstantString().Value).ConstantUtf8().Value != "hello" {
+			t.Fatalf("ldc refers to %v after compacting", str)
+		}
+	}
+
+	again, err := Bytes(c, sess.Request, Compact())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(compact, again) {
+		t.Fatal("compacting a compact class changed it")
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	for _, s := range seeds() {
+		b, err := Bytes(s.class, s.resolve)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, class []byte) {
+		roundTrip(t, class, false)
+	})
+}